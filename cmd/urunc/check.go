@@ -0,0 +1,65 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+	"github.com/urunc-dev/urunc/pkg/unikontainers"
+)
+
+var checkCommand = &cli.Command{
+	Name:  "check",
+	Usage: "check which features of urunc are available in the current host",
+	ArgsUsage: `
+
+The check command reports the availability of the host features that urunc
+makes use of. When urunc runs in rootless mode (see the global --rootless
+option), it also reports the features that are unavailable without root
+privileges.
+
+EXAMPLE:
+
+	$ urunc --rootless true check`,
+	Action: func(_ context.Context, cmd *cli.Command) error {
+		logrus.WithField("command", "CHECK").WithField("args", os.Args).Debug("urunc INVOKED")
+		if err := checkArgs(cmd, 0, exactArgs); err != nil {
+			return err
+		}
+
+		rootless := rootlessMode(cmd)
+		mode := "root"
+		if rootless {
+			mode = "rootless"
+		}
+		fmt.Fprintf(cmd.Root().Writer, "urunc mode: %s\n\n", mode)
+
+		w := tabwriter.NewWriter(cmd.Root().Writer, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "FEATURE\tSTATUS\tDETAILS")
+		for _, f := range unikontainers.CheckFeatures(rootless) {
+			status := "available"
+			if !f.Available {
+				status = "unavailable"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Name, status, f.Details)
+		}
+		return w.Flush()
+	},
+}
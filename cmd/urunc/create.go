@@ -123,6 +123,10 @@ func createUnikontainer(cmd *cli.Command, uruncCfg *unikontainers.UruncConfig) (
 	}
 	metrics.Capture(m.TS01)
 
+	if rootlessMode(cmd) {
+		unikontainer.SetRootless()
+	}
+
 	err = unikontainer.InitialSetup()
 	if err != nil {
 		return err
@@ -213,6 +217,20 @@ func createUnikontainer(cmd *cli.Command, uruncCfg *unikontainers.UruncConfig) (
 		}
 	}
 
+	// Place reexec in the container's cgroup before it enters the namespaces.
+	// In that way, the monitor and every process it spawns will be accounted
+	// in the container's cgroup.
+	err = unikontainer.SetupCgroup(reexecCommand.Process.Pid, cgroupOpts(cmd))
+	if err != nil {
+		return err
+	}
+	reexecPid := -1
+	defer func() {
+		if err != nil {
+			removeFailedCgroup(unikontainer, reexecCommand, reexecPid)
+		}
+	}()
+
 	// Close child ends of sockets and pipes.
 	err = initSockChild.Close()
 	if err != nil {
@@ -233,7 +251,7 @@ func createUnikontainer(cmd *cli.Command, uruncCfg *unikontainers.UruncConfig) (
 	}
 
 	// Get pids from nsenter and reap dead children
-	reexecPid, err := handleNsenterRet(initSockParent, reexecCommand)
+	reexecPid, err = handleNsenterRet(initSockParent, reexecCommand)
 	if err != nil {
 		return err
 	}
@@ -279,6 +297,21 @@ func createUnikontainer(cmd *cli.Command, uruncCfg *unikontainers.UruncConfig) (
 	return err
 }
 
+// removeFailedCgroup removes the cgroup of a container that failed to get
+// created. The processes in the cgroup get killed first, since a cgroup can
+// not be removed while it has processes.
+func removeFailedCgroup(unikontainer *unikontainers.Unikontainer, reexec *exec.Cmd, reexecPid int) {
+	if reexecPid > 0 {
+		_ = unix.Kill(reexecPid, unix.SIGKILL)
+	}
+	_ = reexec.Process.Kill()
+	_ = reexec.Wait()
+	err := unikontainer.RemoveCgroup()
+	if err != nil {
+		logrus.WithError(err).Error("failed to remove the cgroup of the failed container")
+	}
+}
+
 func createReexecCmd(initSock *os.File, logPipe *os.File) *exec.Cmd {
 	selfPath := "/proc/self/exe"
 	reexecCommand := &exec.Cmd{
@@ -407,7 +440,7 @@ func reexecUnikontainer(cmd *cli.Command) error {
 	if err != nil {
 		return fmt.Errorf("error creating listener on reexec socket: %w", err)
 	}
-	awaitErr := unikontainer.AwaitMsg(unikontainers.StartExecve)
+	awaitErr := unikontainer.AwaitMsg(unikontainers.StartExecve, unikontainers.FromReexec)
 	// Before checking for any errors, make sure to clean up the socket
 	cleanErr := unikontainer.DestroyListener(unikontainers.FromReexec)
 	if cleanErr != nil {
@@ -453,6 +486,12 @@ func reexecUnikontainer(cmd *cli.Command) error {
 	// struct does not have the part of urunc config that configures metrics
 	var sockErr error
 	err = unikontainer.Exec(metrics)
+	var exitErr *unikontainers.GuestExitError
+	if errors.As(err, &exitErr) {
+		// The monitor executed as a child of reexec and the container
+		// exits with the exit code of the guest
+		os.Exit(exitErr.Code)
+	}
 	if err != nil {
 		logrus.WithError(err).Error("Setting up execution environment for monitor")
 		sockErr = unikontainer.SendMessage(unikontainers.StartErr)
@@ -60,6 +60,10 @@ var version string
 
 var metrics m.Writer
 
+// ipcTimeouts are loaded in every invocation, similarly to the log and
+// timestamps configuration, and are not stored in the container's state.
+var ipcTimeouts unikontainers.UruncTimeouts
+
 type FatalWriter struct {
 	cliErrWriter io.Writer
 }
@@ -73,6 +77,12 @@ func (f *FatalWriter) Write(p []byte) (n int, err error) {
 }
 
 func main() {
+	if unikontainers.IsConsoleLogger() {
+		if err := unikontainers.RunConsoleLog(); err != nil {
+			fatal(err)
+		}
+		return
+	}
 	root := "/run/urunc"
 	cmd := &cli.Command{
 		Name:    "urunc",
@@ -105,10 +115,11 @@ func main() {
 			&cli.StringFlag{
 				Name:  "rootless",
 				Value: "auto",
-				Usage: "ignore cgroup permission errors ('true', 'false', or 'auto')",
+				Usage: "run without root privileges, ignoring cgroup permission errors ('true', 'false', or 'auto')",
 			},
 		},
 		Commands: []*cli.Command{
+			checkCommand,
 			createCommand,
 			deleteCommand,
 			killCommand,
@@ -140,6 +151,7 @@ func main() {
 				return nil, err
 			}
 			metrics = m.NewZerologMetrics(cfg.Timestamps.Enabled, cfg.Timestamps.Destination, "")
+			ipcTimeouts = cfg.Timeouts
 			return nil, nil
 		},
 	}
@@ -99,7 +99,11 @@ func startUnikontainer(cmd *cli.Command) error {
 	metrics.Capture(m.TS13)
 
 	// wait ContainerStarted message on start.sock from reexec process
-	err = unikontainer.AwaitMsg(unikontainers.StartSuccess)
+	err = unikontainer.AwaitMsg(unikontainers.StartSuccess, !unikontainers.FromReexec)
+	if errors.Is(err, unikontainers.ErrPeerExited) {
+		err = fmt.Errorf("reexec process exited before starting the monitor: %w", err)
+		return err
+	}
 	if err != nil {
 		err = fmt.Errorf("failed to get message from successful start from reexec: %w", err)
 		return err
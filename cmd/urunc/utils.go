@@ -87,6 +87,7 @@ func getUnikontainer(cmd *cli.Command) (*unikontainers.Unikontainer, error) {
 		}
 		return nil, err
 	}
+	unikontainer.UruncCfg.Timeouts = ipcTimeouts
 
 	return unikontainer, nil
 }
@@ -132,6 +133,37 @@ func fatalWithCode(err error, ret int) {
 	os.Exit(ret)
 }
 
+// rootlessMode returns whether urunc runs in rootless mode, based on the
+// global --rootless cli option. In "auto" mode, urunc is considered rootless
+// only when it runs as an unprivileged user. A root inside a user namespace
+// (e.g. rootlesskit) needs an explicit "true", since the rootless mode
+// disables features such as the block based rootfs.
+func rootlessMode(cmd *cli.Command) bool {
+	switch cmd.String("rootless") {
+	case "true":
+		return true
+	case "auto":
+		return os.Geteuid() != 0
+	default:
+		return false
+	}
+}
+
+// runningInUserNS reports whether urunc runs inside a user namespace. It is a
+// variable, so that tests can replace it.
+var runningInUserNS = userns.RunningInUserNS
+
+// cgroupOpts returns the cgroup options based on the global
+// --systemd-cgroup and --rootless cli options. Permission errors on the
+// cgroups are also ignored for a root inside a user namespace (e.g.
+// rootlesskit or nested containers), which usually can not manage cgroups.
+func cgroupOpts(cmd *cli.Command) unikontainers.CgroupOpts {
+	return unikontainers.CgroupOpts{
+		Systemd:        cmd.Bool("systemd-cgroup"),
+		IgnorePermErrs: rootlessMode(cmd) || runningInUserNS(),
+	}
+}
+
 // ShouldHonorXDGRuntimeDir reports whether the runtime should use XDG_RUNTIME_DIR
 // for the default root directory (e.g. /run/user/UID/urunc instead of /run/urunc).
 // It returns true for non-root processes and for root inside a user namespace
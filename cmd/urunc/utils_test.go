@@ -0,0 +1,70 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v3"
+	"github.com/urunc-dev/urunc/pkg/unikontainers"
+)
+
+// runCgroupOpts returns the cgroup options of a command that runs with the
+// given --rootless option.
+func runCgroupOpts(t *testing.T, rootless string) unikontainers.CgroupOpts {
+	var opts unikontainers.CgroupOpts
+	cmd := &cli.Command{
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "systemd-cgroup"},
+			&cli.StringFlag{Name: "rootless", Value: "auto"},
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			opts = cgroupOpts(cmd)
+			return nil
+		},
+	}
+	assert.NoError(t, cmd.Run(context.Background(), []string{"urunc", "--rootless", rootless}))
+	return opts
+}
+
+// The tests replace runningInUserNS and therefore do not run in parallel.
+func TestCgroupOpts(t *testing.T) {
+	inUserNS := runningInUserNS
+	t.Cleanup(func() { runningInUserNS = inUserNS })
+
+	t.Run("root inside a user namespace in auto mode", func(t *testing.T) {
+		if os.Geteuid() != 0 {
+			t.Skip("the test needs to run as root")
+		}
+		runningInUserNS = func() bool { return true }
+		assert.True(t, runCgroupOpts(t, "auto").IgnorePermErrs)
+	})
+
+	t.Run("root in the initial user namespace in auto mode", func(t *testing.T) {
+		if os.Geteuid() != 0 {
+			t.Skip("the test needs to run as root")
+		}
+		runningInUserNS = func() bool { return false }
+		assert.False(t, runCgroupOpts(t, "auto").IgnorePermErrs)
+	})
+
+	t.Run("explicit rootless mode", func(t *testing.T) {
+		runningInUserNS = func() bool { return false }
+		assert.True(t, runCgroupOpts(t, "true").IgnorePermErrs)
+	})
+}
@@ -20,4 +20,8 @@ const (
 	// TODO: Experiment with DynamicNetworkTapIP starting from 172.16.X.1
 	DynamicNetworkTapIP  = "172.16.X.2"
 	QueueProxyRedirectIP = "172.16.1.2"
+	// The private network of the unikernels that share a network
+	// namespace with the bridge network mode
+	BridgeNetworkSubnet    = "172.16.0.0/16"
+	BridgeNetworkGatewayIP = "172.16.0.1"
 )
@@ -0,0 +1,127 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runIptables runs iptables with the given arguments and returns its
+// output.
+func runIptables(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	path, err := exec.LookPath("iptables")
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Cmd{
+		Path:   path,
+		Args:   append(append([]string{path}, args...), "--wait", "1"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	err = cmd.Run()
+	if err != nil {
+		switch err.(type) {
+		case *exec.ExitError:
+			return "", fmt.Errorf("iptables command %s failed: %s", cmd.String(), stderr.String())
+		default:
+			return "", err
+		}
+	}
+	return stdout.String(), nil
+}
+
+// appendIptablesRule appends a rule to a chain, unless the chain already
+// has it.
+func appendIptablesRule(table string, chain string, rule ...string) error {
+	_, err := runIptables(append([]string{"-t", table, "-C", chain}, rule...)...)
+	if err == nil {
+		return nil
+	}
+	_, err = runIptables(append([]string{"-t", table, "-A", chain}, rule...)...)
+	return err
+}
+
+// deleteIptablesRules deletes the rules of a chain with the given comment.
+func deleteIptablesRules(table string, chain string, comment string) error {
+	rules, err := runIptables("-t", table, "-S", chain)
+	if err != nil {
+		return err
+	}
+	for _, rule := range strings.Split(rules, "\n") {
+		fields := strings.Fields(rule)
+		if len(fields) < 2 || fields[0] != "-A" || !hasComment(fields, comment) {
+			continue
+		}
+		fields[0] = "-D"
+		for i := range fields {
+			fields[i] = strings.Trim(fields[i], `"`)
+		}
+		_, err = runIptables(append([]string{"-t", table}, fields...)...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasComment checks if the fields of a rule, as listed by iptables -S,
+// have the given comment.
+func hasComment(fields []string, comment string) bool {
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "--comment" && strings.Trim(fields[i+1], `"`) == comment {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply the following rule, unless it is already applied:
+// iptables -t nat -A POSTROUTING -o <IF> -s <IP> -j MASQUERADE --wait 1
+// and write 1 to /proc/sys/net/ipv4/ip_forward to enable IP forwarding.
+func setNATRule(iface string, sourceIP string) error {
+	_, err := exec.LookPath("iptables")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile("/proc/sys/net/ipv4/ip_forward", os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open /proc/sys/net/ipv4/ip_forward: %w", err)
+	}
+	defer file.Close()
+
+	_, err = file.WriteString("1")
+	if err != nil {
+		return fmt.Errorf("failed to enable IP forwarding: %w", err)
+	}
+	netlog.Debug("Enabled IP forwarding")
+
+	err = appendIptablesRule("nat", "POSTROUTING", "-s", sourceIP, "-o", iface, "-j", "MASQUERADE")
+	if err != nil {
+		return err
+	}
+
+	netlog.Debug("Applied iptables rule for NAT")
+
+	return nil
+}
@@ -15,9 +15,13 @@
 package network
 
 import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"strings"
 
 	"github.com/jackpal/gateway"
@@ -27,7 +31,14 @@ import (
 )
 
 const (
-	DefaultTap = "tapX_urunc"
+	// The tap devices of a container are named tap<hash of its ID>_urunc,
+	// see TapName
+	tapPrefix = "tap"
+	tapSuffix = "_urunc"
+	// MaxInterfaces is the maximum number of network interfaces of a
+	// container that get mirrored to the guest, since the index of the
+	// interface is a single digit of the name of its tap device
+	MaxInterfaces = 10
 )
 
 var netlog = logrus.WithField("subsystem", "network")
@@ -37,7 +48,10 @@ type UnikernelNetworkInfo struct {
 	EthDevice Interface
 }
 type Manager interface {
-	NetworkSetup(uid uint32, gid uint32) (*UnikernelNetworkInfo, error)
+	// NetworkSetup creates the tap devices of the container and returns
+	// their information, starting with the interface of the default
+	// route. When multiNIC is not set, only that interface is mirrored.
+	NetworkSetup(containerID string, multiNIC bool, uid uint32, gid uint32) ([]UnikernelNetworkInfo, error)
 }
 
 type Interface struct {
@@ -54,27 +68,63 @@ func NewNetworkManager(networkType string) (Manager, error) {
 		return &StaticNetwork{}, nil
 	case "dynamic":
 		return &DynamicNetwork{}, nil
+	case "bridge":
+		return &BridgeNetwork{}, nil
 	default:
 		return nil, fmt.Errorf("network manager %s not supported", networkType)
 
 	}
 }
 
-func getTapIndex() (int, error) {
+// TapName returns the name of the tap device of the index-th network
+// interface of a container. The name is unique per container, so that
+// the unikernels that share a network namespace do not clash, and fits in
+// the 15 characters of an interface name. The first tap device gets 24
+// bits of the hash of the ID in hex. The name of the rest starts with a
+// letter after f for their index, followed by 25 bits of the hash in
+// base32, so that it never matches the name of a first tap device.
+func TapName(containerID string, index int) string {
+	sum := sha256.Sum256([]byte(containerID))
+	if index == 0 {
+		return tapPrefix + hex.EncodeToString(sum[:3]) + tapSuffix
+	}
+	hash := strings.ToLower(base32.StdEncoding.EncodeToString(sum[:4]))[:5]
+	return fmt.Sprintf("%s%c%s%s", tapPrefix, 'f'+index, hash, tapSuffix)
+}
+
+// ContainerTaps returns the names of the tap devices of a container that
+// exist in the current network namespace.
+func ContainerTaps(containerID string) []string {
+	var taps []string
+	for i := 0; i < MaxInterfaces; i++ {
+		name := TapName(containerID, i)
+		_, err := netlink.LinkByName(name)
+		if err == nil {
+			taps = append(taps, name)
+		}
+	}
+	return taps
+}
+
+// isUruncTap checks if an interface is the tap device of a unikernel
+func isUruncTap(name string) bool {
+	return strings.HasPrefix(name, tapPrefix) && strings.HasSuffix(name, tapSuffix)
+}
+
+// uruncTaps returns the names of the tap devices of the unikernels in the
+// current network namespace.
+func uruncTaps() ([]string, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	tapCount := 0
+	var taps []string
 	for _, iface := range ifaces {
-		if strings.Contains(iface.Name, "tap") {
-			tapCount++
+		if isUruncTap(iface.Name) {
+			taps = append(taps, iface.Name)
 		}
 	}
-	if tapCount > 255 {
-		return tapCount, fmt.Errorf("TAP interfaces count higher than 255")
-	}
-	return tapCount, nil
+	return taps, nil
 }
 
 func createTapDevice(name string, mtu int, ownerUID, ownerGID uint32) (netlink.Link, error) {
@@ -262,6 +312,11 @@ func networkSetup(tapName string, ipAddress string, redirectLink netlink.Link, a
 	return newTapDevice, nil
 }
 
+// Cleanup removes the tap device of a unikernel, along with the rules that
+// connect it to the container interface: the tc redirect rules of the
+// dynamic network or the iptables rules of the bridge network.
+// The tap devices and the rules of other unikernels in the network
+// namespace stay intact.
 func Cleanup(tapDevice string) error {
 	netlog.Debug("net cleanup called")
 	ifaces, err := net.Interfaces()
@@ -276,16 +331,31 @@ func Cleanup(tapDevice string) error {
 		netlog.Errorf("Failed to get link %s by name: %v", tapDevice, err)
 		return nil
 	}
-	err = deleteAllTCFilters(tapLink)
-	if err != nil {
-		netlog.Errorf("Failed to delete all TC filters: %v", err)
-		return err
+	if tapLink.Attrs().MasterIndex != 0 {
+		bridgeTapCleanup(tapLink)
+		return nil
 	}
-	err = deleteAllQDiscs(tapLink)
+	// Remove the tc rules of the tap device and the interface that it
+	// mirrors, which the redirect filters of the tap device point to
+	links := []netlink.Link{tapLink}
+	peers, err := redirectPeers(tapLink)
 	if err != nil {
-		netlog.Errorf("Failed to delete all qdiscs: %v", err)
+		netlog.Errorf("Failed to find the interface of %s: %v", tapDevice, err)
 		return err
 	}
+	links = append(links, peers...)
+	for _, link := range links {
+		err = deleteAllTCFilters(link)
+		if err != nil {
+			netlog.Errorf("Failed to delete all TC filters: %v", err)
+			return err
+		}
+		err = deleteIngressQdisc(link)
+		if err != nil {
+			netlog.Errorf("Failed to delete all qdiscs: %v", err)
+			return err
+		}
+	}
 	err = deleteTapDevice(tapLink)
 	if err != nil {
 		netlog.Errorf("Failed to delete link %s: %v", tapDevice, err)
@@ -293,6 +363,34 @@ func Cleanup(tapDevice string) error {
 	return nil
 }
 
+// redirectPeers returns the links that the ingress filters of a link
+// redirect its traffic to.
+func redirectPeers(link netlink.Link) ([]netlink.Link, error) {
+	filters, err := netlink.FilterList(link, netlink.MakeHandle(0xffff, 0))
+	if err != nil {
+		return nil, err
+	}
+	var peers []netlink.Link
+	for _, filter := range filters {
+		u32, ok := filter.(*netlink.U32)
+		if !ok {
+			continue
+		}
+		for _, action := range u32.Actions {
+			mirred, ok := action.(*netlink.MirredAction)
+			if !ok || mirred.Ifindex == link.Attrs().Index {
+				continue
+			}
+			peer, err := netlink.LinkByIndex(mirred.Ifindex)
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, peer)
+		}
+	}
+	return peers, nil
+}
+
 func deleteIngressQdisc(link netlink.Link) error {
 	qdiscs, err := netlink.QdiscList(link)
 	if err != nil {
@@ -366,40 +464,13 @@ func discoverContainerIface() (netlink.Link, error) {
 	return nil, errors.New("no suitable network interface found in namespace")
 }
 
-func deleteAllQDiscs(device netlink.Link) error {
-	err := deleteIngressQdisc(device)
-	if err != nil {
-		return err
-	}
-	device, err = discoverContainerIface()
-	if err != nil {
-		return err
-	}
-	err = deleteIngressQdisc(device)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 func deleteAllTCFilters(device netlink.Link) error {
-	var allFilters []netlink.Filter
 	parent := uint32(netlink.HANDLE_ROOT)
-	tapFilters, err := netlink.FilterList(device, parent)
-	if err != nil {
-		return err
-	}
-	allFilters = append(allFilters, tapFilters...)
-	device, err = discoverContainerIface()
-	if err != nil {
-		return err
-	}
-	ethFilters, err := netlink.FilterList(device, parent)
+	filters, err := netlink.FilterList(device, parent)
 	if err != nil {
 		return err
 	}
-	allFilters = append(allFilters, ethFilters...)
-	for _, filter := range allFilters {
+	for _, filter := range filters {
 		err = netlink.FilterDel(filter)
 		if err != nil {
 			return err
@@ -408,6 +479,42 @@ func deleteAllTCFilters(device netlink.Link) error {
 	return nil
 }
 
+// discoverSecondaryIfaces discovers the rest of the container's network
+// interfaces (e.g. the networks of Multus), besides the interface of the
+// default route, sorted by name. These are the ethernet interfaces with an
+// IPv4 address, except for the tap devices and the bridge of urunc.
+func discoverSecondaryIfaces(primary netlink.Link) ([]netlink.Link, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+	var ifaces []netlink.Link
+	for _, link := range links {
+		attrs := link.Attrs()
+		if attrs == nil || attrs.Index == primary.Attrs().Index {
+			continue
+		}
+		if (attrs.Flags&net.FlagLoopback) != 0 || isUruncTap(attrs.Name) ||
+			link.Type() == "bridge" || link.Type() == "tuntap" {
+			continue
+		}
+		if len(attrs.HardwareAddr) != 6 {
+			netlog.Debugf("skipping interface %s: not an ethernet interface", attrs.Name)
+			continue
+		}
+		addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+		if err != nil || len(addrs) == 0 {
+			netlog.Debugf("skipping interface %s: no IPv4 address configured", attrs.Name)
+			continue
+		}
+		ifaces = append(ifaces, link)
+	}
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].Attrs().Name < ifaces[j].Attrs().Name
+	})
+	return ifaces, nil
+}
+
 func deleteTapDevice(device netlink.Link) error {
 	err := netlink.LinkSetDown(device)
 	if err != nil {
@@ -0,0 +1,393 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/urunc-dev/urunc/internal/constants"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// BridgeName is the bridge that connects the tap devices of the
+	// unikernels in a network namespace, with the bridge network
+	BridgeName = "br0_urunc"
+	// The comment of the iptables rule that forwards the connections to
+	// the IP of the pod to a guest is inboundComment<tap device>
+	inboundComment = "urunc-inbound:"
+	// The alias of the tap device of a guest is guestAlias<IP of the
+	// guest>, so that the addresses in use can be found in the network
+	// namespace
+	guestAlias = "urunc-guest:"
+	// bridgeLockName is the abstract unix socket that serializes the
+	// allocation of the guest addresses. Abstract unix sockets belong to
+	// the network namespace, so only the unikernels of the same pod wait
+	// for each other.
+	bridgeLockName     = "@urunc-bridge-lock"
+	bridgeLockTimeout  = 10 * time.Second
+	bridgeLockInterval = 10 * time.Millisecond
+)
+
+// BridgeNetwork connects the tap devices of all the unikernels in a
+// network namespace to a bridge. Each guest gets the lowest free IP address
+// in a private subnet (constants.BridgeNetworkSubnet), along with a MAC
+// address that derives from it, and reaches the network of the pod through
+// NAT on the container interface. The connections to the IP of the pod get
+// forwarded to the first guest and, once it gets deleted, to the remaining
+// guest with the lowest IP address.
+type BridgeNetwork struct {
+}
+
+// bridgeGuestAddr returns the lowest IP address of subnet that is not in
+// used, along with a MAC address that derives from it. The first address
+// after the network address is the bridge and the last one is the
+// broadcast address.
+func bridgeGuestAddr(subnet *net.IPNet, used map[string]bool) (net.IP, net.HardwareAddr, error) {
+	ones, bits := subnet.Mask.Size()
+	base := binary.BigEndian.Uint32(subnet.IP.To4())
+	for host := uint32(2); host < 1<<(bits-ones)-1; host++ {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, base|host)
+		if used[ip.String()] {
+			continue
+		}
+		// A locally administered unicast address
+		mac := net.HardwareAddr{0x02, 0x75, ip[0], ip[1], ip[2], ip[3]}
+		return ip, mac, nil
+	}
+	return nil, nil, fmt.Errorf("no free address left in %s", subnet)
+}
+
+// lockBridge serializes the allocation of the guest addresses in the
+// network namespace, by binding to bridgeLockName. The lock gets released
+// with the returned function, or when the process exits.
+func lockBridge() (func(), error) {
+	addr := &net.UnixAddr{Name: bridgeLockName, Net: "unix"}
+	deadline := time.Now().Add(bridgeLockTimeout)
+	for {
+		l, err := net.ListenUnix("unix", addr)
+		if err == nil {
+			return func() { _ = l.Close() }, nil
+		}
+		if !errors.Is(err, unix.EADDRINUSE) || time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock the guest addresses of %s: %w", BridgeName, err)
+		}
+		time.Sleep(bridgeLockInterval)
+	}
+}
+
+// bridgeGuests returns the tap devices of the guests in the network
+// namespace, along with the IP address of each guest.
+func bridgeGuests() (map[string]net.IP, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+	guests := map[string]net.IP{}
+	for _, link := range links {
+		ip, ok := strings.CutPrefix(link.Attrs().Alias, guestAlias)
+		if !ok || net.ParseIP(ip) == nil {
+			continue
+		}
+		guests[link.Attrs().Name] = net.ParseIP(ip)
+	}
+	return guests, nil
+}
+
+// allocGuestAddr allocates the lowest free address of the bridge network
+// to the guest behind the tap device and records it in the alias of the
+// tap device. The address gets released along with the tap device.
+func allocGuestAddr(tap netlink.Link) (net.IP, net.HardwareAddr, error) {
+	_, subnet, err := net.ParseCIDR(constants.BridgeNetworkSubnet)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock, err := lockBridge()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	guests, err := bridgeGuests()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list the guests of %s: %w", BridgeName, err)
+	}
+	used := map[string]bool{}
+	for _, ip := range guests {
+		used[ip.String()] = true
+	}
+	ip, mac, err := bridgeGuestAddr(subnet, used)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = netlink.LinkSetAlias(tap, guestAlias+ip.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("LinkSetAlias(%s) failed: %w", tap.Attrs().Name, err)
+	}
+	return ip, mac, nil
+}
+
+// setupBridge creates the bridge of the network namespace, unless another
+// unikernel already created it, and brings it up with the gateway IP of
+// the guests.
+func setupBridge(mtu int) (netlink.Link, error) {
+	bridgeAttrs := netlink.NewLinkAttrs()
+	bridgeAttrs.Name = BridgeName
+	bridgeAttrs.MTU = mtu
+	err := netlink.LinkAdd(&netlink.Bridge{LinkAttrs: bridgeAttrs})
+	if err != nil && !errors.Is(err, unix.EEXIST) {
+		return nil, fmt.Errorf("failed to create bridge %s: %w", BridgeName, err)
+	}
+	bridge, err := netlink.LinkByName(BridgeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bridge %s: %w", BridgeName, err)
+	}
+	_, subnet, err := net.ParseCIDR(constants.BridgeNetworkSubnet)
+	if err != nil {
+		return nil, err
+	}
+	gateway := &netlink.Addr{IPNet: &net.IPNet{
+		IP:   net.ParseIP(constants.BridgeNetworkGatewayIP),
+		Mask: subnet.Mask,
+	}}
+	err = netlink.AddrReplace(bridge, gateway)
+	if err != nil {
+		return nil, fmt.Errorf("AddrReplace(%s, %s) failed: %w", BridgeName, gateway, err)
+	}
+	err = netlink.LinkSetUp(bridge)
+	if err != nil {
+		return nil, fmt.Errorf("LinkSetUp(%s) failed: %w", BridgeName, err)
+	}
+	return bridge, nil
+}
+
+// inboundTap returns the tap device of the first rule in rules, the output
+// of iptables -S, that forwards the connections to the IP of the pod to a
+// guest. It returns an empty string if there is no such rule.
+func inboundTap(rules string) string {
+	for _, rule := range strings.Split(rules, "\n") {
+		fields := strings.Fields(rule)
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+		for i := 0; i+1 < len(fields); i++ {
+			comment := strings.Trim(fields[i+1], `"`)
+			if fields[i] == "--comment" && strings.HasPrefix(comment, inboundComment) {
+				return strings.TrimPrefix(comment, inboundComment)
+			}
+		}
+	}
+	return ""
+}
+
+// forwardInbound forwards the connections to the IP of the pod to the
+// guest, unless they already get forwarded to another guest. It returns
+// true if they get forwarded to this guest.
+func forwardInbound(iface string, podIP net.IP, tapName string, guestIP net.IP) (bool, error) {
+	rules, err := runIptables("-t", "nat", "-S", "PREROUTING")
+	if err != nil {
+		return false, err
+	}
+	if tap := inboundTap(rules); tap != "" {
+		return tap == tapName, nil
+	}
+	err = appendIptablesRule("nat", "PREROUTING", "-i", iface, "-d", podIP.String(),
+		"-m", "comment", "--comment", inboundComment+tapName,
+		"-j", "DNAT", "--to-destination", guestIP.String())
+	if err != nil {
+		return false, err
+	}
+	// Another unikernel might have appended its rule at the same time.
+	// The first rule of the chain wins and the rest get deleted.
+	rules, err = runIptables("-t", "nat", "-S", "PREROUTING")
+	if err != nil {
+		return false, err
+	}
+	if inboundTap(rules) != tapName {
+		return false, bridgeCleanup(tapName)
+	}
+	return true, nil
+}
+
+// podIPv4 returns the IPv4 address of the container interface
+func podIPv4(podLink netlink.Link) (net.IP, error) {
+	addrs, err := netlink.AddrList(podLink, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the addresses of %s: %w", podLink.Attrs().Name, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("interface %s has no IPv4 address", podLink.Attrs().Name)
+	}
+	return addrs[0].IP, nil
+}
+
+// bridgeCleanup deletes the rule that forwards the connections to the IP
+// of the pod to the guest behind the tap device, if any.
+func bridgeCleanup(tapName string) error {
+	return deleteIptablesRules("nat", "PREROUTING", inboundComment+tapName)
+}
+
+// inboundSuccessor returns the tap device of the guest that takes over the
+// connections to the IP of the pod, which is the guest with the lowest IP
+// address. It returns an empty string if there are no guests.
+func inboundSuccessor(guests map[string]net.IP) string {
+	successor := ""
+	for tap, ip := range guests {
+		if successor == "" || bytes.Compare(ip.To16(), guests[successor].To16()) < 0 {
+			successor = tap
+		}
+	}
+	return successor
+}
+
+// handOffInbound forwards the connections to the IP of the pod to one of
+// the remaining guests, after the guest that received them got deleted.
+func handOffInbound() error {
+	guests, err := bridgeGuests()
+	if err != nil {
+		return err
+	}
+	tap := inboundSuccessor(guests)
+	if tap == "" {
+		return nil
+	}
+	podLink, err := discoverContainerIface()
+	if err != nil {
+		return err
+	}
+	podIP, err := podIPv4(podLink)
+	if err != nil {
+		return err
+	}
+	inbound, err := forwardInbound(podLink.Attrs().Name, podIP, tap, guests[tap])
+	if err != nil {
+		return err
+	}
+	netlog.Debugf("connections to %s handed off to %s (ip=%s, inbound=%v)", podIP, tap, guests[tap], inbound)
+	return nil
+}
+
+// bridgeTapCleanup removes the tap device of a guest of the bridge network
+// along with its iptables rules. If the guest received the connections to
+// the IP of the pod, another guest takes them over.
+func bridgeTapCleanup(tapLink netlink.Link) {
+	tapName := tapLink.Attrs().Name
+	rules, err := runIptables("-t", "nat", "-S", "PREROUTING")
+	if err != nil {
+		netlog.Errorf("Failed to list the iptables rules of %s: %v", tapName, err)
+	}
+	inbound := err == nil && inboundTap(rules) == tapName
+	err = bridgeCleanup(tapName)
+	if err != nil {
+		netlog.Errorf("Failed to delete the iptables rules of %s: %v", tapName, err)
+	}
+	err = deleteTapDevice(tapLink)
+	if err != nil {
+		netlog.Errorf("Failed to delete link %s: %v", tapName, err)
+	}
+	if !inbound {
+		return
+	}
+	err = handOffInbound()
+	if err != nil {
+		netlog.Errorf("Failed to forward the connections of the pod to another guest: %v", err)
+	}
+}
+
+// NetworkSetup creates the tap device of the container, attaches it to the
+// bridge of the network namespace and sets up NAT between the private
+// subnet of the guests and the container interface. The secondary
+// interfaces of the container are not mirrored, since the guests share
+// them.
+func (n BridgeNetwork) NetworkSetup(containerID string, _ bool, uid uint32, gid uint32) ([]UnikernelNetworkInfo, error) {
+	podLink, err := discoverContainerIface()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find container interface, (unikernel may have been spawned using ctr): %w", err)
+	}
+	netlog.Debugf("found interface %s (index=%d)", podLink.Attrs().Name, podLink.Attrs().Index)
+
+	bridge, err := setupBridge(podLink.Attrs().MTU)
+	if err != nil {
+		return nil, err
+	}
+
+	newTapName := TapName(containerID, 0)
+	info, err := attachGuest(newTapName, podLink, bridge, uid, gid)
+	if err != nil {
+		// Remove the tap device and the iptables rules of the guest,
+		// which might have been set up half-way
+		cleanupErr := Cleanup(newTapName)
+		if cleanupErr != nil {
+			netlog.Errorf("Failed to clean up %s: %v", newTapName, cleanupErr)
+		}
+		return nil, err
+	}
+	return []UnikernelNetworkInfo{info}, nil
+}
+
+// attachGuest creates the tap device of a guest, attaches it to the bridge,
+// allocates the address of the guest and forwards the connections to the
+// IP of the pod to it, unless they get forwarded to another guest.
+func attachGuest(newTapName string, podLink netlink.Link, bridge netlink.Link, uid uint32, gid uint32) (UnikernelNetworkInfo, error) {
+	newTapDevice, err := networkSetup(newTapName, "", podLink, false, uid, gid)
+	if err != nil {
+		return UnikernelNetworkInfo{}, fmt.Errorf("networkSetup(%s) failed: %w", newTapName, err)
+	}
+	err = netlink.LinkSetMaster(newTapDevice, bridge)
+	if err != nil {
+		return UnikernelNetworkInfo{}, fmt.Errorf("LinkSetMaster(%s, %s) failed: %w", newTapName, BridgeName, err)
+	}
+	guestIP, guestMAC, err := allocGuestAddr(newTapDevice)
+	if err != nil {
+		return UnikernelNetworkInfo{}, fmt.Errorf("failed to allocate the address of the guest of %s: %w", newTapName, err)
+	}
+
+	err = setNATRule(podLink.Attrs().Name, constants.BridgeNetworkSubnet)
+	if err != nil {
+		return UnikernelNetworkInfo{}, err
+	}
+	podIP, err := podIPv4(podLink)
+	if err != nil {
+		return UnikernelNetworkInfo{}, err
+	}
+	inbound, err := forwardInbound(podLink.Attrs().Name, podIP, newTapName, guestIP)
+	if err != nil {
+		return UnikernelNetworkInfo{}, fmt.Errorf("failed to forward the connections of the pod to %s: %w", guestIP, err)
+	}
+	netlog.Debugf("tap device %s attached to %s (ip=%s, mac=%s, inbound=%v)",
+		newTapName, BridgeName, guestIP, guestMAC, inbound)
+
+	_, subnet, err := net.ParseCIDR(constants.BridgeNetworkSubnet)
+	if err != nil {
+		return UnikernelNetworkInfo{}, err
+	}
+	return UnikernelNetworkInfo{
+		TapDevice: newTapName,
+		EthDevice: Interface{
+			IP:             guestIP.String(),
+			DefaultGateway: constants.BridgeNetworkGatewayIP,
+			Mask:           net.IP(subnet.Mask).String(),
+			Interface:      podLink.Attrs().Name,
+			MAC:            guestMAC.String(),
+		},
+	}, nil
+}
@@ -16,29 +16,28 @@ package network
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
+
+	"github.com/vishvananda/netlink"
 )
 
 type DynamicNetwork struct {
 }
 
-// NetworkSetup checks if any tap device is available in the current netns. If it is, it assumes a running unikernel
-// is present in the current netns and returns an error, because network functionality for more than one unikernels
-// is not yet implemented.
-// If no TAP devices are available in the current netns, it creates a new tap device and
-// sets TC rules between the veth interface and the tap device inside the namespace.
-//
-// FIXME: CUrrently only one tap device per netns can provide functional networking. We need to find a proper way to handle networking
-// for multiple unikernels in the same pod/network namespace.
-// See: https://github.com/urunc-dev/urunc/issues/13
-func (n DynamicNetwork) NetworkSetup(uid uint32, gid uint32) (*UnikernelNetworkInfo, error) {
-	tapIndex, err := getTapIndex()
+// NetworkSetup checks if the tap device of another unikernel exists in the current netns. If it does, it returns an
+// error, because the TC rules redirect all the traffic of the veth interface to a single tap device. The unikernels
+// that share a network namespace need the bridge network instead (see BridgeNetwork).
+// If no other unikernel is present in the current netns, it creates a new tap device for each interface of the
+// container (only for the interface of the default route, unless multiNIC is set) and sets TC rules between the
+// interface and its tap device inside the namespace.
+func (n DynamicNetwork) NetworkSetup(containerID string, multiNIC bool, uid uint32, gid uint32) ([]UnikernelNetworkInfo, error) {
+	taps, err := uruncTaps()
 	if err != nil {
-		return nil, fmt.Errorf("getTapIndex failed: %w", err)
+		return nil, fmt.Errorf("failed to list the tap devices: %w", err)
 	}
-	if tapIndex > 0 {
-		return nil, fmt.Errorf("unsupported operation: can't spawn multiple unikernels in the same network namespace")
+	if len(taps) > 0 {
+		return nil, fmt.Errorf("unsupported operation: can't spawn multiple unikernels in the same network namespace "+
+			"with the redirect network mode (found %s), use the bridge network mode instead", strings.Join(taps, ", "))
 	}
 
 	redirectLink, err := discoverContainerIface()
@@ -46,23 +45,59 @@ func (n DynamicNetwork) NetworkSetup(uid uint32, gid uint32) (*UnikernelNetworkI
 		return nil, fmt.Errorf("failed to find container interface, (unikernel may have been spawned using ctr): %w", err)
 	}
 	netlog.Debugf("found interface %s (index=%d)", redirectLink.Attrs().Name, redirectLink.Attrs().Index)
+	ifaces := []netlink.Link{redirectLink}
+	if multiNIC {
+		secondary, err := discoverSecondaryIfaces(redirectLink)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the secondary container interfaces: %w", err)
+		}
+		ifaces = append(ifaces, secondary...)
+	}
+	if len(ifaces) > MaxInterfaces {
+		netlog.Warnf("mirroring only %d out of the %d container interfaces", MaxInterfaces, len(ifaces))
+		ifaces = ifaces[:MaxInterfaces]
+	}
 
-	newTapName := strings.ReplaceAll(DefaultTap, "X", strconv.Itoa(tapIndex))
-	netlog.Debugf("creating tap device %s", newTapName)
+	infos := make([]UnikernelNetworkInfo, 0, len(ifaces))
+	for i, iface := range ifaces {
+		info, err := mirrorInterface(TapName(containerID, i), iface, uid, gid)
+		if err != nil {
+			// Remove the tap devices and the tc rules of the interfaces
+			// that got mirrored, including the one that failed half-way
+			for _, tap := range ContainerTaps(containerID) {
+				cleanupErr := Cleanup(tap)
+				if cleanupErr != nil {
+					netlog.Errorf("Failed to clean up %s: %v", tap, cleanupErr)
+				}
+			}
+			return nil, err
+		}
+		// Only the interface of the default route has a gateway
+		if i > 0 {
+			info.EthDevice.DefaultGateway = ""
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
 
+// mirrorInterface creates a tap device and redirects the traffic of the
+// container interface to it and vice versa.
+func mirrorInterface(newTapName string, redirectLink netlink.Link, uid uint32, gid uint32) (UnikernelNetworkInfo, error) {
+	netlog.Debugf("creating tap device %s", newTapName)
 	newTapDevice, err := networkSetup(newTapName, "", redirectLink, true, uid, gid)
 	if err != nil {
-		return nil, fmt.Errorf("networkSetup(%s) failed: %w", newTapName, err)
+		return UnikernelNetworkInfo{}, fmt.Errorf("networkSetup(%s) failed: %w", newTapName, err)
 	}
 	netlog.Debugf("tap device created: %s", newTapDevice.Attrs().Name)
 
 	netlog.Debugf("fetching info for %s", redirectLink.Attrs().Name)
 	ifInfo, err := getInterfaceInfo(redirectLink.Attrs().Name)
 	if err != nil {
-		return nil, fmt.Errorf("getInterfaceInfo(%s) failed: %w", redirectLink.Attrs().Name, err)
+		return UnikernelNetworkInfo{}, fmt.Errorf("getInterfaceInfo(%s) failed: %w", redirectLink.Attrs().Name, err)
 	}
 
-	return &UnikernelNetworkInfo{
+	return UnikernelNetworkInfo{
 		TapDevice: newTapDevice.Attrs().Name,
 		EthDevice: ifInfo,
 	}, nil
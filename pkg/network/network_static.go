@@ -15,11 +15,7 @@
 package network
 
 import (
-	"bytes"
 	"fmt"
-	"os"
-	"os/exec"
-	"strings"
 
 	"github.com/urunc-dev/urunc/internal/constants"
 )
@@ -29,67 +25,10 @@ var StaticIPAddr = fmt.Sprintf("%s/24", constants.StaticNetworkTapIP)
 type StaticNetwork struct {
 }
 
-// Apply the following rule:
-// iptables -t nat -A POSTROUTING -o <IF> -s <IP> -j MASQUERADE --wait 1
-// and write 1 to /proc/sys/net/ipv4/ip_forward to enable IP forwarding.
-func setNATRule(iface string, sourceIP string) error {
-	var args []string
-	var stdout, stderr bytes.Buffer
-
-	path, err := exec.LookPath("iptables")
-	if err != nil {
-		return err
-	}
-
-	file, err := os.OpenFile("/proc/sys/net/ipv4/ip_forward", os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open /proc/sys/net/ipv4/ip_forward: %w", err)
-	}
-	defer file.Close()
-
-	_, err = file.WriteString("1")
-	if err != nil {
-		return fmt.Errorf("failed to enable IP forwarding: %w", err)
-	}
-	netlog.Debug("Enabled IP forwarding")
-
-	args = append(args, path)
-	args = append(args, "-t")
-	args = append(args, "nat")
-	args = append(args, "-A")
-	args = append(args, "POSTROUTING")
-	args = append(args, "-s")
-	args = append(args, sourceIP)
-	args = append(args, "-o")
-	args = append(args, iface)
-	args = append(args, "-j")
-	args = append(args, "MASQUERADE")
-	args = append(args, "--wait")
-	args = append(args, "1")
-
-	cmd := exec.Cmd{
-		Path:   path,
-		Args:   args,
-		Stdout: &stdout,
-		Stderr: &stderr,
-	}
-	err = cmd.Run()
-	if err != nil {
-		switch err.(type) {
-		case *exec.ExitError:
-			return fmt.Errorf("iptables command %s failed: %s", cmd.String(), stderr.String())
-		default:
-			return err
-		}
-	}
-
-	netlog.Debug("Applied iptables rule for NAT")
-
-	return nil
-}
-
-func (n StaticNetwork) NetworkSetup(uid uint32, gid uint32) (*UnikernelNetworkInfo, error) {
-	newTapName := strings.ReplaceAll(DefaultTap, "X", "0")
+// NetworkSetup creates a tap device with a static IP for the container. The
+// secondary interfaces of the container are not mirrored.
+func (n StaticNetwork) NetworkSetup(containerID string, _ bool, uid uint32, gid uint32) ([]UnikernelNetworkInfo, error) {
+	newTapName := TapName(containerID, 0)
 	addTCRules := false
 	redirectLink, err := discoverContainerIface()
 	if err != nil {
@@ -104,14 +43,14 @@ func (n StaticNetwork) NetworkSetup(uid uint32, gid uint32) (*UnikernelNetworkIn
 	if err != nil {
 		return nil, err
 	}
-	return &UnikernelNetworkInfo{
+	return []UnikernelNetworkInfo{{
 		TapDevice: newTapDevice.Attrs().Name,
 		EthDevice: Interface{
 			IP:             constants.StaticNetworkUnikernelIP,
 			DefaultGateway: constants.StaticNetworkTapIP,
 			Mask:           "255.255.255.0",
-			Interface:      redirectLink.Attrs().Name, // or tap0_urunc?
+			Interface:      redirectLink.Attrs().Name,
 			MAC:            redirectLink.Attrs().HardwareAddr.String(),
 		},
-	}, nil
+	}}, nil
 }
@@ -15,9 +15,14 @@
 package network
 
 import (
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/internal/constants"
+	"golang.org/x/sys/unix"
 )
 
 func TestNewNetworkManager(t *testing.T) {
@@ -36,6 +41,11 @@ func TestNewNetworkManager(t *testing.T) {
 			networkType: "dynamic",
 			expectedErr: false,
 		},
+		{
+			name:        "bridge network manager",
+			networkType: "bridge",
+			expectedErr: false,
+		},
 		{
 			name:        "invalid network type",
 			networkType: "invalid",
@@ -56,3 +66,169 @@ func TestNewNetworkManager(t *testing.T) {
 		})
 	}
 }
+
+func TestTapName(t *testing.T) {
+	t.Run("tap name fits in an interface name", func(t *testing.T) {
+		t.Parallel()
+		name := TapName("4f2d6a1c0e8b9d7f5a3c1e2b4d6f8a0c9e7b5d3f1a2c4e6b8d0f9a7c5e3b1d2f", MaxInterfaces-1)
+		assert.LessOrEqual(t, len(name), unix.IFNAMSIZ-1)
+		assert.True(t, isUruncTap(name))
+	})
+
+	t.Run("tap name is unique per container", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, TapName("app", 0), TapName("app", 0))
+		assert.NotEqual(t, TapName("app", 0), TapName("sidecar", 0))
+	})
+
+	t.Run("tap name is unique per interface", func(t *testing.T) {
+		t.Parallel()
+		names := map[string]bool{}
+		for i := 0; i < MaxInterfaces; i++ {
+			names[TapName("app", i)] = true
+		}
+		assert.Len(t, names, MaxInterfaces)
+	})
+
+	t.Run("first tap name has 24 bits of the hash", func(t *testing.T) {
+		t.Parallel()
+		// The first 3 bytes of the SHA-256 of "app"
+		assert.Equal(t, "tapa172ce_urunc", TapName("app", 0))
+	})
+
+	t.Run("secondary tap names differ from first tap names", func(t *testing.T) {
+		t.Parallel()
+		for i := 1; i < MaxInterfaces; i++ {
+			name := TapName("app", i)
+			assert.NotContains(t, "0123456789abcdef", name[len(tapPrefix):len(tapPrefix)+1])
+		}
+	})
+
+	t.Run("only urunc tap devices are recognized", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, isUruncTap("tap0_urunc"))
+		assert.False(t, isUruncTap("tap0"))
+		assert.False(t, isUruncTap(BridgeName))
+		assert.False(t, isUruncTap("eth0"))
+	})
+}
+
+func TestBridgeGuestAddr(t *testing.T) {
+	_, subnet, err := net.ParseCIDR(constants.BridgeNetworkSubnet)
+	assert.NoError(t, err)
+
+	t.Run("first guest", func(t *testing.T) {
+		t.Parallel()
+		ip, mac, err := bridgeGuestAddr(subnet, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "172.16.0.2", ip.String())
+		assert.Equal(t, "02:75:ac:10:00:02", mac.String())
+	})
+
+	t.Run("lowest free address", func(t *testing.T) {
+		t.Parallel()
+		used := map[string]bool{"172.16.0.2": true, "172.16.0.4": true}
+		ip, mac, err := bridgeGuestAddr(subnet, used)
+		assert.NoError(t, err)
+		assert.Equal(t, "172.16.0.3", ip.String())
+		assert.Equal(t, "02:75:ac:10:00:03", mac.String())
+	})
+
+	t.Run("addresses beyond the first 256", func(t *testing.T) {
+		t.Parallel()
+		used := map[string]bool{}
+		for host := 2; host < 0x1234; host++ {
+			used[net.IPv4(172, 16, byte(host>>8), byte(host)).String()] = true
+		}
+		ip, mac, err := bridgeGuestAddr(subnet, used)
+		assert.NoError(t, err)
+		assert.Equal(t, "172.16.18.52", ip.String())
+		assert.Equal(t, "02:75:ac:10:12:34", mac.String())
+	})
+
+	t.Run("addresses are locally administered unicast", func(t *testing.T) {
+		t.Parallel()
+		_, mac, err := bridgeGuestAddr(subnet, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, byte(0x02), mac[0]&0x03)
+	})
+
+	t.Run("full subnet", func(t *testing.T) {
+		t.Parallel()
+		// Only 10.0.0.2 is left, besides the bridge and the broadcast
+		_, small, err := net.ParseCIDR("10.0.0.0/30")
+		assert.NoError(t, err)
+		ip, _, err := bridgeGuestAddr(small, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.2", ip.String())
+		_, _, err = bridgeGuestAddr(small, map[string]bool{"10.0.0.2": true})
+		assert.Error(t, err)
+	})
+}
+
+func TestInboundSuccessor(t *testing.T) {
+	t.Run("guest with the lowest address", func(t *testing.T) {
+		t.Parallel()
+		guests := map[string]net.IP{
+			"tapa_urunc": net.ParseIP("172.16.1.2"),
+			"tapb_urunc": net.ParseIP("172.16.0.9"),
+			"tapc_urunc": net.ParseIP("172.16.0.10"),
+		}
+		assert.Equal(t, "tapb_urunc", inboundSuccessor(guests))
+	})
+
+	t.Run("no guests", func(t *testing.T) {
+		t.Parallel()
+		assert.Empty(t, inboundSuccessor(nil))
+	})
+}
+
+func TestLockBridge(t *testing.T) {
+	unlock, err := lockBridge()
+	assert.NoError(t, err)
+	locked := make(chan struct{})
+	go func() {
+		unlockAgain, err := lockBridge()
+		assert.NoError(t, err)
+		close(locked)
+		unlockAgain()
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the lock got acquired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
+
+func TestHasComment(t *testing.T) {
+	t.Run("comment is matched with or without quotes", func(t *testing.T) {
+		t.Parallel()
+		rule := strings.Fields(`-A PREROUTING -i eth0 -m comment --comment "urunc-inbound:tap0_urunc" -j DNAT --to-destination 172.16.0.5`)
+		assert.True(t, hasComment(rule, "urunc-inbound:tap0_urunc"))
+		assert.False(t, hasComment(rule, "urunc-inbound:tap1_urunc"))
+
+		rule = strings.Fields(`-A PREROUTING -i eth0 -m comment --comment urunc-inbound:tap0_urunc -j DNAT`)
+		assert.True(t, hasComment(rule, "urunc-inbound:tap0_urunc"))
+		assert.False(t, hasComment(strings.Fields("-A PREROUTING -i eth0 -j DNAT"), "urunc-inbound:tap0_urunc"))
+	})
+}
+
+func TestInboundTap(t *testing.T) {
+	t.Run("first inbound rule wins", func(t *testing.T) {
+		t.Parallel()
+		rules := `-P PREROUTING ACCEPT
+-A PREROUTING -m addrtype --dst-type LOCAL -j DOCKER
+-A PREROUTING -d 10.0.0.5/32 -i eth0 -m comment --comment "urunc-inbound:tap0_urunc" -j DNAT --to-destination 172.16.0.5
+-A PREROUTING -d 10.0.0.5/32 -i eth0 -m comment --comment urunc-inbound:tap1_urunc -j DNAT --to-destination 172.16.0.6
+`
+		assert.Equal(t, "tap0_urunc", inboundTap(rules))
+	})
+
+	t.Run("no inbound rule", func(t *testing.T) {
+		t.Parallel()
+		assert.Empty(t, inboundTap("-P PREROUTING ACCEPT\n-A PREROUTING -m comment --comment other -j ACCEPT\n"))
+		assert.Empty(t, inboundTap(""))
+	})
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+)
+
+// State annotation with the accelerator that the monitor uses (e.g. tcg
+// for Qemu without KVM)
+const annotAccel = "urunc_state.accel"
+
+// recordAccel resolves the accelerator of the monitor and stores it in the
+// state annotations, so that reexec and later invocations of urunc agree
+// on it. Only Qemu can run without KVM and hence, it is a no-op for the
+// rest of the monitors.
+func (u *Unikontainer) recordAccel() error {
+	vmmType := u.State.Annotations[annotHypervisor]
+	if hypervisors.VmmType(vmmType) != hypervisors.QemuVmm {
+		return nil
+	}
+	accel, err := hypervisors.ResolveQemuAccel(u.UruncCfg.Monitors[vmmType].Accel)
+	if err != nil {
+		return err
+	}
+	uniklog.WithField("accel", accel).Info("Selected accelerator for qemu")
+	u.State.Annotations[annotAccel] = accel
+	return nil
+}
+
+// machine returns the machine type of the VM. The machine type of Qemu can
+// be overridden per container.
+func (u *Unikontainer) machine(vmmType string) string {
+	if machine := u.Spec.Annotations[annotQemuMachine]; machine != "" && hypervisors.VmmType(vmmType) == hypervisors.QemuVmm {
+		return machine
+	}
+	return u.UruncCfg.Monitors[vmmType].Machine
+}
+
+// Accel returns the accelerator that the monitor uses, or an empty string
+// if it was not recorded (i.e. KVM).
+func (u *Unikontainer) Accel() string {
+	return u.State.Annotations[annotAccel]
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+)
+
+func TestRecordAccel(t *testing.T) {
+	t.Run("qemu with tcg", func(t *testing.T) {
+		t.Parallel()
+		u := newMonitorSocketUnikontainer(t.TempDir(), string(hypervisors.QemuVmm))
+		qemuCfg := u.UruncCfg.Monitors["qemu"]
+		qemuCfg.Accel = hypervisors.QemuAccelTCG
+		u.UruncCfg.Monitors["qemu"] = qemuCfg
+		assert.NoError(t, u.recordAccel())
+		assert.Equal(t, hypervisors.QemuAccelTCG, u.Accel())
+	})
+
+	t.Run("qemu defaults to kvm", func(t *testing.T) {
+		t.Parallel()
+		u := newMonitorSocketUnikontainer(t.TempDir(), string(hypervisors.QemuVmm))
+		assert.NoError(t, u.recordAccel())
+		assert.Equal(t, hypervisors.QemuAccelKVM, u.Accel())
+	})
+
+	t.Run("unknown accelerator", func(t *testing.T) {
+		t.Parallel()
+		u := newMonitorSocketUnikontainer(t.TempDir(), string(hypervisors.QemuVmm))
+		qemuCfg := u.UruncCfg.Monitors["qemu"]
+		qemuCfg.Accel = "hvf"
+		u.UruncCfg.Monitors["qemu"] = qemuCfg
+		assert.ErrorIs(t, u.recordAccel(), hypervisors.ErrUnknownAccel)
+		assert.Empty(t, u.Accel())
+	})
+
+	t.Run("other monitors", func(t *testing.T) {
+		t.Parallel()
+		u := newMonitorSocketUnikontainer(t.TempDir(), string(hypervisors.FirecrackerVmm))
+		assert.NoError(t, u.recordAccel())
+		assert.Empty(t, u.Accel())
+	})
+}
@@ -221,7 +221,7 @@ func getBlockVolumes(monRootfs string, mounts []specs.Mount, ukernel types.Unike
 		if err != nil {
 			return nil, err
 		}
-		if ukernel.SupportsFS(mInfo.FsType) {
+		if ukernel.Capabilities().SupportsBlockFS(mInfo.FsType) {
 			err = mount.Unmount(mInfo.MountPoint)
 			if err != nil {
 				return nil, err
@@ -0,0 +1,202 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/unikernels"
+)
+
+var ErrUnsupported = errors.New("unsupported configuration")
+
+// capabilityCheck holds the capabilities of the monitor and the guest of a
+// container, along with the annotations that request features from them.
+type capabilityCheck struct {
+	vmmType       string
+	vmm           types.VMMCapabilities
+	unikernelType string
+	unikernel     types.UnikernelCapabilities
+	annot         map[string]string // The annotations of the state
+	specAnnot     map[string]string // The annotations of the spec
+	arch          string
+	machine       string                    // The machine type of the VM (e.g. microvm)
+	solo5         *unikernels.Solo5Manifest // The manifest of Solo5 guests
+	hugepages     *guestHugepages           // The hugepages that the container requests
+}
+
+// checkCapabilities verifies that the monitor and the guest of the
+// container support the features that the container requests. It is
+// called when the container gets created, so that unsupported
+// combinations fail before the container starts.
+func (u *Unikontainer) checkCapabilities() error {
+	vmmType := u.State.Annotations[annotHypervisor]
+	vmm, err := hypervisors.NewVMM(hypervisors.VmmType(vmmType), u.UruncCfg.Monitors)
+	if err != nil {
+		return err
+	}
+	unikernelType := u.State.Annotations[annotType]
+	unikernel, err := unikernels.New(unikernelType)
+	if err != nil {
+		return err
+	}
+	c := capabilityCheck{
+		vmmType:       vmmType,
+		vmm:           vmm.Capabilities(),
+		unikernelType: unikernelType,
+		unikernel:     unikernel.Capabilities(),
+		annot:         u.State.Annotations,
+		specAnnot:     u.Spec.Annotations,
+		arch:          runtime.GOARCH,
+		machine:       u.machine(vmmType),
+	}
+	c.hugepages, err = getGuestHugepages(u.Spec.Linux.Resources)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnsupported, err)
+	}
+	if vmmType == string(hypervisors.HvtVmm) || vmmType == string(hypervisors.SptVmm) {
+		c.solo5, err = u.readSolo5Manifest()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUnsupported, err)
+		}
+	}
+	return c.check()
+}
+
+func (c capabilityCheck) check() error {
+	if !c.vmm.SupportsArch(c.arch) {
+		return fmt.Errorf("%w: monitor %s does not support %s", ErrUnsupported, c.vmmType, c.arch)
+	}
+	if !c.unikernel.SupportsArch(c.arch) {
+		return fmt.Errorf("%w: %s guests do not support %s", ErrUnsupported, c.unikernelType, c.arch)
+	}
+
+	if block := c.annot[annotBlock]; block != "" {
+		if !c.unikernel.Block {
+			return fmt.Errorf("%w: block device %s requested, but %s guests do not support block devices", ErrUnsupported, block, c.unikernelType)
+		}
+		if !c.vmm.Block {
+			return fmt.Errorf("%w: block device %s requested, but monitor %s does not support block devices", ErrUnsupported, block, c.vmmType)
+		}
+	}
+
+	if c.vmmType == string(hypervisors.QemuVmm) && c.machine == hypervisors.QemuMachineMicrovm && !c.unikernel.Microvm {
+		return fmt.Errorf("%w: %s guests can not use the devices of the microvm machine, which has no PCI bus", ErrUnsupported, c.unikernelType)
+	}
+
+	if c.hugepages != nil && !c.vmm.Hugepages {
+		return fmt.Errorf("%w: hugepages requested, but monitor %s can not back the guest memory with hugepages", ErrUnsupported, c.vmmType)
+	}
+
+	err := c.checkContainerRootfs()
+	if err != nil {
+		return err
+	}
+	err = c.checkSolo5Manifest()
+	if err != nil {
+		return err
+	}
+	return c.checkVAccel()
+}
+
+// readSolo5Manifest reads the manifest of the unikernel binary from the
+// container's rootfs.
+func (u *Unikontainer) readSolo5Manifest() (*unikernels.Solo5Manifest, error) {
+	rootfsDir, err := resolveAgainstBase(filepath.Clean(u.State.Bundle), filepath.Clean(u.Spec.Root.Path))
+	if err != nil {
+		return nil, err
+	}
+	binary := u.State.Annotations[annotBinary]
+	manifest, err := unikernels.ReadSolo5Manifest(filepath.Join(rootfsDir, binary))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Solo5 manifest of %s: %w", binary, err)
+	}
+	return manifest, nil
+}
+
+// checkSolo5Manifest checks the devices that a Solo5 guest declares in
+// its manifest against the devices that the container requests. Whether
+// the devices match exactly depends on the network and the rootfs of the
+// container and hence, it is only checked when the container starts.
+func (c capabilityCheck) checkSolo5Manifest() error {
+	if c.solo5 == nil {
+		return nil
+	}
+	nets := c.solo5.DeviceNames(unikernels.Solo5NetDevice)
+	if len(nets) > 1 && (!c.vmm.MultiNIC || !c.unikernel.MultiNIC) {
+		return fmt.Errorf("%w: the unikernel declares net devices %s, but only a single net device can be attached",
+			ErrUnsupported, strings.Join(nets, ", "))
+	}
+	block := c.annot[annotBlock]
+	if block != "" && len(c.solo5.DeviceNames(unikernels.Solo5BlockDevice)) == 0 {
+		return fmt.Errorf("%w: block device %s requested, but the unikernel declares no block devices in its Solo5 manifest",
+			ErrUnsupported, block)
+	}
+	return nil
+}
+
+// checkContainerRootfs checks if the container's rootfs can be passed to
+// the guest, when the guest requests it and there is no other rootfs.
+// Whether a block based rootfs can actually be used depends on the
+// snapshotter and hence, it is only checked when the container starts.
+func (c capabilityCheck) checkContainerRootfs() error {
+	rs := &rootfsSelector{annot: c.annot}
+	if !rs.shouldMountContainerRootfs() || c.annot[annotInitrd] != "" {
+		return nil
+	}
+	if c.annot[annotBlock] != "" && c.annot[annotBlockMntPoint] == "/" {
+		return nil
+	}
+	if c.unikernel.Block && c.vmm.Block {
+		return nil
+	}
+	if c.unikernel.Virtiofs && c.vmm.Virtiofs {
+		return nil
+	}
+	if c.unikernel.NinePfs && c.vmm.NinePfs {
+		return nil
+	}
+	return fmt.Errorf("%w: the container rootfs can not be used by %s guests with monitor %s, since they do not share support for block, virtiofs or 9pfs",
+		ErrUnsupported, c.unikernelType, c.vmmType)
+}
+
+// checkVAccel checks if vAccel over vsock is supported and if the RPC
+// address matches the vsock mode of the monitor
+func (c capabilityCheck) checkVAccel() error {
+	if c.specAnnot[annotVAccel] != "vsock" {
+		return nil
+	}
+	if c.vmm.Vsock == types.VsockNone {
+		return fmt.Errorf("%w: vAccel over vsock requested, but monitor %s does not support vsock", ErrUnsupported, c.vmmType)
+	}
+	if !c.unikernel.Vsock {
+		return fmt.Errorf("%w: vAccel over vsock requested, but %s guests do not support vsock", ErrUnsupported, c.unikernelType)
+	}
+	address := c.specAnnot[annotRPCAddress]
+	if address == "" {
+		return fmt.Errorf("%w: vAccel over vsock requested, but the rpc address is not set", ErrUnsupported)
+	}
+	_, _, err := isValidVSockAddress(&address, c.vmm.Vsock)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnsupported, err)
+	}
+	return nil
+}
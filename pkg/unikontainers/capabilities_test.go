@@ -0,0 +1,184 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/unikernels"
+)
+
+func newCapabilityCheck(t *testing.T, vmmType string, unikernelType string) capabilityCheck {
+	t.Helper()
+	unikernel, err := unikernels.New(unikernelType)
+	assert.NoError(t, err)
+	c := capabilityCheck{
+		vmmType:       vmmType,
+		unikernelType: unikernelType,
+		unikernel:     unikernel.Capabilities(),
+		annot:         map[string]string{},
+		specAnnot:     map[string]string{},
+		arch:          "amd64",
+	}
+	switch hypervisors.VmmType(vmmType) {
+	case hypervisors.QemuVmm:
+		c.vmm = (&hypervisors.Qemu{}).Capabilities()
+	case hypervisors.FirecrackerVmm:
+		c.vmm = (&hypervisors.Firecracker{}).Capabilities()
+	case hypervisors.HvtVmm:
+		c.vmm = (&hypervisors.HVT{}).Capabilities()
+	case hypervisors.HedgeVmm:
+		c.vmm = (&hypervisors.Hedge{}).Capabilities()
+	}
+	return c
+}
+
+func TestCheckCapabilities(t *testing.T) {
+	t.Run("supported combination", func(t *testing.T) {
+		t.Parallel()
+		c := newCapabilityCheck(t, "qemu", "linux")
+		c.annot[annotMountRootfs] = "true"
+		c.specAnnot[annotVAccel] = "vsock"
+		c.specAnnot[annotRPCAddress] = "vsock://2:2049"
+		assert.NoError(t, c.check())
+	})
+
+	t.Run("architecture", func(t *testing.T) {
+		t.Parallel()
+		c := newCapabilityCheck(t, "hedge", "rumprun")
+		c.arch = "arm64"
+		assert.ErrorIs(t, c.check(), ErrUnsupported)
+		assert.ErrorContains(t, c.check(), "monitor hedge does not support arm64")
+
+		c = newCapabilityCheck(t, "qemu", "mewz")
+		c.arch = "arm64"
+		assert.ErrorContains(t, c.check(), "mewz guests do not support arm64")
+	})
+
+	t.Run("block device", func(t *testing.T) {
+		t.Parallel()
+		c := newCapabilityCheck(t, "qemu", "unikraft")
+		c.annot[annotBlock] = "/disk.img"
+		assert.ErrorContains(t, c.check(), "unikraft guests do not support block devices")
+
+		c = newCapabilityCheck(t, "hvt", "rumprun")
+		c.annot[annotBlock] = "/disk.img"
+		assert.NoError(t, c.check())
+	})
+
+	t.Run("solo5 manifest", func(t *testing.T) {
+		t.Parallel()
+		c := newCapabilityCheck(t, "hvt", "mirage")
+		c.solo5 = &unikernels.Solo5Manifest{Devices: []unikernels.Solo5Device{
+			{Name: "service", Type: unikernels.Solo5NetDevice},
+		}}
+		assert.NoError(t, c.check())
+
+		c.annot[annotBlock] = "/disk.img"
+		assert.ErrorIs(t, c.check(), ErrUnsupported)
+		assert.ErrorContains(t, c.check(), "the unikernel declares no block devices in its Solo5 manifest")
+
+		c.solo5.Devices = append(c.solo5.Devices,
+			unikernels.Solo5Device{Name: "storage", Type: unikernels.Solo5BlockDevice},
+			unikernels.Solo5Device{Name: "management", Type: unikernels.Solo5NetDevice})
+		assert.NoError(t, c.check())
+
+		// Rumprun guests use a single net device
+		c = newCapabilityCheck(t, "hvt", "rumprun")
+		c.solo5 = &unikernels.Solo5Manifest{Devices: []unikernels.Solo5Device{
+			{Name: "service", Type: unikernels.Solo5NetDevice},
+			{Name: "management", Type: unikernels.Solo5NetDevice},
+		}}
+		assert.ErrorContains(t, c.check(), "the unikernel declares net devices service, management")
+	})
+
+	t.Run("microvm", func(t *testing.T) {
+		t.Parallel()
+		c := newCapabilityCheck(t, "qemu", "mewz")
+		c.machine = hypervisors.QemuMachineMicrovm
+		assert.ErrorIs(t, c.check(), ErrUnsupported)
+		assert.ErrorContains(t, c.check(), "mewz guests can not use the devices of the microvm machine")
+
+		c = newCapabilityCheck(t, "qemu", "unikraft")
+		c.machine = hypervisors.QemuMachineMicrovm
+		assert.NoError(t, c.check())
+	})
+
+	t.Run("hugepages", func(t *testing.T) {
+		t.Parallel()
+		c := newCapabilityCheck(t, "hvt", "rumprun")
+		c.hugepages = &guestHugepages{pageSize: 2 * 1024 * 1024, limit: 512 * 1024 * 1024}
+		assert.ErrorIs(t, c.check(), ErrUnsupported)
+		assert.ErrorContains(t, c.check(), "monitor hvt can not back the guest memory with hugepages")
+
+		c = newCapabilityCheck(t, "firecracker", "linux")
+		c.hugepages = &guestHugepages{pageSize: 2 * 1024 * 1024, limit: 512 * 1024 * 1024}
+		assert.NoError(t, c.check())
+	})
+
+	t.Run("container rootfs", func(t *testing.T) {
+		t.Parallel()
+		// Unikraft supports only 9pfs, which Firecracker does not support
+		c := newCapabilityCheck(t, "firecracker", "unikraft")
+		c.annot[annotMountRootfs] = "true"
+		assert.ErrorIs(t, c.check(), ErrUnsupported)
+
+		c.annot[annotInitrd] = "/initrd"
+		assert.NoError(t, c.check())
+
+		c = newCapabilityCheck(t, "qemu", "unikraft")
+		c.annot[annotMountRootfs] = "true"
+		assert.NoError(t, c.check())
+	})
+
+	t.Run("vaccel", func(t *testing.T) {
+		t.Parallel()
+		c := newCapabilityCheck(t, "hvt", "linux")
+		c.specAnnot[annotVAccel] = "vsock"
+		c.specAnnot[annotRPCAddress] = "vsock://2:2049"
+		assert.ErrorContains(t, c.check(), "monitor hvt does not support vsock")
+
+		c = newCapabilityCheck(t, "qemu", "rumprun")
+		c.specAnnot[annotVAccel] = "vsock"
+		c.specAnnot[annotRPCAddress] = "vsock://2:2049"
+		assert.ErrorContains(t, c.check(), "rumprun guests do not support vsock")
+
+		// Firecracker expects a unix socket
+		c = newCapabilityCheck(t, "firecracker", "linux")
+		c.specAnnot[annotVAccel] = "vsock"
+		c.specAnnot[annotRPCAddress] = "vsock://2:2049"
+		assert.ErrorIs(t, c.check(), ErrUnsupported)
+		c.specAnnot[annotRPCAddress] = "unix:///run/vaccel.sock_2049"
+		assert.NoError(t, c.check())
+
+		delete(c.specAnnot, annotRPCAddress)
+		assert.ErrorContains(t, c.check(), "rpc address is not set")
+	})
+}
+
+func TestUnikernelCapabilities(t *testing.T) {
+	t.Parallel()
+	linux, err := unikernels.New("linux")
+	assert.NoError(t, err)
+	assert.True(t, linux.Capabilities().SupportsBlockFS("ext4"))
+	assert.False(t, linux.Capabilities().SupportsBlockFS("xfs"))
+
+	mirage, err := unikernels.New("mirage")
+	assert.NoError(t, err)
+	assert.False(t, mirage.Capabilities().SupportsBlockFS("ext2"))
+	assert.True(t, mirage.Capabilities().SupportsArch("arm64"))
+}
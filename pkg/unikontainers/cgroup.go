@@ -0,0 +1,384 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containerd/cgroups/v3"
+	"github.com/containerd/cgroups/v3/cgroup1"
+	"github.com/containerd/cgroups/v3/cgroup2"
+	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CgroupDriverFs      = "cgroupfs"
+	CgroupDriverSystemd = "systemd"
+	defaultSystemdSlice = "system.slice"
+	systemdJobTimeout   = 30 * time.Second
+	cgroupMountpoint    = "/sys/fs/cgroup"
+	// State annotations to keep track of the cgroup that we created,
+	// in order to remove it in delete.
+	annotCgroupDriver = "urunc_state.cgroup_driver"
+	annotCgroupPath   = "urunc_state.cgroup_path"
+)
+
+// CgroupOpts holds the options of urunc's cli regarding cgroups
+type CgroupOpts struct {
+	Systemd        bool // Use the systemd driver instead of cgroupfs
+	IgnorePermErrs bool // Do not fail if we are not allowed to manage cgroups
+}
+
+// SetupCgroup places the process with the given pid in the cgroup described in
+// the container's spec and applies the CPU, memory, pids and IO limits.
+// It should be called with the reexec process, before it enters the
+// namespaces, so that the monitor and all its children inherit the cgroup.
+// The cgroup is recorded in the state annotations and therefore, the caller
+// needs to save the state afterwards.
+func (u *Unikontainer) SetupCgroup(pid int, opts CgroupOpts) error {
+	cgroupsPath := ""
+	var resources *specs.LinuxResources
+	if u.Spec.Linux != nil {
+		cgroupsPath = u.Spec.Linux.CgroupsPath
+		resources = u.Spec.Linux.Resources
+	}
+	if cgroupsPath == "" {
+		uniklog.Debug("No cgroups path was specified, staying in the current cgroup")
+		return nil
+	}
+	resources = monitorResources(resources)
+
+	driver := CgroupDriverFs
+	var path string
+	var err error
+	if opts.Systemd {
+		driver = CgroupDriverSystemd
+		path, err = applySystemdCgroup(cgroupsPath, u.State.ID, pid, resources)
+	} else {
+		path, err = applyFsCgroup(cgroupsPath, pid, resources)
+	}
+	if err != nil {
+		if opts.IgnorePermErrs && isCgroupPermErr(err) {
+			uniklog.WithError(err).Warn("Ignoring cgroup permission error")
+			return nil
+		}
+		return fmt.Errorf("failed to set up %s cgroup %s: %w", driver, cgroupsPath, err)
+	}
+	uniklog.WithFields(logrus.Fields{
+		"driver": driver,
+		"path":   path,
+		"pid":    pid,
+	}).Debug("Placed process in cgroup")
+
+	u.State.Annotations[annotCgroupDriver] = driver
+	u.State.Annotations[annotCgroupPath] = path
+	return nil
+}
+
+// RemoveCgroup removes the cgroup that was created in SetupCgroup, if any.
+func (u *Unikontainer) RemoveCgroup() error {
+	path := u.State.Annotations[annotCgroupPath]
+	if path == "" {
+		return nil
+	}
+	var err error
+	switch u.State.Annotations[annotCgroupDriver] {
+	case CgroupDriverSystemd:
+		err = stopSystemdUnit(filepath.Base(path))
+		if err != nil {
+			break
+		}
+		// systemd does not remove the cgroups in the hierarchies it does not manage
+		if cgroups.Mode() != cgroups.Unified {
+			err = removeFsCgroup(path)
+		}
+	case CgroupDriverFs:
+		err = removeFsCgroup(path)
+	default:
+		err = fmt.Errorf("unknown cgroup driver %s", u.State.Annotations[annotCgroupDriver])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove cgroup %s: %w", path, err)
+	}
+	return nil
+}
+
+// monitorMemoryOverhead is the memory that the cgroup of the monitor gets on
+// top of the memory limit of the container. The memory limit of the container
+// becomes the memory of the guest (see Exec), while the monitor needs memory
+// of its own (e.g. for the emulation of the devices and its page tables).
+const monitorMemoryOverhead = 128 * 1024 * 1024
+
+// monitorResources keeps only the resources that make sense for the monitor
+// process. Devices are left out on purpose, since the monitor needs access to
+// devices (e.g. /dev/kvm) which are not part of the container's device list.
+// The memory limits get monitorMemoryOverhead, so that the monitor does not
+// get OOM-killed once the guest touches all of its memory.
+func monitorResources(r *specs.LinuxResources) *specs.LinuxResources {
+	if r == nil {
+		return &specs.LinuxResources{}
+	}
+	return &specs.LinuxResources{
+		CPU:     r.CPU,
+		Memory:  monitorMemory(r.Memory),
+		Pids:    r.Pids,
+		BlockIO: r.BlockIO,
+	}
+}
+
+// monitorMemory adds monitorMemoryOverhead to the memory limit and to the
+// memory plus swap limit of the container, if they are set.
+func monitorMemory(m *specs.LinuxMemory) *specs.LinuxMemory {
+	if m == nil {
+		return nil
+	}
+	memory := *m
+	addOverhead := func(limit *int64) *int64 {
+		if limit == nil || *limit <= 0 {
+			return limit
+		}
+		withOverhead := *limit + monitorMemoryOverhead
+		return &withOverhead
+	}
+	memory.Limit = addOverhead(m.Limit)
+	memory.Swap = addOverhead(m.Swap)
+	return &memory
+}
+
+func isCgroupPermErr(err error) bool {
+	if errors.Is(err, os.ErrPermission) {
+		return true
+	}
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) {
+		return dbusErr.Name == "org.freedesktop.DBus.Error.AccessDenied" ||
+			dbusErr.Name == "org.freedesktop.DBus.Error.InteractiveAuthorizationRequired"
+	}
+	return false
+}
+
+// applyFsCgroup creates the cgroup directly in the cgroup filesystem.
+// Relative paths are considered relative to the cgroup of the current process.
+func applyFsCgroup(cgroupsPath string, pid int, resources *specs.LinuxResources) (string, error) {
+	if cgroups.Mode() == cgroups.Unified {
+		group := filepath.Clean(cgroupsPath)
+		if !filepath.IsAbs(group) {
+			var err error
+			group, err = cgroup2.NestedGroupPath(group)
+			if err != nil {
+				return "", err
+			}
+		}
+		manager, err := cgroup2.NewManager(cgroupMountpoint, group, cgroup2.ToResources(resources))
+		if err != nil {
+			return "", err
+		}
+		return group, manager.AddProc(uint64(pid)) //nolint: gosec
+	}
+
+	// Fallback to cgroup v1 (legacy or hybrid hierarchy)
+	path := cgroup1.StaticPath(filepath.Clean(cgroupsPath))
+	if !filepath.IsAbs(cgroupsPath) {
+		path = cgroup1.NestedPath(cgroupsPath)
+	}
+	group, err := path(cgroup1.Pids)
+	if err != nil {
+		return "", err
+	}
+	cg, err := cgroup1.New(path, resources)
+	if err != nil {
+		return "", err
+	}
+	return group, cg.AddProc(uint64(pid)) //nolint: gosec
+}
+
+func removeFsCgroup(group string) error {
+	if cgroups.Mode() == cgroups.Unified {
+		manager, err := cgroup2.Load(group)
+		if err != nil {
+			return err
+		}
+		err = manager.Delete()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	cg, err := cgroup1.Load(cgroup1.StaticPath(group))
+	if err != nil {
+		if errors.Is(err, cgroup1.ErrCgroupDeleted) {
+			return nil
+		}
+		return err
+	}
+	return cg.Delete()
+}
+
+// parseSystemdCgroupsPath parses a cgroups path of the form "slice:prefix:name"
+// and returns the slice and the name of the unit.
+func parseSystemdCgroupsPath(cgroupsPath string, containerID string) (string, string, error) {
+	parts := strings.Split(cgroupsPath, ":")
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("expected cgroups path of the form \"slice:prefix:name\", got %q", cgroupsPath)
+	}
+	slice, prefix, name := parts[0], parts[1], parts[2]
+	if slice == "" {
+		slice = defaultSystemdSlice
+	}
+	if name == "" {
+		name = containerID
+	}
+	// A name ending in .slice refers to a slice and is used as is
+	if strings.HasSuffix(name, ".slice") {
+		return slice, name, nil
+	}
+	if prefix != "" {
+		name = prefix + "-" + name
+	}
+	return slice, name + ".scope", nil
+}
+
+// expandSlice returns the cgroup path of a systemd slice,
+// e.g. "a-b-c.slice" -> "/a.slice/a-b.slice/a-b-c.slice"
+func expandSlice(slice string) (string, error) {
+	const suffix = ".slice"
+	if !strings.HasSuffix(slice, suffix) || strings.Contains(slice, "/") {
+		return "", fmt.Errorf("invalid slice name: %s", slice)
+	}
+	if slice == "-.slice" {
+		return "/", nil
+	}
+	sliceName := strings.TrimSuffix(slice, suffix)
+	var path, prefix string
+	for _, component := range strings.Split(sliceName, "-") {
+		if component == "" {
+			return "", fmt.Errorf("invalid slice name: %s", slice)
+		}
+		path += "/" + prefix + component + suffix
+		prefix += component + "-"
+	}
+	return path, nil
+}
+
+// applySystemdCgroup asks systemd to create a transient unit for the process
+// and then applies the resources in the cgroup that systemd delegated to us.
+func applySystemdCgroup(cgroupsPath string, containerID string, pid int, resources *specs.LinuxResources) (string, error) {
+	slice, unit, err := parseSystemdCgroupsPath(cgroupsPath, containerID)
+	if err != nil {
+		return "", err
+	}
+	slicePath, err := expandSlice(slice)
+	if err != nil {
+		return "", err
+	}
+	group := filepath.Join(slicePath, unit)
+
+	err = startSystemdUnit(slice, unit, pid)
+	if err != nil {
+		return "", err
+	}
+
+	if cgroups.Mode() == cgroups.Unified {
+		manager, err := cgroup2.Load(group)
+		if err != nil {
+			return "", err
+		}
+		return group, manager.Update(cgroup2.ToResources(resources))
+	}
+	// systemd manages only some of the v1 hierarchies. Make sure that the
+	// process is placed in the same path in the rest of them too.
+	cg, err := cgroup1.New(cgroup1.StaticPath(group), resources)
+	if err != nil {
+		return "", err
+	}
+	return group, cg.AddProc(uint64(pid)) //nolint: gosec
+}
+
+func startSystemdUnit(slice string, unit string, pid int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), systemdJobTimeout)
+	defer cancel()
+	conn, err := systemdDbus.NewWithContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	properties := []systemdDbus.Property{
+		systemdDbus.PropDescription("urunc container " + unit),
+		systemdDbus.PropPids(uint32(pid)), //nolint: gosec
+		{Name: "DefaultDependencies", Value: dbus.MakeVariant(false)},
+		{Name: "Delegate", Value: dbus.MakeVariant(true)},
+		{Name: "MemoryAccounting", Value: dbus.MakeVariant(true)},
+		{Name: "CPUAccounting", Value: dbus.MakeVariant(true)},
+		{Name: "IOAccounting", Value: dbus.MakeVariant(true)},
+		{Name: "TasksAccounting", Value: dbus.MakeVariant(true)},
+	}
+	if strings.HasSuffix(unit, ".slice") {
+		properties = append(properties, systemdDbus.PropWants(slice))
+	} else {
+		properties = append(properties, systemdDbus.PropSlice(slice))
+	}
+
+	statusChan := make(chan string, 1)
+	_, err = conn.StartTransientUnitContext(ctx, unit, "replace", properties, statusChan)
+	if err != nil {
+		return fmt.Errorf("failed to start unit %s: %w", unit, err)
+	}
+	select {
+	case status := <-statusChan:
+		if status != "done" {
+			return fmt.Errorf("failed to start unit %s: job status %s", unit, status)
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for unit %s to start", unit)
+	}
+	return nil
+}
+
+func stopSystemdUnit(unit string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), systemdJobTimeout)
+	defer cancel()
+	conn, err := systemdDbus.NewWithContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	statusChan := make(chan string, 1)
+	_, err = conn.StopUnitContext(ctx, unit, "replace", statusChan)
+	if err != nil {
+		var dbusErr dbus.Error
+		if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.systemd1.NoSuchUnit" {
+			// Transient units get removed when their processes exit
+			return nil
+		}
+		return fmt.Errorf("failed to stop unit %s: %w", unit, err)
+	}
+	select {
+	case <-statusChan:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for unit %s to stop", unit)
+	}
+	return nil
+}
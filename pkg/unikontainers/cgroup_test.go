@@ -0,0 +1,128 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSystemdCgroupsPath(t *testing.T) {
+	t.Run("full systemd cgroups path", func(t *testing.T) {
+		t.Parallel()
+		slice, unit, err := parseSystemdCgroupsPath("kubepods.slice:cri-containerd:abc", "id")
+		assert.NoError(t, err)
+		assert.Equal(t, "kubepods.slice", slice)
+		assert.Equal(t, "cri-containerd-abc.scope", unit)
+	})
+
+	t.Run("empty fields use defaults", func(t *testing.T) {
+		t.Parallel()
+		slice, unit, err := parseSystemdCgroupsPath("::", "id")
+		assert.NoError(t, err)
+		assert.Equal(t, defaultSystemdSlice, slice)
+		assert.Equal(t, "id.scope", unit)
+	})
+
+	t.Run("name is a slice", func(t *testing.T) {
+		t.Parallel()
+		_, unit, err := parseSystemdCgroupsPath("system.slice:urunc:test.slice", "id")
+		assert.NoError(t, err)
+		assert.Equal(t, "test.slice", unit)
+	})
+
+	t.Run("cgroupfs path is invalid", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := parseSystemdCgroupsPath("/default/id", "id")
+		assert.Error(t, err)
+	})
+}
+
+func TestExpandSlice(t *testing.T) {
+	t.Run("nested slice", func(t *testing.T) {
+		t.Parallel()
+		path, err := expandSlice("a-b-c.slice")
+		assert.NoError(t, err)
+		assert.Equal(t, "/a.slice/a-b.slice/a-b-c.slice", path)
+	})
+
+	t.Run("root slice", func(t *testing.T) {
+		t.Parallel()
+		path, err := expandSlice("-.slice")
+		assert.NoError(t, err)
+		assert.Equal(t, "/", path)
+	})
+
+	t.Run("invalid slices", func(t *testing.T) {
+		t.Parallel()
+		for _, s := range []string{"system", "a--b.slice", "a/b.slice"} {
+			_, err := expandSlice(s)
+			assert.Error(t, err, "Expected an error for %s", s)
+		}
+	})
+}
+
+func TestMonitorResources(t *testing.T) {
+	t.Run("nil resources", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, &specs.LinuxResources{}, monitorResources(nil))
+	})
+
+	t.Run("device rules are dropped", func(t *testing.T) {
+		t.Parallel()
+		limit := int64(1 << 20)
+		res := &specs.LinuxResources{
+			Devices: []specs.LinuxDeviceCgroup{{Allow: false, Access: "rwm"}},
+			Memory:  &specs.LinuxMemory{Limit: &limit},
+		}
+		filtered := monitorResources(res)
+		assert.Nil(t, filtered.Devices)
+		assert.NotNil(t, filtered.Memory)
+	})
+
+	t.Run("memory limits get the overhead of the monitor", func(t *testing.T) {
+		t.Parallel()
+		limit := int64(256 << 20)
+		swap := int64(512 << 20)
+		reservation := int64(64 << 20)
+		res := &specs.LinuxResources{
+			Memory: &specs.LinuxMemory{Limit: &limit, Swap: &swap, Reservation: &reservation},
+		}
+		filtered := monitorResources(res)
+		assert.Equal(t, limit+monitorMemoryOverhead, *filtered.Memory.Limit)
+		assert.Equal(t, swap+monitorMemoryOverhead, *filtered.Memory.Swap)
+		assert.Equal(t, reservation, *filtered.Memory.Reservation)
+		// The guest keeps the memory limit of the container
+		assert.Equal(t, int64(256<<20), *res.Memory.Limit)
+	})
+
+	t.Run("unlimited memory", func(t *testing.T) {
+		t.Parallel()
+		unlimited := int64(-1)
+		res := &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: &unlimited, Swap: &unlimited}}
+		filtered := monitorResources(res)
+		assert.Equal(t, int64(-1), *filtered.Memory.Limit)
+		assert.Equal(t, int64(-1), *filtered.Memory.Swap)
+	})
+}
+
+func TestIsCgroupPermErr(t *testing.T) {
+	t.Parallel()
+	assert.True(t, isCgroupPermErr(os.ErrPermission))
+	assert.False(t, isCgroupPermErr(os.ErrNotExist))
+}
@@ -47,6 +47,11 @@ const (
 	annotMountRootfs   = "com.urunc.unikernel.mountRootfs"
 )
 
+// annotQemuMachine overrides the machine type of Qemu from the urunc config
+// for a single container. It is not part of the unikernel configuration and
+// it is only read from the spec (e.g. as a pod annotation).
+const annotQemuMachine = "com.urunc.unikernel.qemuMachine"
+
 // A UnikernelConfig struct holds the info provided by bima image on how to execute our unikernel
 type UnikernelConfig struct {
 	UnikernelType    string `json:"com.urunc.unikernel.unikernelType"`
@@ -0,0 +1,376 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	consoleLogFilename = "console.log"
+	// The environment of the console logger process. The pipe with the
+	// output of the monitor and the rest of the files are passed as file
+	// descriptors, since the logger shares the mount namespace of reexec
+	// and loses access to the host paths, when reexec changes its root.
+	consolePipeEnv   = "_URUNC_CONSOLEPIPE"
+	consoleConfigEnv = "_URUNC_CONSOLE_CONFIG"
+	// How long reexec waits for the console logger, when reexec
+	// supervises the monitor
+	consoleStopTimeout = 5 * time.Second
+)
+
+// consoleLoggerConfig is the configuration of the console logger, which
+// startConsoleLog passes as JSON in the environment of the logger
+type consoleLoggerConfig struct {
+	LogDirFd int          `json:"logDirFd,omitempty"` // The directory of the console log. Zero disables the log
+	MaxSize  int64        `json:"maxSize"`
+	MaxFiles int          `json:"maxFiles"`
+	Crash    *crashConfig `json:"crash,omitempty"` // Nil disables the crash detection
+}
+
+// consoleLogDir returns the directory of the console log of the container
+func (u *Unikontainer) consoleLogDir() string {
+	if u.UruncCfg.Console.Directory == "" {
+		return u.BaseDir
+	}
+	return filepath.Join(u.UruncCfg.Console.Directory, u.State.ID)
+}
+
+// startConsoleLog starts a process that copies the output of the monitor,
+// which is the serial console of the guest, to the stdout of reexec and
+// to the console log of the container, while it also looks for crashes of
+// the guest. The stdout of reexec gets replaced by a pipe to that
+// process. It must be called before reexec changes its root.
+//
+// If the monitor reports the panics of the guest as events, it returns the
+// file where the monitor must write them, which stays open in execve.
+func (u *Unikontainer) startConsoleLog(unikernel types.Unikernel, vmmCaps types.VMMCapabilities, stateDir *os.File) (*os.File, error) {
+	if !u.UruncCfg.Console.Enabled && stateDir == nil {
+		return nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the console pipe: %w", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	// The pipe is the first of the extra files and hence fd 3
+	files := []*os.File{r}
+	addFile := func(f *os.File) int {
+		files = append(files, f)
+		return 2 + len(files)
+	}
+
+	var config consoleLoggerConfig
+	if u.UruncCfg.Console.Enabled {
+		logDir := u.consoleLogDir()
+		err = os.MkdirAll(logDir, 0o750)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the console log directory: %w", err)
+		}
+		dir, err := os.Open(logDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the console log directory: %w", err)
+		}
+		defer dir.Close()
+		config.LogDirFd = addFile(dir)
+		config.MaxSize = int64(u.UruncCfg.Console.MaxSizeMB) * 1024 * 1024 //nolint: gosec
+		config.MaxFiles = int(u.UruncCfg.Console.MaxFiles)                 //nolint: gosec
+		uniklog.WithField("directory", logDir).Info("Logging the console of the guest")
+	}
+
+	var events *os.File
+	if stateDir != nil {
+		config.Crash = &crashConfig{
+			ContainerID:   u.State.ID,
+			UnikernelType: u.State.Annotations[annotType],
+			Lines:         int(u.UruncCfg.Crash.ConsoleLines), //nolint: gosec
+			StateDirFd:    addFile(stateDir),
+		}
+		if reporter, ok := unikernel.(types.GuestCrashReporter); ok {
+			config.Crash.Patterns = reporter.CrashPatterns()
+		}
+		if log, ok := logrus.StandardLogger().Out.(*os.File); ok {
+			config.Crash.LogFd = addFile(log)
+			_, config.Crash.LogJSON = logrus.StandardLogger().Formatter.(*logrus.JSONFormatter)
+		}
+		if vmmCaps.PanicEvents {
+			eventsR, eventsW, err := os.Pipe()
+			if err != nil {
+				return nil, fmt.Errorf("failed to create the event pipe: %w", err)
+			}
+			defer eventsR.Close()
+			config.Crash.EventFd = addFile(eventsR)
+			events = eventsW
+		}
+	}
+	encodedConfig, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// The logger must not run nsenter again
+	var env []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "_LIBCONTAINER_") {
+			env = append(env, e)
+		}
+	}
+	env = append(env, consolePipeEnv+"=3", consoleConfigEnv+"="+string(encodedConfig))
+	logger := &exec.Cmd{
+		Path:       "/proc/self/exe",
+		Args:       []string{os.Args[0]},
+		Env:        env,
+		Stdout:     os.Stdout,
+		Stderr:     os.Stderr,
+		ExtraFiles: files,
+		// Signals from the terminal of the container must not kill the
+		// logger before the monitor
+		SysProcAttr: &syscall.SysProcAttr{Setpgid: true},
+	}
+	err = logger.Start()
+	if err != nil {
+		if events != nil {
+			_ = events.Close()
+		}
+		return nil, fmt.Errorf("failed to start the console logger: %w", err)
+	}
+	u.consoleLogger = logger
+	// The logger exits, when the monitor exits and closes the pipe
+	err = unix.Dup3(int(w.Fd()), unix.Stdout, 0)
+	if err != nil {
+		if events != nil {
+			_ = events.Close()
+		}
+		return nil, fmt.Errorf("failed to redirect the console to the logger: %w", err)
+	}
+	if events != nil {
+		// The monitor inherits the event pipe
+		_, err = unix.FcntlInt(events.Fd(), unix.F_SETFD, 0)
+		if err != nil {
+			_ = events.Close()
+			return nil, fmt.Errorf("failed to pass the event pipe to the monitor: %w", err)
+		}
+	}
+	return events, nil
+}
+
+// stopConsoleLog closes the stdout of the current process, which is the
+// last writer of the console pipe once the monitor exits, and waits for
+// the console logger to exit. As a result, the logger reports any crash
+// of the guest that it detected first.
+func (u *Unikontainer) stopConsoleLog() {
+	if u.consoleLogger == nil {
+		return
+	}
+	_ = unix.Close(unix.Stdout)
+	done := make(chan error, 1)
+	go func() {
+		done <- u.consoleLogger.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(consoleStopTimeout):
+		uniklog.Warn("Timed out waiting for the console logger to exit")
+	}
+}
+
+// IsConsoleLogger returns true if the current process is the console
+// logger that startConsoleLog started. The logger does not parse any
+// arguments or files, since reexec might change its root at any time.
+func IsConsoleLogger() bool {
+	return os.Getenv(consolePipeEnv) != ""
+}
+
+// RunConsoleLog is the main function of the console logger process that
+// startConsoleLog starts. It copies the console of the guest from the pipe
+// to stdout and to the console log, until the monitor exits. Meanwhile,
+// it reports any crash of the guest.
+func RunConsoleLog() error {
+	pipeFd, err := strconv.Atoi(os.Getenv(consolePipeEnv))
+	if err != nil {
+		return fmt.Errorf("unable to convert %s: %w", consolePipeEnv, err)
+	}
+	var config consoleLoggerConfig
+	err = json.Unmarshal([]byte(os.Getenv(consoleConfigEnv)), &config)
+	if err != nil {
+		return fmt.Errorf("unable to parse %s: %w", consoleConfigEnv, err)
+	}
+	// Writing to stdout must fail, instead of killing the logger, when
+	// the reader of stdout goes away
+	signal.Ignore(unix.SIGPIPE)
+	pipe := os.NewFile(uintptr(pipeFd), "consolepipe")
+
+	var sinks []io.Writer
+	if config.LogDirFd != 0 {
+		dir := os.NewFile(uintptr(config.LogDirFd), "consoledir")
+		defer dir.Close()
+		log, err := newConsoleLog(dir, config.MaxSize, config.MaxFiles)
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+		sinks = append(sinks, log)
+	}
+	if config.Crash != nil {
+		if config.Crash.LogFd != 0 {
+			logrus.SetOutput(os.NewFile(uintptr(config.Crash.LogFd), "log"))
+		}
+		if config.Crash.LogJSON {
+			logrus.SetFormatter(new(logrus.JSONFormatter))
+		}
+		stateDir := os.NewFile(uintptr(config.Crash.StateDirFd), "statedir")
+		defer stateDir.Close()
+		watcher, err := newCrashWatcher(*config.Crash, stateDir)
+		if err != nil {
+			return err
+		}
+		defer watcher.Flush()
+		if config.Crash.EventFd != 0 {
+			go watcher.watchEvents(os.NewFile(uintptr(config.Crash.EventFd), "events"))
+		}
+		sinks = append(sinks, watcher)
+	}
+	return copyConsole(pipe, os.Stdout, sinks...)
+}
+
+// copyConsole copies the console from src to out and to the sinks (the
+// console log and the crash watcher). A writer that fails gets dropped,
+// while the console keeps getting copied to the rest. For example, writing
+// to out fails once the shim has detached and writing to the console log
+// fails once the disk is full.
+func copyConsole(src io.Reader, out io.Writer, sinks ...io.Writer) error {
+	writers := append([]io.Writer{out}, sinks...)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			for i, w := range writers {
+				if w == nil {
+					continue
+				}
+				_, writeErr := w.Write(buf[:n])
+				if writeErr == nil {
+					continue
+				}
+				writers[i] = nil
+				if i > 0 {
+					uniklog.WithError(writeErr).Warn("Stopped logging the console of the guest")
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// consoleLog is a console log with size based rotation. When the log
+// would exceed maxSize, it gets renamed to console.log.1, the previous
+// console.log.1 to console.log.2 and so on, keeping up to maxFiles of the
+// older logs. A zero maxSize disables the rotation. All the files are
+// accessed relative to the directory of the log.
+type consoleLog struct {
+	dir      *os.File
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func newConsoleLog(dir *os.File, maxSize int64, maxFiles int) (*consoleLog, error) {
+	l := &consoleLog{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	err := l.open()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *consoleLog) open() error {
+	fd, err := unix.Openat(int(l.dir.Fd()), consoleLogFilename, unix.O_WRONLY|unix.O_CREAT|unix.O_APPEND|unix.O_CLOEXEC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open the console log: %w", err)
+	}
+	l.file = os.NewFile(uintptr(fd), consoleLogFilename)
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	l.size = info.Size()
+	return nil
+}
+
+func (l *consoleLog) rotate() error {
+	err := l.file.Close()
+	if err != nil {
+		return err
+	}
+	dirFd := int(l.dir.Fd())
+	if l.maxFiles == 0 {
+		err = unix.Unlinkat(dirFd, consoleLogFilename, 0)
+		if err != nil && !errors.Is(err, unix.ENOENT) {
+			return err
+		}
+		return l.open()
+	}
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		oldName := consoleLogFilename + "." + strconv.Itoa(i)
+		newName := consoleLogFilename + "." + strconv.Itoa(i+1)
+		err = unix.Renameat(dirFd, oldName, dirFd, newName)
+		if err != nil && !errors.Is(err, unix.ENOENT) {
+			return err
+		}
+	}
+	err = unix.Renameat(dirFd, consoleLogFilename, dirFd, consoleLogFilename+".1")
+	if err != nil && !errors.Is(err, unix.ENOENT) {
+		return err
+	}
+	return l.open()
+}
+
+func (l *consoleLog) Write(p []byte) (int, error) {
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		err := l.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+func (l *consoleLog) Close() error {
+	return l.file.Close()
+}
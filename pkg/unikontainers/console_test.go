@@ -0,0 +1,129 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(_ []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("detached")
+}
+
+func TestConsoleLog(t *testing.T) {
+	openDir := func(t *testing.T) (string, *os.File) {
+		path := t.TempDir()
+		dir, err := os.Open(path)
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = dir.Close() })
+		return path, dir
+	}
+	readLog := func(t *testing.T, path string) string {
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		return string(content)
+	}
+
+	t.Run("rotation", func(t *testing.T) {
+		t.Parallel()
+		path, dir := openDir(t)
+		log, err := newConsoleLog(dir, 10, 2)
+		assert.NoError(t, err)
+		for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+			_, err = log.Write([]byte(line))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, log.Close())
+
+		assert.Equal(t, "fourth\n", readLog(t, filepath.Join(path, "console.log")))
+		assert.Equal(t, "third\n", readLog(t, filepath.Join(path, "console.log.1")))
+		assert.Equal(t, "second\n", readLog(t, filepath.Join(path, "console.log.2")))
+		assert.NoFileExists(t, filepath.Join(path, "console.log.3"))
+	})
+
+	t.Run("no rotated files", func(t *testing.T) {
+		t.Parallel()
+		path, dir := openDir(t)
+		log, err := newConsoleLog(dir, 10, 0)
+		assert.NoError(t, err)
+		for _, line := range []string{"first\n", "second\n"} {
+			_, err = log.Write([]byte(line))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, log.Close())
+
+		assert.Equal(t, "second\n", readLog(t, filepath.Join(path, "console.log")))
+		assert.NoFileExists(t, filepath.Join(path, "console.log.1"))
+	})
+
+	t.Run("append to existing log", func(t *testing.T) {
+		t.Parallel()
+		path, dir := openDir(t)
+		assert.NoError(t, os.WriteFile(filepath.Join(path, "console.log"), []byte("boot\n"), 0o640))
+		log, err := newConsoleLog(dir, 0, 1)
+		assert.NoError(t, err)
+		_, err = log.Write([]byte("restart\n"))
+		assert.NoError(t, err)
+		assert.NoError(t, log.Close())
+
+		assert.Equal(t, "boot\nrestart\n", readLog(t, filepath.Join(path, "console.log")))
+	})
+
+	t.Run("copy continues when stdout fails", func(t *testing.T) {
+		t.Parallel()
+		out := &failingWriter{}
+		var log bytes.Buffer
+		src := strings.NewReader(strings.Repeat("x", 100*1024))
+		assert.NoError(t, copyConsole(src, out, &log))
+		assert.Equal(t, 1, out.writes)
+		assert.Equal(t, 100*1024, log.Len())
+	})
+
+	t.Run("copy continues when the log fails", func(t *testing.T) {
+		t.Parallel()
+		log := &failingWriter{}
+		var out, watcher bytes.Buffer
+		src := strings.NewReader(strings.Repeat("x", 100*1024))
+		assert.NoError(t, copyConsole(src, &out, log, &watcher))
+		assert.Equal(t, 1, log.writes)
+		assert.Equal(t, 100*1024, out.Len())
+		assert.Equal(t, 100*1024, watcher.Len())
+	})
+
+	t.Run("log directory", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{
+			BaseDir:  "/run/urunc/c1",
+			State:    &specs.State{ID: "c1"},
+			UruncCfg: &UruncConfig{Console: defaultConsoleConfig()},
+		}
+		assert.Equal(t, "/run/urunc/c1", u.consoleLogDir())
+		u.UruncCfg.Console.Directory = "/var/log/urunc"
+		assert.Equal(t, "/var/log/urunc/c1", u.consoleLogDir())
+	})
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// State annotations with the reason of the termination of the guest
+	// and its details (e.g. the line of the console that reported it)
+	annotTerminationReason = "urunc_state.termination_reason"
+	annotTerminationDetail = "urunc_state.termination_detail"
+	// The file where the console logger or reexec writes the reason of
+	// the termination of the guest, since they can not update the state
+	terminationFilename = "termination.json"
+	// TerminationCrash is the termination reason of a guest that crashed
+	TerminationCrash = "crash"
+	// The sources of a crash
+	crashSourceConsole = "console"
+	crashSourcePvpanic = "pvpanic"
+	// How long the report of a crash waits for the rest of the console
+	// output of the crash (e.g. the stack trace of a kernel panic)
+	crashReportDelay = time.Second
+	// Longer lines of the console are split
+	maxConsoleLine = 4096
+)
+
+// termination is the reason of the termination of the guest, as written
+// in the state directory
+type termination struct {
+	Reason string `json:"reason"`
+	Source string `json:"source"`
+	Detail string `json:"detail"`
+}
+
+// crashConfig is the configuration of the crash detection of the console
+// logger
+type crashConfig struct {
+	ContainerID   string   `json:"containerID"`
+	UnikernelType string   `json:"unikernelType"`
+	Patterns      []string `json:"patterns,omitempty"` // The patterns of the console that report a crash
+	Lines         int      `json:"lines"`              // The number of the last lines of the console to report
+	StateDirFd    int      `json:"stateDirFd"`         // The state directory of the container
+	EventFd       int      `json:"eventFd,omitempty"`  // The events of the monitor, if it reports panics
+	LogFd         int      `json:"logFd,omitempty"`    // The log of urunc
+	LogJSON       bool     `json:"logJSON,omitempty"`  // The log of urunc is in JSON
+}
+
+// openStateDir opens the state directory of the container, where the
+// reason of the termination of the guest gets written. It must be called
+// before reexec changes its root.
+func (u *Unikontainer) openStateDir() (*os.File, error) {
+	return os.Open(u.BaseDir)
+}
+
+// writeTermination writes the reason of the termination of the guest in
+// the state directory, unless it is already written. It returns false if
+// it was already written.
+func writeTermination(stateDir *os.File, t termination) (bool, error) {
+	fd, err := unix.Openat(int(stateDir.Fd()), terminationFilename,
+		unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL|unix.O_CLOEXEC, 0o644)
+	if errors.Is(err, unix.EEXIST) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	f := os.NewFile(uintptr(fd), terminationFilename)
+	defer f.Close()
+	return true, json.NewEncoder(f).Encode(t)
+}
+
+// reportCrash records a crash of the guest in the state directory and
+// logs it along with the last lines of the console, if any. Only the
+// first report of a crash gets logged.
+func reportCrash(stateDir *os.File, config crashConfig, t termination, lines []string) {
+	written, err := writeTermination(stateDir, t)
+	if err != nil {
+		uniklog.WithError(err).Warn("failed to record the crash of the guest")
+	}
+	if !written && err == nil {
+		return
+	}
+	fields := logrus.Fields{
+		"containerID":   config.ContainerID,
+		"unikernelType": config.UnikernelType,
+		"source":        t.Source,
+		"detail":        t.Detail,
+	}
+	if lines != nil {
+		fields["console"] = lines
+	}
+	uniklog.WithFields(fields).Error("The guest crashed")
+}
+
+// recordTermination stores the reason of the termination of the guest
+// that the console logger or reexec wrote in the state directory, in the
+// state annotations. It returns true if the state changed.
+func (u *Unikontainer) recordTermination() bool {
+	if u.State.Annotations[annotTerminationReason] != "" {
+		return false
+	}
+	content, err := os.ReadFile(filepath.Join(u.BaseDir, terminationFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	if err != nil {
+		uniklog.WithError(err).Warn("failed to read the termination of the guest")
+		return false
+	}
+	var t termination
+	err = json.Unmarshal(content, &t)
+	if err != nil || t.Reason == "" {
+		uniklog.WithError(err).Warn("invalid termination of the guest")
+		return false
+	}
+	if u.State.Annotations == nil {
+		u.State.Annotations = map[string]string{}
+	}
+	u.State.Annotations[annotTerminationReason] = t.Reason
+	u.State.Annotations[annotTerminationDetail] = t.Source + ": " + t.Detail
+	return true
+}
+
+// TerminationReason returns the reason of the termination of the guest
+// (e.g. TerminationCrash), or an empty string if it is not known.
+func (u *Unikontainer) TerminationReason() string {
+	return u.State.Annotations[annotTerminationReason]
+}
+
+// crashWatcher looks for crashes of the guest in its console, as well as
+// in the panic events of the monitor. It keeps the last lines of the
+// console, in order to report them along with a crash.
+type crashWatcher struct {
+	config   crashConfig
+	stateDir *os.File
+	patterns []*regexp.Regexp
+
+	mu       sync.Mutex
+	lines    []string
+	partial  []byte
+	crash    *termination
+	timer    *time.Timer
+	reported bool
+}
+
+func newCrashWatcher(config crashConfig, stateDir *os.File) (*crashWatcher, error) {
+	w := &crashWatcher{config: config, stateDir: stateDir}
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid crash pattern %q: %w", pattern, err)
+		}
+		w.patterns = append(w.patterns, re)
+	}
+	return w, nil
+}
+
+// Write splits the console of the guest in lines and looks for a crash in
+// each of them.
+func (w *crashWatcher) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, p...)
+	rest := w.partial
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		w.addLine(string(rest[:i]))
+		rest = rest[i+1:]
+	}
+	if len(rest) > maxConsoleLine {
+		w.addLine(string(rest))
+		rest = nil
+	}
+	w.partial = append(w.partial[:0], rest...)
+	return len(p), nil
+}
+
+func (w *crashWatcher) addLine(line string) {
+	line = strings.TrimRight(line, "\r")
+	w.lines = append(w.lines, line)
+	if len(w.lines) > w.config.Lines {
+		w.lines = w.lines[len(w.lines)-w.config.Lines:]
+	}
+	for _, re := range w.patterns {
+		if re.MatchString(line) {
+			w.crashed(termination{Reason: TerminationCrash, Source: crashSourceConsole, Detail: line})
+			return
+		}
+	}
+}
+
+// crashed schedules the report of a crash, so that the report includes
+// the rest of the console output of the crash. It must be called with the
+// lock held.
+func (w *crashWatcher) crashed(t termination) {
+	if w.crash != nil || w.reported {
+		return
+	}
+	w.crash = &t
+	w.timer = time.AfterFunc(crashReportDelay, w.Flush)
+}
+
+// watchEvents reads the events that the monitor writes to its event
+// monitor (e.g. Cloud Hypervisor) and reports the panic events of the
+// guest, until the monitor exits.
+func (w *crashWatcher) watchEvents(events io.Reader) {
+	decoder := json.NewDecoder(events)
+	for {
+		var event struct {
+			Source string `json:"source"`
+			Event  string `json:"event"`
+		}
+		err := decoder.Decode(&event)
+		if err != nil {
+			return
+		}
+		if event.Source == "guest" && event.Event == "panic" {
+			w.mu.Lock()
+			w.crashed(termination{Reason: TerminationCrash, Source: crashSourcePvpanic, Detail: "panic event of the monitor"})
+			w.mu.Unlock()
+		}
+	}
+}
+
+// Flush reports a pending crash right away. It gets called when the
+// console of the guest closes, since no more output will follow.
+func (w *crashWatcher) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.crash == nil || w.reported {
+		return
+	}
+	w.reported = true
+	w.timer.Stop()
+	lines := w.lines
+	if len(w.partial) > 0 {
+		lines = append(lines, string(w.partial))
+	}
+	if len(lines) > w.config.Lines {
+		lines = lines[len(lines)-w.config.Lines:]
+	}
+	reportCrash(w.stateDir, w.config, *w.crash, lines)
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrashWatcher(t *testing.T) {
+	openStateDir := func(t *testing.T) (string, *os.File) {
+		path := t.TempDir()
+		dir, err := os.Open(path)
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = dir.Close() })
+		return path, dir
+	}
+	config := crashConfig{
+		ContainerID:   "c1",
+		UnikernelType: "linux",
+		Patterns:      []string{`Kernel panic - not syncing`},
+		Lines:         3,
+	}
+
+	t.Run("crash in the console", func(t *testing.T) {
+		t.Parallel()
+		path, dir := openStateDir(t)
+		w, err := newCrashWatcher(config, dir)
+		assert.NoError(t, err)
+		// Lines can be split across writes
+		for _, chunk := range []string{"boot\r\nOops: 0000 [#1]\nCall Trace:\n", "Kernel panic - not sy", "ncing: Fatal exception\n", "---[ end"} {
+			_, err = w.Write([]byte(chunk))
+			assert.NoError(t, err)
+		}
+		w.Flush()
+
+		u := &Unikontainer{BaseDir: path, State: &specs.State{}}
+		assert.True(t, u.recordTermination())
+		assert.Equal(t, TerminationCrash, u.TerminationReason())
+		assert.Equal(t, "console: Kernel panic - not syncing: Fatal exception", u.State.Annotations[annotTerminationDetail])
+		assert.False(t, u.recordTermination())
+	})
+
+	t.Run("last lines of the console", func(t *testing.T) {
+		t.Parallel()
+		_, dir := openStateDir(t)
+		w, err := newCrashWatcher(config, dir)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte("one\ntwo\nKernel panic - not syncing: x\nthree\nfour"))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Kernel panic - not syncing: x", "three"}, w.lines[1:])
+		assert.Equal(t, "four", string(w.partial))
+		w.Flush()
+	})
+
+	t.Run("long lines get split", func(t *testing.T) {
+		t.Parallel()
+		_, dir := openStateDir(t)
+		w, err := newCrashWatcher(config, dir)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(strings.Repeat("x", maxConsoleLine+1)))
+		assert.NoError(t, err)
+		assert.Len(t, w.lines, 1)
+		assert.Empty(t, w.partial)
+	})
+
+	t.Run("no crash", func(t *testing.T) {
+		t.Parallel()
+		path, dir := openStateDir(t)
+		w, err := newCrashWatcher(config, dir)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte("Hello world\nreboot: Power down\n"))
+		assert.NoError(t, err)
+		w.Flush()
+		assert.NoFileExists(t, filepath.Join(path, terminationFilename))
+	})
+
+	t.Run("panic event of the monitor", func(t *testing.T) {
+		t.Parallel()
+		path, dir := openStateDir(t)
+		w, err := newCrashWatcher(config, dir)
+		assert.NoError(t, err)
+		events := `{"timestamp":{"secs":0,"nanos":1},"source":"vmm","event":"booting","properties":null}
+{
+  "source": "guest",
+  "event": "panic",
+  "properties": null
+}`
+		w.watchEvents(strings.NewReader(events))
+		w.Flush()
+
+		u := &Unikontainer{BaseDir: path, State: &specs.State{}}
+		assert.True(t, u.recordTermination())
+		assert.Equal(t, "pvpanic: panic event of the monitor", u.State.Annotations[annotTerminationDetail])
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		t.Parallel()
+		_, err := newCrashWatcher(crashConfig{Patterns: []string{"("}}, nil)
+		assert.ErrorContains(t, err, "invalid crash pattern")
+	})
+
+	t.Run("only the first termination is written", func(t *testing.T) {
+		t.Parallel()
+		_, dir := openStateDir(t)
+		written, err := writeTermination(dir, termination{Reason: TerminationCrash, Source: crashSourcePvpanic})
+		assert.NoError(t, err)
+		assert.True(t, written)
+		written, err = writeTermination(dir, termination{Reason: TerminationCrash, Source: crashSourceConsole})
+		assert.NoError(t, err)
+		assert.False(t, written)
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/unikernels"
+)
+
+// ErrNoUnikernelBinary is returned when detection is enabled, but the
+// container has neither an annotated unikernel binary, nor one in the
+// configured paths of its rootfs.
+var ErrNoUnikernelBinary = errors.New("no unikernel binary found")
+
+// detectUnikernelConfig builds the unikernel config of a container that
+// lacks some of the mandatory urunc annotations by inspecting its
+// unikernel binary. The annotations that are present take precedence and
+// only the missing unikernel type and monitor get filled. The monitor is
+// the first one that is installed among the ones that can execute the
+// binary.
+func detectUnikernelConfig(bundleDir string, spec *specs.Spec, detection UruncDetection, monitors map[string]types.MonitorConfig) (*UnikernelConfig, error) {
+	conf := getConfigFromSpec(spec)
+	err := conf.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	rootfsDir, err := resolveAgainstBase(filepath.Clean(bundleDir), filepath.Clean(spec.Root.Path))
+	if err != nil {
+		return nil, err
+	}
+	candidates := detection.Paths
+	if conf.UnikernelBinary != "" {
+		candidates = []string{conf.UnikernelBinary}
+	}
+	binary := ""
+	for _, candidate := range candidates {
+		info, err := os.Stat(filepath.Join(rootfsDir, candidate))
+		if err == nil && info.Mode().IsRegular() {
+			binary = candidate
+			break
+		}
+	}
+	if binary == "" {
+		return nil, ErrNoUnikernelBinary
+	}
+
+	detected, err := unikernels.Detect(filepath.Join(rootfsDir, binary))
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect the type of %s: %w", binary, err)
+	}
+	conf.UnikernelBinary = binary
+	if conf.UnikernelType == "" {
+		if detected.Unikernel == "" {
+			return nil, fmt.Errorf("the unikernel type of %s (%s) can not be detected", binary, detected.Format)
+		}
+		conf.UnikernelType = detected.Unikernel
+	}
+	if conf.Hypervisor == "" {
+		for _, monitor := range detected.Monitors {
+			if hypervisors.Installed(hypervisors.VmmType(monitor), monitors) {
+				conf.Hypervisor = monitor
+				break
+			}
+		}
+		if conf.Hypervisor == "" {
+			return nil, fmt.Errorf("none of the monitors that can execute %s (%s) is installed: %v",
+				binary, detected.Format, detected.Monitors)
+		}
+	} else if !slices.Contains(detected.Monitors, conf.Hypervisor) {
+		uniklog.Warnf("%s (%s) is not known to execute on top of %s", binary, detected.Format, conf.Hypervisor)
+	}
+
+	uniklog.WithFields(logrus.Fields{
+		"binary":        conf.UnikernelBinary,
+		"format":        detected.Format,
+		"unikernelType": conf.UnikernelType,
+		"hypervisor":    conf.Hypervisor,
+	}).Info("Detected unikernel configuration from the binary")
+	return conf, nil
+}
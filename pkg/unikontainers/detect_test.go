@@ -0,0 +1,101 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+func TestDetectUnikernelConfig(t *testing.T) {
+	// A Linux bzImage only needs the header of the boot protocol
+	bzImage := make([]byte, 0x400)
+	copy(bzImage[0x202:], "HdrS")
+	// Only cloud-hypervisor is installed among the monitors of bzImages
+	monitors := map[string]types.MonitorConfig{
+		"cloud-hypervisor": {BinaryPath: "/usr/local/bin/cloud-hypervisor"},
+	}
+	detection := UruncDetection{Enabled: true, Paths: []string{"/kernel", "/boot/kernel"}}
+	newSpec := func(t *testing.T, files map[string][]byte, annotations map[string]string) *specs.Spec {
+		rootfs := t.TempDir()
+		for name, content := range files {
+			path := filepath.Join(rootfs, name)
+			assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			assert.NoError(t, os.WriteFile(path, content, 0o644))
+		}
+		return &specs.Spec{Root: &specs.Root{Path: rootfs}, Annotations: annotations}
+	}
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	t.Run("detect type and monitor", func(t *testing.T) {
+		t.Parallel()
+		spec := newSpec(t, map[string][]byte{"/boot/kernel": bzImage}, nil)
+		config, err := detectUnikernelConfig(t.TempDir(), spec, detection, monitors)
+		assert.NoError(t, err)
+		assert.Equal(t, &UnikernelConfig{
+			UnikernelType:   "linux",
+			UnikernelBinary: "/boot/kernel",
+			Hypervisor:      "cloud-hypervisor",
+		}, config)
+	})
+
+	t.Run("annotations take precedence", func(t *testing.T) {
+		t.Parallel()
+		spec := newSpec(t, map[string][]byte{"/bzImage": bzImage}, map[string]string{
+			annotBinary:     encode("/bzImage"),
+			annotHypervisor: encode("qemu"),
+			annotCmdLine:    encode("console=ttyS0"),
+		})
+		config, err := detectUnikernelConfig(t.TempDir(), spec, detection, monitors)
+		assert.NoError(t, err)
+		assert.Equal(t, &UnikernelConfig{
+			UnikernelType:   "linux",
+			UnikernelBinary: "/bzImage",
+			UnikernelCmd:    "console=ttyS0",
+			Hypervisor:      "qemu",
+		}, config)
+	})
+
+	t.Run("no binary", func(t *testing.T) {
+		t.Parallel()
+		spec := newSpec(t, map[string][]byte{"/bin/sh": []byte("#!/bin/sh\n")}, nil)
+		_, err := detectUnikernelConfig(t.TempDir(), spec, detection, monitors)
+		assert.ErrorIs(t, err, ErrNoUnikernelBinary)
+	})
+
+	t.Run("unknown binary", func(t *testing.T) {
+		t.Parallel()
+		spec := newSpec(t, map[string][]byte{"/kernel": []byte("not a kernel")}, nil)
+		_, err := detectUnikernelConfig(t.TempDir(), spec, detection, monitors)
+		assert.ErrorContains(t, err, "unknown unikernel binary format")
+	})
+
+	t.Run("no installed monitor", func(t *testing.T) {
+		t.Parallel()
+		spec := newSpec(t, map[string][]byte{"/kernel": bzImage}, nil)
+		_, err := detectUnikernelConfig(t.TempDir(), spec, detection, map[string]types.MonitorConfig{
+			"firecracker": {BinaryPath: "/usr/local/bin/firecracker"},
+		})
+		assert.ErrorContains(t, err, "is installed")
+	})
+}
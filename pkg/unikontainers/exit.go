@@ -0,0 +1,102 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+	"golang.org/x/sys/unix"
+)
+
+// GuestExitError is returned by Exec, when the monitor executed as a child
+// of urunc and its guest exited with a non-zero exit code. The container
+// must exit with that code.
+type GuestExitError struct {
+	types.GuestExit
+}
+
+func (e *GuestExitError) Error() string {
+	if e.Panic {
+		return fmt.Sprintf("the guest panicked (exit code %d)", e.Code)
+	}
+	return fmt.Sprintf("the guest exited with code %d", e.Code)
+}
+
+// guestExit maps the wait status of a monitor to the exit of its guest. A
+// monitor that got killed by a signal exits with 128 plus the signal, as
+// in shells.
+func guestExit(ws unix.WaitStatus, mapper types.ExitMapper) types.GuestExit {
+	if ws.Signaled() {
+		return types.GuestExit{Code: 128 + int(ws.Signal())}
+	}
+	return mapper(ws.ExitStatus())
+}
+
+// monitorExit is the ExitMapper of the guests that do not report their
+// exit through a device of the monitor. The exit status of the monitor is
+// the exit code of the guest.
+func monitorExit(status int) types.GuestExit {
+	return types.GuestExit{Code: status}
+}
+
+// supervisesMonitor returns true if the monitor must execute as a child of
+// the current process, instead of replacing it. Besides mapping the exit of
+// the guest, the current process must outlive the monitor to wait for the
+// console logger, which otherwise gets killed along with the rest of the
+// container as soon as the monitor exits. The logger would then lose the
+// end of the console and the crash it detects last.
+func (u *Unikontainer) supervisesMonitor(mapper types.ExitMapper) bool {
+	return mapper != nil || u.UruncCfg.Console.Enabled || u.UruncCfg.Crash.Enabled
+}
+
+// superviseMonitor runs the monitor as a child of the current process with
+// run (e.g. hypervisors.RunSupervised) and maps its exit status to the exit
+// of the guest. A nil mapper keeps the exit status of the monitor. It
+// returns nil if the guest exited cleanly and a GuestExitError otherwise. A
+// panic of the guest gets reported as a crash, if stateDir is not nil.
+func (u *Unikontainer) superviseMonitor(run func() (unix.WaitStatus, error), mapper types.ExitMapper, stateDir *os.File) error {
+	if mapper == nil {
+		mapper = monitorExit
+	}
+	ws, err := run()
+	if err != nil {
+		return err
+	}
+	exit := guestExit(ws, mapper)
+	// The console logger reports a crash along with the console
+	u.stopConsoleLog()
+	log := uniklog.WithFields(logrus.Fields{
+		"monitorStatus": ws.ExitStatus(),
+		"exitCode":      exit.Code,
+	})
+	switch {
+	case exit.Panic && stateDir != nil:
+		reportCrash(stateDir, crashConfig{
+			ContainerID:   u.State.ID,
+			UnikernelType: u.State.Annotations[annotType],
+		}, termination{Reason: TerminationCrash, Source: crashSourcePvpanic, Detail: "the monitor exited after a panic of the guest"}, nil)
+	case exit.Panic:
+		log.Error("The guest panicked")
+	default:
+		log.Info("The guest exited")
+	}
+	if exit.Code == 0 {
+		return nil
+	}
+	return &GuestExitError{exit}
+}
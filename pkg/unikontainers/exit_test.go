@@ -0,0 +1,149 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+	"golang.org/x/sys/unix"
+)
+
+func TestGuestExit(t *testing.T) {
+	mapper := func(status int) types.GuestExit {
+		if status == 1 {
+			return types.GuestExit{Code: types.GuestPanicExitCode, Panic: true}
+		}
+		return types.GuestExit{Code: status >> 1}
+	}
+
+	t.Run("mapped exit status", func(t *testing.T) {
+		t.Parallel()
+		// The monitor exited with 7
+		assert.Equal(t, types.GuestExit{Code: 3}, guestExit(unix.WaitStatus(7<<8), mapper))
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, types.GuestExit{Code: types.GuestPanicExitCode, Panic: true},
+			guestExit(unix.WaitStatus(1<<8), mapper))
+	})
+
+	t.Run("killed monitor", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, types.GuestExit{Code: 128 + int(unix.SIGKILL)},
+			guestExit(unix.WaitStatus(unix.SIGKILL), mapper))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		err := &GuestExitError{types.GuestExit{Code: types.GuestPanicExitCode, Panic: true}}
+		assert.EqualError(t, err, "the guest panicked (exit code 255)")
+	})
+}
+
+func TestSuperviseMonitor(t *testing.T) {
+	t.Run("exit status of the monitor without mapper", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{State: &specs.State{}}
+		err := u.superviseMonitor(func() (unix.WaitStatus, error) {
+			return unix.WaitStatus(3 << 8), nil
+		}, nil, nil)
+		var exitErr *GuestExitError
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, 3, exitErr.Code)
+	})
+
+	t.Run("clean exit", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{State: &specs.State{}}
+		err := u.superviseMonitor(func() (unix.WaitStatus, error) {
+			return 0, nil
+		}, nil, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("crash of a supervised monitor", func(t *testing.T) {
+		t.Parallel()
+		baseDir := t.TempDir()
+		stateDir, err := os.Open(baseDir)
+		assert.NoError(t, err)
+		defer stateDir.Close()
+		u := &Unikontainer{
+			BaseDir:  baseDir,
+			State:    &specs.State{ID: "c1", Annotations: map[string]string{annotType: "linux"}},
+			UruncCfg: &UruncConfig{Crash: UruncCrash{Enabled: true}},
+		}
+		panicMapper := func(status int) types.GuestExit {
+			if status == 0 {
+				return types.GuestExit{Code: types.GuestPanicExitCode, Panic: true}
+			}
+			return types.GuestExit{Code: status}
+		}
+		assert.True(t, u.supervisesMonitor(nil))
+		err = u.superviseMonitor(func() (unix.WaitStatus, error) {
+			return hypervisors.RunSupervised("/bin/sh", []string{"sh", "-c", "exit 0"}, nil)
+		}, panicMapper, stateDir)
+		var exitErr *GuestExitError
+		assert.ErrorAs(t, err, &exitErr)
+		assert.True(t, exitErr.Panic)
+		assert.True(t, u.recordTermination())
+		assert.Equal(t, TerminationCrash, u.TerminationReason())
+	})
+
+	t.Run("failed run", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{State: &specs.State{}}
+		err := u.superviseMonitor(func() (unix.WaitStatus, error) {
+			return 0, errors.New("failed to trace the monitor")
+		}, nil, nil)
+		assert.EqualError(t, err, "failed to trace the monitor")
+	})
+}
+
+func TestSupervisesMonitor(t *testing.T) {
+	mapper := func(status int) types.GuestExit {
+		return types.GuestExit{Code: status}
+	}
+
+	t.Run("monitor replaces urunc", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{UruncCfg: &UruncConfig{}}
+		assert.False(t, u.supervisesMonitor(nil))
+	})
+
+	t.Run("exit mapping", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{UruncCfg: &UruncConfig{}}
+		assert.True(t, u.supervisesMonitor(mapper))
+	})
+
+	t.Run("console log", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{UruncCfg: &UruncConfig{Console: UruncConsole{Enabled: true}}}
+		assert.True(t, u.supervisesMonitor(nil))
+	})
+
+	t.Run("crash detection", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{UruncCfg: &UruncConfig{Crash: UruncCrash{Enabled: true}}}
+		assert.True(t, u.supervisesMonitor(nil))
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// guestHugepages holds the hugepages that back the memory of the guest
+type guestHugepages struct {
+	pageSize uint64 // The size of each hugepage in bytes
+	limit    uint64 // The total size of the hugepages in bytes
+}
+
+// parseHugepageSize parses the page size of a hugepage limit, which has
+// the format <size><unit-prefix>B (e.g. 64KB, 2MB or 1GB) with binary
+// prefixes, as in the hugetlb controller of the kernel.
+func parseHugepageSize(pageSize string) (uint64, error) {
+	units := []struct {
+		suffix string
+		bytes  uint64
+	}{
+		{"KB", 1024},
+		{"MB", 1024 * 1024},
+		{"GB", 1024 * 1024 * 1024},
+	}
+	for _, unit := range units {
+		num, found := strings.CutSuffix(pageSize, unit.suffix)
+		if !found {
+			continue
+		}
+		size, err := strconv.ParseUint(num, 10, 64)
+		if err != nil || size == 0 {
+			break
+		}
+		return size * unit.bytes, nil
+	}
+	return 0, fmt.Errorf("invalid hugepage size %q", pageSize)
+}
+
+// getGuestHugepages returns the hugepages that the container requests in
+// its resources, or nil if it does not request any. If the container
+// requests hugepages of more than one size, the largest ones are used.
+func getGuestHugepages(resources *specs.LinuxResources) (*guestHugepages, error) {
+	if resources == nil {
+		return nil, nil
+	}
+	var hugepages *guestHugepages
+	for _, limit := range resources.HugepageLimits {
+		if limit.Limit == 0 {
+			continue
+		}
+		pageSize, err := parseHugepageSize(limit.Pagesize)
+		if err != nil {
+			return nil, err
+		}
+		if hugepages == nil || pageSize > hugepages.pageSize {
+			hugepages = &guestHugepages{pageSize: pageSize, limit: limit.Limit}
+		}
+	}
+	return hugepages, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGuestHugepages(t *testing.T) {
+	t.Run("no hugepages", func(t *testing.T) {
+		t.Parallel()
+		hugepages, err := getGuestHugepages(nil)
+		assert.NoError(t, err)
+		assert.Nil(t, hugepages)
+
+		hugepages, err = getGuestHugepages(&specs.LinuxResources{
+			HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 0}},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, hugepages)
+	})
+
+	t.Run("largest page size", func(t *testing.T) {
+		t.Parallel()
+		hugepages, err := getGuestHugepages(&specs.LinuxResources{
+			HugepageLimits: []specs.LinuxHugepageLimit{
+				{Pagesize: "2MB", Limit: 512 * 1024 * 1024},
+				{Pagesize: "1GB", Limit: 2 * 1024 * 1024 * 1024},
+				{Pagesize: "64KB", Limit: 0},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, &guestHugepages{pageSize: 1024 * 1024 * 1024, limit: 2 * 1024 * 1024 * 1024}, hugepages)
+	})
+
+	t.Run("invalid page size", func(t *testing.T) {
+		t.Parallel()
+		for _, pageSize := range []string{"2M", "MB", "0MB", "2TB"} {
+			_, err := getGuestHugepages(&specs.LinuxResources{
+				HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: pageSize, Limit: 1024}},
+			})
+			assert.ErrorContains(t, err, "invalid hugepage size", pageSize)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIError is returned when the API server of a monitor responds
+// with an unsuccessful status code
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("monitor api error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// apiClient sends JSON requests to the REST API that a monitor exposes
+// over a unix socket
+type apiClient struct {
+	http *http.Client
+	// errMessage extracts the error message from the body of an
+	// unsuccessful response. If nil, the whole body is used.
+	errMessage func([]byte) string
+}
+
+func newAPIClient(socketPath string, timeout time.Duration) *apiClient {
+	transport := &http.Transport{
+		DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+			return dialUnix(socketPath, timeout)
+		},
+	}
+	return &apiClient{
+		http: &http.Client{Transport: transport, Timeout: timeout},
+	}
+}
+
+// do sends a request to the API server. The host part of the URL is
+// ignored, since the connection always goes through the unix socket.
+func (c *apiClient) do(method string, path string, body any, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request for %s: %w", path, err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	u := url.URL{Scheme: "http", Host: "localhost", Path: path}
+	req, err := http.NewRequest(method, u.String(), reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if c.errMessage != nil {
+			apiErr.Message = c.errMessage(data)
+		} else {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return apiErr
+	}
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"strings"
+)
+
+// cmdBuilder builds the arguments of a monitor's execution one argument
+// at a time. Values such as paths are never split, so they can contain
+// spaces or any other character.
+type cmdBuilder struct {
+	args []string
+}
+
+func newCmdBuilder(path string) *cmdBuilder {
+	return &cmdBuilder{args: []string{path}}
+}
+
+// add appends each of the given arguments as is.
+func (b *cmdBuilder) add(args ...string) {
+	b.args = append(b.args, args...)
+}
+
+// addOpt appends an option and its value as two arguments, only if the
+// value is not empty.
+func (b *cmdBuilder) addOpt(opt string, value string) {
+	if value != "" {
+		b.args = append(b.args, opt, value)
+	}
+}
+
+// addFragment appends the whitespace separated arguments of a command
+// line fragment. Unikernels return their monitor specific arguments as
+// such fragments and they never contain values with spaces.
+func (b *cmdBuilder) addFragment(fragment string) {
+	b.args = append(b.args, strings.Fields(fragment)...)
+}
+
+func (b *cmdBuilder) build() []string {
+	return b.args
+}
+
+// qemuEscape escapes a value that is part of a QEMU option list, where a
+// comma separates options and a double comma is a literal comma.
+func qemuEscape(value string) string {
+	return strings.ReplaceAll(value, ",", ",,")
+}
+
+// qemuOpt returns a key=value pair of a QEMU option list.
+func qemuOpt(key string, value string) string {
+	return key + "=" + qemuEscape(value)
+}
+
+// quoteOptValue quotes a value of a key=value option list, as parsed by
+// Cloud Hypervisor and crosvm, if the value contains a comma.
+func quoteOptValue(value string) string {
+	if !strings.Contains(value, ",") {
+		return value
+	}
+	return `"` + value + `"`
+}
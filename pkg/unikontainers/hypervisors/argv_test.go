@@ -0,0 +1,189 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+func TestCmdBuilder(t *testing.T) {
+	t.Parallel()
+	cmd := newCmdBuilder("/usr/bin/monitor")
+	cmd.add("--kernel", "/my images/app")
+	cmd.addOpt("--initrd", "")
+	cmd.addOpt("--disk", "/dev/dm-1")
+	cmd.addFragment(" -no-reboot  -nodefaults ")
+	cmd.addFragment("")
+	assert.Equal(t, []string{
+		"/usr/bin/monitor",
+		"--kernel", "/my images/app",
+		"--disk", "/dev/dm-1",
+		"-no-reboot", "-nodefaults",
+	}, cmd.build())
+}
+
+func TestOptValueEscaping(t *testing.T) {
+	t.Run("qemu", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, "file=/dev/dm-1", qemuOpt("file", "/dev/dm-1"))
+		assert.Equal(t, "file=/a,,b,,,,c", qemuOpt("file", "/a,b,,c"))
+		assert.Equal(t, "path=/my rootfs", qemuOpt("path", "/my rootfs"))
+	})
+
+	t.Run("key=value option lists", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, "/my rootfs", quoteOptValue("/my rootfs"))
+		assert.Equal(t, `"/a,b"`, quoteOptValue("/a,b"))
+	})
+}
+
+// TestBuildExecCmdAwkwardPaths checks the complete command line of each
+// monitor, when the paths it gets contain spaces and commas.
+func TestBuildExecCmdAwkwardPaths(t *testing.T) {
+	const (
+		kernel = "/var/lib/my images/app,v2"
+		block  = "/dev/disk/by-id/disk 1,a"
+		initrd = "/boot/init rd.img"
+		shared = "/run/my rootfs"
+		socket = "/run/urunc/a,b/monitor.sock"
+		cmdStr = "console=ttyS0 root=/dev/vda"
+	)
+	args := types.ExecArgs{
+		UnikernelPath: kernel,
+		InitrdPath:    initrd,
+		Command:       cmdStr,
+		MemSizeB:      256 * 1000 * 1000,
+		MonitorSocket: socket,
+		Machine:       "q35",
+		Sharedfs:      types.SharedfsParams{Type: "9pfs", Path: shared},
+	}
+	ukernel := &fakeUnikernel{blocks: []types.MonitorBlockArgs{{ID: "vol1", Path: block}}}
+
+	tests := []struct {
+		name     string
+		vmm      types.VMM
+		expected []string
+	}{
+		{
+			name: "qemu",
+			vmm:  &Qemu{binaryPath: "/usr/bin/qemu-system-x86_64"},
+			expected: []string{
+				"/usr/bin/qemu-system-x86_64",
+				"-m", "256M",
+				"-L", "/usr/share/qemu",
+				"-cpu", "host",
+				"-enable-kvm",
+				"-display", "none", "-vga", "none", "-serial", "stdio", "-monitor", "null",
+				"-qmp", "unix:/run/urunc/a,,b/monitor.sock,server=on,wait=off",
+				"-M", "q35",
+				"-kernel", kernel,
+				"-nic", "none",
+				"-device", "virtio-blk-pci,serial=vol1,drive=vol1,scsi=off",
+				"-drive", "format=raw,if=none,id=vol1,file=/dev/disk/by-id/disk 1,,a",
+				"-initrd", initrd,
+				"-fsdev", "local,id=rootfs9p,security_model=none,path=/run/my rootfs",
+				"-device", "virtio-9p-pci,fsdev=rootfs9p,mount_tag=fs0",
+				"-append", cmdStr,
+			},
+		},
+		{
+			name: "hvt",
+			vmm:  &HVT{binaryPath: "/usr/bin/solo5-hvt"},
+			expected: []string{
+				"/usr/bin/solo5-hvt",
+				"--mem=256",
+				"--block:vol1=" + block,
+				kernel,
+				cmdStr,
+			},
+		},
+		{
+			name: "spt",
+			vmm:  &SPT{binaryPath: "/usr/bin/solo5-spt"},
+			expected: []string{
+				"/usr/bin/solo5-spt",
+				"--mem=256",
+				"--block:vol1=" + block,
+				kernel,
+				cmdStr,
+			},
+		},
+		{
+			name: "cloud-hypervisor",
+			vmm:  &CloudHypervisor{binaryPath: "/usr/bin/cloud-hypervisor"},
+			expected: []string{
+				"/usr/bin/cloud-hypervisor",
+				"--memory", "size=256M",
+				"--kernel", kernel,
+				"--api-socket", `path="/run/urunc/a,b/monitor.sock"`,
+				"--console", "off", "--serial", "tty",
+				"--seccomp", "false",
+				"--disk", `path="/dev/disk/by-id/disk 1,a",id=vol1`,
+				"--initramfs", initrd,
+				"--cmdline", cmdStr,
+			},
+		},
+		{
+			name: "crosvm",
+			vmm:  &Crosvm{binaryPath: "/usr/bin/crosvm"},
+			expected: []string{
+				"/usr/bin/crosvm", "run",
+				"--mem", "256",
+				"--serial", "type=stdout,hardware=serial,num=1,console=true,stdin=true",
+				"--disable-sandbox",
+				"--block", `path="/dev/disk/by-id/disk 1,a",id=vol1`,
+				"--initrd", initrd,
+				"--shared-dir", "/run/my rootfs:fs0:type=p9",
+				"--params", cmdStr,
+				kernel,
+			},
+		},
+		{
+			name: "kvmtool",
+			vmm:  &Kvmtool{binaryPath: "/usr/bin/lkvm"},
+			expected: []string{
+				"/usr/bin/lkvm", "run",
+				"--mem", "256",
+				"--kernel", kernel,
+				"--console", "serial",
+				"--network", "mode=none",
+				"--disk", block,
+				"--initrd", initrd,
+				"--9p", "/run/my rootfs,fs0",
+				"--params", cmdStr,
+			},
+		},
+		{
+			name: "firecracker api",
+			vmm:  &FirecrackerAPI{&Firecracker{binaryPath: "/usr/bin/firecracker"}},
+			expected: []string{
+				"/usr/bin/firecracker",
+				"--api-sock", socket,
+				"--no-seccomp",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cmd, err := tc.vmm.BuildExecCmd(args, ukernel)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, cmd)
+		})
+	}
+}
@@ -16,7 +16,8 @@ package hypervisors
 
 import (
 	"fmt"
-	"strings"
+	"runtime"
+	"strconv"
 
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
 )
@@ -35,22 +36,41 @@ func (ch *CloudHypervisor) Stop(pid int) error {
 	return killProcess(pid)
 }
 
-func (ch *CloudHypervisor) Ok() error {
-	return nil
+// StopWithSocket shuts Cloud Hypervisor down through its API socket and
+// waits for it to exit. If that fails, the monitor process gets killed.
+func (ch *CloudHypervisor) StopWithSocket(socketPath string, pid int) error {
+	client := NewCloudHypervisorClient(socketPath, DefaultCloudHypervisorAPITimeout)
+	err := client.ShutdownVMM()
+	if err != nil {
+		vmmLog.WithError(err).Warn("failed to shut down cloud-hypervisor through its api socket")
+		return ch.Stop(pid)
+	}
+	if waitProcessExit(pid, cloudHypervisorStopTimeout) {
+		return nil
+	}
+	vmmLog.Warnf("cloud-hypervisor with pid %d did not exit in time, killing it", pid)
+	return ch.Stop(pid)
 }
 
-// UsesKVM returns true as Cloud Hypervisor is a KVM-based VMM
-func (ch *CloudHypervisor) UsesKVM() bool {
-	return true
+func (ch *CloudHypervisor) Ok() error {
+	return nil
 }
 
-// SupportsSharedfs returns true as Cloud Hypervisor supports virtiofs
-func (ch *CloudHypervisor) SupportsSharedfs(fsType string) bool {
-	switch fsType {
-	case "virtio":
-		return true
-	default:
-		return false
+// Capabilities returns the features that Cloud Hypervisor supports. The
+// guest can get paused and snapshotted and devices can get hotplugged
+// through the API socket.
+func (ch *CloudHypervisor) Capabilities() types.VMMCapabilities {
+	return types.VMMCapabilities{
+		KVM:         true,
+		Block:       true,
+		Virtiofs:    true,
+		MultiNIC:    true,
+		Hotplug:     true,
+		Pause:       true,
+		Snapshot:    true,
+		Hugepages:   true,
+		PanicEvents: true,
+		Archs:       commonArchs,
 	}
 }
 
@@ -61,43 +81,60 @@ func (ch *CloudHypervisor) Path() string {
 // BuildExecCmd builds and validates the Cloud Hypervisor command arguments without executing.
 func (ch *CloudHypervisor) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
 	chMem := BytesToStringMB(args.MemSizeB)
+	if args.HugepageSize != 0 {
+		memMiB, err := hugepageMemMiB(args.MemSizeB, args.HugepageSize)
+		if err != nil {
+			return nil, err
+		}
+		chMem = strconv.FormatUint(memMiB, 10)
+	}
 
 	// Start building the command
-	exArgs := []string{ch.binaryPath}
+	cmd := newCmdBuilder(ch.binaryPath)
 
 	// Memory configuration
+	memory := fmt.Sprintf("size=%sM", chMem)
 	if args.Sharedfs.Type == "virtiofs" {
-		exArgs = append(exArgs, "--memory", fmt.Sprintf("size=%sM,shared=on", chMem))
-	} else {
-		exArgs = append(exArgs, "--memory", fmt.Sprintf("size=%sM", chMem))
+		memory += ",shared=on"
+	}
+	if args.HugepageSize != 0 {
+		// Cloud Hypervisor allocates the hugepages through memfd and it
+		// does not use the hugetlbfs mount
+		memory += ",hugepages=on,hugepage_size=" + hugepageSizeString(args.HugepageSize)
 	}
+	cmd.add("--memory", memory)
 
 	// CPU configuration
 	if args.VCPUs > 0 {
-		exArgs = append(exArgs, "--cpus", fmt.Sprintf("boot=%d", args.VCPUs))
+		cmd.add("--cpus", fmt.Sprintf("boot=%d", args.VCPUs))
 	}
 
 	// Kernel path
-	exArgs = append(exArgs, "--kernel", args.UnikernelPath)
+	cmd.add("--kernel", args.UnikernelPath)
+
+	// API socket configuration
+	if args.MonitorSocket != "" {
+		cmd.add("--api-socket", "path="+quoteOptValue(args.MonitorSocket))
+	}
 
 	// Console configuration - disable graphical output
-	exArgs = append(exArgs, "--console", "off", "--serial", "tty")
+	cmd.add("--console", "off", "--serial", "tty")
 
 	// Seccomp configuration
 	if args.Seccomp {
-		exArgs = append(exArgs, "--seccomp", "true")
+		cmd.add("--seccomp", "true")
 	} else {
-		exArgs = append(exArgs, "--seccomp", "false")
+		cmd.add("--seccomp", "false")
 	}
 
 	// Network configuration
-	if args.Net.TapDev != "" {
-		netCli := ukernel.MonitorNetCli(args.Net.TapDev, args.Net.MAC)
+	for _, nic := range args.Nets {
+		netCli := ukernel.MonitorNetCli(nic.TapDev, nic.MAC)
 		if netCli == "" {
 			// Default network configuration for Cloud Hypervisor
-			exArgs = append(exArgs, "--net", fmt.Sprintf("tap=%s,mac=%s", args.Net.TapDev, args.Net.MAC))
+			cmd.add("--net", fmt.Sprintf("tap=%s,mac=%s", nic.TapDev, nic.MAC))
 		} else {
-			exArgs = append(exArgs, strings.Split(strings.TrimSpace(netCli), " ")...)
+			cmd.addFragment(netCli)
 		}
 	}
 
@@ -105,52 +142,164 @@ func (ch *CloudHypervisor) BuildExecCmd(args types.ExecArgs, ukernel types.Unike
 	blockArgs := ukernel.MonitorBlockCli()
 	for _, blockArg := range blockArgs {
 		if blockArg.ExactArgs != "" {
-			exArgs = append(exArgs, strings.Split(strings.TrimSpace(blockArg.ExactArgs), " ")...)
+			cmd.addFragment(blockArg.ExactArgs)
 		} else if blockArg.Path != "" {
-			diskArg := fmt.Sprintf("path=%s", blockArg.Path)
+			diskArg := "path=" + quoteOptValue(blockArg.Path)
 			if blockArg.ID != "" {
-				diskArg += fmt.Sprintf(",id=%s", blockArg.ID)
+				diskArg += ",id=" + blockArg.ID
 			}
-			exArgs = append(exArgs, "--disk", diskArg)
+			cmd.add("--disk", diskArg)
 		}
 	}
 
 	// Initrd configuration
-	if args.InitrdPath != "" {
-		exArgs = append(exArgs, "--initramfs", args.InitrdPath)
-	}
+	cmd.addOpt("--initramfs", args.InitrdPath)
 
 	// Check for extra initrd from unikernel monitor args
 	extraMonArgs := ukernel.MonitorCli()
-	if extraMonArgs.ExtraInitrd != "" {
-		exArgs = append(exArgs, "--initramfs", extraMonArgs.ExtraInitrd)
-	}
+	cmd.addOpt("--initramfs", extraMonArgs.ExtraInitrd)
 
 	switch args.Sharedfs.Type {
 	case "virtiofs":
-		exArgs = append(exArgs, "--fs", "tag=fs0,socket=/tmp/vhostqemu")
+		cmd.add("--fs", "tag=fs0,socket=/tmp/vhostqemu")
 	default:
 		// No shared filesystem
 	}
 
 	if args.VAccelType == "vsock" {
-		exArgs = append(exArgs, "--vsock", fmt.Sprintf("cid=%d,socket=%s/vaccel.sock",
-			args.VSockDevID, args.VSockDevPath))
+		socket := args.VSockDevPath + "/vaccel.sock"
+		cmd.add("--vsock", fmt.Sprintf("cid=%d,socket=%s", args.VSockDevID, quoteOptValue(socket)))
 	}
 
-	if extraMonArgs.OtherArgs != "" {
-		exArgs = append(exArgs, strings.Split(strings.TrimSpace(extraMonArgs.OtherArgs), " ")...)
+	// The guest reports a panic through pvpanic and Cloud Hypervisor
+	// writes a panic event to the event monitor
+	if args.EventFd != 0 {
+		cmd.add("--pvpanic", "--event-monitor", "fd="+strconv.Itoa(args.EventFd))
 	}
 
+	cmd.addFragment(extraMonArgs.OtherArgs)
+
 	// Add the command line arguments for the kernel
-	exArgs = append(exArgs, "--cmdline", args.Command)
+	cmd.add("--cmdline", args.Command)
 
+	exArgs := cmd.build()
 	vmmLog.WithField("cloud-hypervisor command", exArgs).Debug("Ready to execve cloud-hypervisor")
 
 	return exArgs, nil
 }
 
-// PreExec performs pre-execution setup. Cloud Hypervisor has no special pre-exec requirements.
-func (ch *CloudHypervisor) PreExec(_ types.ExecArgs) error {
-	return nil
+// cloudHypervisorSeccompSyscalls returns the system calls that the Cloud
+// Hypervisor process requires, including the ones for loading its own
+// filters.
+func cloudHypervisorSeccompSyscalls() []string {
+	syscalls := []string{
+		"open",
+		"openat",
+		"close",
+		"read",
+		"write",
+		"readv",
+		"writev",
+		"pread64",
+		"pwrite64",
+		"preadv",
+		"pwritev",
+		"preadv2",
+		"pwritev2",
+		"lseek",
+		"fstat",
+		"statx",
+		"fstatfs",
+		"statfs",
+		"access",
+		"faccessat",
+		"faccessat2",
+		"readlink",
+		"readlinkat",
+		"getdents64",
+		"getcwd",
+		"fcntl",
+		"ioctl",
+		"dup",
+		"dup2",
+		"dup3",
+		"pipe2",
+		"eventfd2",
+		"fallocate",
+		"fsync",
+		"fdatasync",
+		"ftruncate",
+		"unlink",
+		"unlinkat",
+		"mkdir",
+		"mkdirat",
+		"umask",
+		"memfd_create",
+		"io_setup",
+		"io_submit",
+		"io_getevents",
+		"io_destroy",
+		"io_uring_setup",
+		"io_uring_enter",
+		"io_uring_register",
+		"mremap",
+		"mbind",
+		"brk",
+		"sched_getaffinity",
+		"sched_setaffinity",
+		"prctl",
+		"seccomp",
+		"arch_prctl",
+		"capget",
+		"getuid",
+		"geteuid",
+		"getgid",
+		"getegid",
+		"getppid",
+		"getrandom",
+		"uname",
+		"sysinfo",
+		"kill",
+		"tkill",
+		"wait4",
+		"signalfd4",
+		"restart_syscall",
+		"clock_nanosleep",
+		"timerfd_create",
+		"timerfd_settime",
+		"timer_create",
+		"timer_settime",
+		"timer_delete",
+		"poll",
+		"ppoll",
+		"epoll_create1",
+		"epoll_wait",
+		"socket",
+		"socketpair",
+		"bind",
+		"listen",
+		"accept4",
+		"connect",
+		"getsockname",
+		"getpeername",
+		"getsockopt",
+		"setsockopt",
+		"recvfrom",
+		"recvmsg",
+		"sendmsg",
+		"sendto",
+		"shutdown",
+	}
+	// The architectures name the stat system call differently
+	if runtime.GOARCH == "arm64" {
+		syscalls = append(syscalls, "fstatat")
+	} else {
+		syscalls = append(syscalls, "newfstatat")
+	}
+	return append(syscalls, goRuntimeSyscalls...)
+}
+
+// PreExec applies the seccomp profile of the container to Cloud Hypervisor.
+func (ch *CloudHypervisor) PreExec(args types.ExecArgs) error {
+	return loadSeccompProfile(args, cloudHypervisorSeccompSyscalls())
 }
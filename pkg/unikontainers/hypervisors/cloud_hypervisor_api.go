@@ -0,0 +1,148 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultCloudHypervisorAPITimeout = 5 * time.Second
+	cloudHypervisorAPIPrefix         = "/api/v1/"
+	cloudHypervisorStopTimeout       = 5 * time.Second
+)
+
+// CloudHypervisorVMInfo is the response of vm.info
+type CloudHypervisorVMInfo struct {
+	State            string          `json:"state"`
+	MemoryActualSize uint64          `json:"memory_actual_size"`
+	Config           json.RawMessage `json:"config"`
+}
+
+// CloudHypervisorResize is the request of vm.resize. Only the set fields
+// get resized.
+type CloudHypervisorResize struct {
+	DesiredVCPUs   *uint   `json:"desired_vcpus,omitempty"`
+	DesiredRAM     *uint64 `json:"desired_ram,omitempty"`
+	DesiredBalloon *uint64 `json:"desired_balloon,omitempty"`
+}
+
+type CloudHypervisorDisk struct {
+	Path     string `json:"path"`
+	ReadOnly bool   `json:"readonly,omitempty"`
+	ID       string `json:"id,omitempty"`
+}
+
+type CloudHypervisorNet struct {
+	Tap string `json:"tap,omitempty"`
+	MAC string `json:"mac,omitempty"`
+	ID  string `json:"id,omitempty"`
+}
+
+// CloudHypervisorSnapshot is the request of vm.snapshot. The destination is
+// a file URL of the directory where the snapshot gets stored.
+type CloudHypervisorSnapshot struct {
+	DestinationURL string `json:"destination_url"`
+}
+
+// CloudHypervisorPciDevice describes a hotplugged device
+type CloudHypervisorPciDevice struct {
+	ID  string `json:"id"`
+	BDF string `json:"bdf"`
+}
+
+// CloudHypervisorCounters holds the counters of each device of the VM
+type CloudHypervisorCounters map[string]map[string]uint64
+
+// CloudHypervisorClient is a client for the REST API that Cloud Hypervisor
+// exposes over its API unix socket.
+type CloudHypervisorClient struct {
+	api *apiClient
+}
+
+// NewCloudHypervisorClient returns a client for the API socket at
+// socketPath. The timeout applies to every request.
+func NewCloudHypervisorClient(socketPath string, timeout time.Duration) *CloudHypervisorClient {
+	return &CloudHypervisorClient{api: newAPIClient(socketPath, timeout)}
+}
+
+func (c *CloudHypervisorClient) do(method string, endpoint string, body any, result any) error {
+	return c.api.do(method, cloudHypervisorAPIPrefix+endpoint, body, result)
+}
+
+// Ping checks if the API server of Cloud Hypervisor responds
+func (c *CloudHypervisorClient) Ping() error {
+	return c.do(http.MethodGet, "vmm.ping", nil, nil)
+}
+
+// Info returns the state and the configuration of the VM
+func (c *CloudHypervisorClient) Info() (CloudHypervisorVMInfo, error) {
+	var info CloudHypervisorVMInfo
+	err := c.do(http.MethodGet, "vm.info", nil, &info)
+	return info, err
+}
+
+// Shutdown shuts the VM down, while Cloud Hypervisor keeps running
+func (c *CloudHypervisorClient) Shutdown() error {
+	return c.do(http.MethodPut, "vm.shutdown", nil, nil)
+}
+
+// ShutdownVMM shuts the VM down and terminates Cloud Hypervisor
+func (c *CloudHypervisorClient) ShutdownVMM() error {
+	return c.do(http.MethodPut, "vmm.shutdown", nil, nil)
+}
+
+// Pause pauses the execution of the VM
+func (c *CloudHypervisorClient) Pause() error {
+	return c.do(http.MethodPut, "vm.pause", nil, nil)
+}
+
+// Resume resumes the execution of a paused VM
+func (c *CloudHypervisorClient) Resume() error {
+	return c.do(http.MethodPut, "vm.resume", nil, nil)
+}
+
+// Snapshot creates a snapshot of a paused VM
+func (c *CloudHypervisorClient) Snapshot(snapshot CloudHypervisorSnapshot) error {
+	return c.do(http.MethodPut, "vm.snapshot", snapshot, nil)
+}
+
+// Resize changes the vCPUs, memory or balloon size of a running VM
+func (c *CloudHypervisorClient) Resize(resize CloudHypervisorResize) error {
+	return c.do(http.MethodPut, "vm.resize", resize, nil)
+}
+
+// AddDisk hotplugs a disk to the VM
+func (c *CloudHypervisorClient) AddDisk(disk CloudHypervisorDisk) (CloudHypervisorPciDevice, error) {
+	var dev CloudHypervisorPciDevice
+	err := c.do(http.MethodPut, "vm.add-disk", disk, &dev)
+	return dev, err
+}
+
+// AddNet hotplugs a network device to the VM
+func (c *CloudHypervisorClient) AddNet(netDev CloudHypervisorNet) (CloudHypervisorPciDevice, error) {
+	var dev CloudHypervisorPciDevice
+	err := c.do(http.MethodPut, "vm.add-net", netDev, &dev)
+	return dev, err
+}
+
+// Counters returns the counters of the devices of the VM
+func (c *CloudHypervisorClient) Counters() (CloudHypervisorCounters, error) {
+	var counters CloudHypervisorCounters
+	err := c.do(http.MethodGet, "vm.counters", nil, &counters)
+	return counters, err
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+// fakeCloudHypervisorAPI emulates the API server of Cloud Hypervisor over
+// a unix socket and records the requests it receives
+type fakeCloudHypervisorAPI struct {
+	socket     string
+	mu         sync.Mutex
+	requests   []fcRequest
+	onShutdown func()
+}
+
+func newFakeCloudHypervisorAPI(t *testing.T) *fakeCloudHypervisorAPI {
+	t.Helper()
+	f := &fakeCloudHypervisorAPI{socket: filepath.Join(t.TempDir(), MonitorSocketName)}
+	l, err := net.Listen("unix", f.socket)
+	assert.NoError(t, err)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(f.handle))
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return f
+}
+
+func (f *fakeCloudHypervisorAPI) handle(w http.ResponseWriter, r *http.Request) {
+	req := fcRequest{Method: r.Method, Path: strings.TrimPrefix(r.URL.Path, cloudHypervisorAPIPrefix)}
+	data, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(data, &req.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	switch req.Path {
+	case "vm.info":
+		_, _ = w.Write([]byte(`{"config": {"cpus": {"boot_vcpus": 1}}, "state": "Running", "memory_actual_size": 268435456}`))
+	case "vm.counters":
+		_, _ = w.Write([]byte(`{"_net2": {"rx_bytes": 1024, "tx_bytes": 512}, "_disk0": {"read_ops": 3}}`))
+	case "vm.add-disk":
+		_, _ = w.Write([]byte(`{"id": "_disk1", "bdf": "0000:00:06.0"}`))
+	case "vm.add-net":
+		_, _ = w.Write([]byte(`{"id": "_net3", "bdf": "0000:00:07.0"}`))
+	case "vm.resume":
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("Error from API: The VM could not be resumed\n"))
+	case "vmm.shutdown":
+		if f.onShutdown != nil {
+			f.onShutdown()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeCloudHypervisorAPI) received() []fcRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fcRequest(nil), f.requests...)
+}
+
+func TestCloudHypervisorClient(t *testing.T) {
+	t.Run("queries", func(t *testing.T) {
+		t.Parallel()
+		f := newFakeCloudHypervisorAPI(t)
+		c := NewCloudHypervisorClient(f.socket, time.Second)
+
+		assert.NoError(t, c.Ping())
+		info, err := c.Info()
+		assert.NoError(t, err)
+		assert.Equal(t, "Running", info.State)
+		assert.Equal(t, uint64(268435456), info.MemoryActualSize)
+
+		counters, err := c.Counters()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1024), counters["_net2"]["rx_bytes"])
+		assert.Equal(t, uint64(3), counters["_disk0"]["read_ops"])
+	})
+
+	t.Run("operations", func(t *testing.T) {
+		t.Parallel()
+		f := newFakeCloudHypervisorAPI(t)
+		c := NewCloudHypervisorClient(f.socket, time.Second)
+
+		assert.NoError(t, c.Pause())
+		vcpus := uint(2)
+		assert.NoError(t, c.Resize(CloudHypervisorResize{DesiredVCPUs: &vcpus}))
+		disk, err := c.AddDisk(CloudHypervisorDisk{Path: "/dev/dm-2", ReadOnly: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "_disk1", disk.ID)
+		netDev, err := c.AddNet(CloudHypervisorNet{Tap: "tap1_urunc"})
+		assert.NoError(t, err)
+		assert.Equal(t, "0000:00:07.0", netDev.BDF)
+		assert.NoError(t, c.Snapshot(CloudHypervisorSnapshot{DestinationURL: "file:///snap"}))
+		assert.NoError(t, c.Shutdown())
+
+		reqs := f.received()
+		assert.Len(t, reqs, 6)
+		assert.Equal(t, fcRequest{Method: http.MethodPut, Path: "vm.pause"}, reqs[0])
+		assert.Equal(t, fcRequest{Method: http.MethodPut, Path: "vm.resize", Body: map[string]any{"desired_vcpus": float64(2)}}, reqs[1])
+		assert.Equal(t, map[string]any{"path": "/dev/dm-2", "readonly": true}, reqs[2].Body)
+		assert.Equal(t, map[string]any{"tap": "tap1_urunc"}, reqs[3].Body)
+		assert.Equal(t, fcRequest{Method: http.MethodPut, Path: "vm.snapshot", Body: map[string]any{"destination_url": "file:///snap"}}, reqs[4])
+		assert.Equal(t, "vm.shutdown", reqs[5].Path)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		t.Parallel()
+		f := newFakeCloudHypervisorAPI(t)
+		c := NewCloudHypervisorClient(f.socket, time.Second)
+
+		err := c.Resume()
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+		assert.Equal(t, "Error from API: The VM could not be resumed", apiErr.Message)
+	})
+}
+
+func TestCloudHypervisorStopWithSocket(t *testing.T) {
+	t.Parallel()
+	proc := exec.Command("sleep", "30")
+	assert.NoError(t, proc.Start())
+	exited := make(chan struct{})
+	go func() {
+		_ = proc.Wait()
+		close(exited)
+	}()
+
+	f := newFakeCloudHypervisorAPI(t)
+	f.onShutdown = func() {
+		_ = proc.Process.Kill()
+	}
+	ch := &CloudHypervisor{}
+	assert.NoError(t, ch.StopWithSocket(f.socket, proc.Process.Pid))
+	<-exited
+	assert.Equal(t, "vmm.shutdown", f.received()[0].Path)
+}
+
+func TestCloudHypervisorAPISocket(t *testing.T) {
+	t.Parallel()
+	ch := &CloudHypervisor{binaryPath: "/usr/bin/cloud-hypervisor"}
+	args := types.ExecArgs{
+		UnikernelPath: "/unikernel/app",
+		Command:       "app",
+		MonitorSocket: "/" + MonitorSocketName,
+	}
+	cmd, err := ch.BuildExecCmd(args, &fakeUnikernel{})
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(cmd, " "), "--api-socket path=/monitor.sock")
+	assert.True(t, HasMonitorSocket(CloudHypervisorVmm, types.MonitorConfig{}))
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+const (
+	CrosvmVmm    VmmType = "crosvm"
+	CrosvmBinary string  = "crosvm"
+	// crosvmPivotRoot is the directory that crosvm uses as the root of the
+	// minijail sandboxes of its devices. It has to exist in the rootfs of
+	// the monitor.
+	crosvmPivotRoot = "/var/empty"
+)
+
+type Crosvm struct {
+	binaryPath string
+	binary     string
+}
+
+func (c *Crosvm) Stop(pid int) error {
+	return killProcess(pid)
+}
+
+func (c *Crosvm) Ok() error {
+	return nil
+}
+
+// Capabilities returns the features that crosvm supports. Both virtiofs
+// and 9p are implemented by crosvm itself through --shared-dir.
+func (c *Crosvm) Capabilities() types.VMMCapabilities {
+	return types.VMMCapabilities{
+		KVM:             true,
+		Block:           true,
+		NinePfs:         true,
+		Virtiofs:        true,
+		Vsock:           types.VsockVhost,
+		MultiNIC:        true,
+		Archs:           commonArchs,
+		BuiltinVirtiofs: true,
+	}
+}
+
+func (c *Crosvm) Path() string {
+	return c.binaryPath
+}
+
+// BuildExecCmd builds and validates the crosvm command arguments without executing.
+func (c *Crosvm) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
+	cmd := newCmdBuilder(c.binaryPath)
+	cmd.add("run")
+
+	cmd.add("--mem", BytesToStringMB(args.MemSizeB))
+	if args.VCPUs > 0 {
+		cmd.add("--cpus", fmt.Sprintf("%d", args.VCPUs))
+	}
+
+	// Attach the console of the guest to the first serial port
+	cmd.add("--serial", "type=stdout,hardware=serial,num=1,console=true,stdin=true")
+
+	// crosvm jails each device in its own minijail sandbox by default
+	if !args.Seccomp {
+		cmd.add("--disable-sandbox")
+	}
+
+	for _, nic := range args.Nets {
+		netCli := ukernel.MonitorNetCli(nic.TapDev, nic.MAC)
+		if netCli == "" {
+			cmd.add("--net", fmt.Sprintf("tap-name=%s,mac=%s", nic.TapDev, nic.MAC))
+		} else {
+			cmd.addFragment(netCli)
+		}
+	}
+
+	for _, blockArg := range ukernel.MonitorBlockCli() {
+		if blockArg.ExactArgs != "" {
+			cmd.addFragment(blockArg.ExactArgs)
+		} else if blockArg.Path != "" {
+			blockCli := "path=" + quoteOptValue(blockArg.Path)
+			if blockArg.ID != "" {
+				blockCli += ",id=" + blockArg.ID
+			}
+			cmd.add("--block", blockCli)
+		}
+	}
+
+	extraMonArgs := ukernel.MonitorCli()
+	initrd := args.InitrdPath
+	if extraMonArgs.ExtraInitrd != "" {
+		if initrd != "" {
+			return nil, ErrMultipleInitrd
+		}
+		initrd = extraMonArgs.ExtraInitrd
+	}
+	cmd.addOpt("--initrd", initrd)
+
+	switch args.Sharedfs.Type {
+	case "9pfs":
+		cmd.add("--shared-dir", args.Sharedfs.Path+":fs0:type=p9")
+	case "virtiofs":
+		cmd.add("--shared-dir", args.Sharedfs.Path+":fs0:type=fs:cache=always")
+	default:
+		// No shared filesystem
+	}
+
+	if args.VAccelType == "vsock" {
+		cmd.add("--vsock", fmt.Sprintf("cid=%d", args.VSockDevID))
+	}
+
+	cmd.addFragment(extraMonArgs.OtherArgs)
+
+	cmd.add("--params", args.Command, args.UnikernelPath)
+
+	exArgs := cmd.build()
+	vmmLog.WithField("crosvm command", exArgs).Debug("Ready to execve crosvm")
+
+	return exArgs, nil
+}
+
+// PreExec creates the directory that crosvm uses as the root of its
+// device sandboxes, if they are enabled, and applies the seccomp profile
+// of the container to crosvm.
+func (c *Crosvm) PreExec(args types.ExecArgs) error {
+	if !args.Seccomp {
+		return nil
+	}
+	err := os.MkdirAll(crosvmPivotRoot, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create crosvm's sandbox root %s: %w", crosvmPivotRoot, err)
+	}
+	// There is no baseline for crosvm, since each of its device
+	// sandboxes loads its own filter
+	return loadSeccompProfile(args, nil)
+}
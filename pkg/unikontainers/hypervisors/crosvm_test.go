@@ -0,0 +1,120 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+func TestVMMFactoryCrosvm(t *testing.T) {
+	t.Parallel()
+	factory, exists := vmmFactories[CrosvmVmm]
+	assert.True(t, exists, "crosvm factory should exist")
+
+	vmm := factory.createFunc(CrosvmBinary, "/usr/bin/crosvm", true)
+	crosvm, ok := vmm.(*Crosvm)
+	assert.True(t, ok, "factory should return *Crosvm")
+	assert.Equal(t, "/usr/bin/crosvm", crosvm.Path())
+	caps := crosvm.Capabilities()
+	assert.True(t, caps.KVM)
+	assert.True(t, caps.Virtiofs)
+	assert.True(t, caps.NinePfs)
+	assert.Equal(t, types.VsockVhost, caps.Vsock)
+	assert.True(t, caps.BuiltinVirtiofs)
+	assert.False(t, (&Qemu{}).Capabilities().BuiltinVirtiofs)
+}
+
+func TestCrosvmBuildExecCmd(t *testing.T) {
+	crosvm := &Crosvm{binary: CrosvmBinary, binaryPath: "/usr/bin/crosvm"}
+
+	t.Run("minimal", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{
+			UnikernelPath: "/unikernel/vmlinux",
+			Command:       "console=ttyS0 init=/bin/app",
+			MemSizeB:      256 * 1000 * 1000,
+		}
+		cmd, err := crosvm.BuildExecCmd(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"/usr/bin/crosvm", "run",
+			"--mem", "256",
+			"--serial", "type=stdout,hardware=serial,num=1,console=true,stdin=true",
+			"--disable-sandbox",
+			"--params", "console=ttyS0 init=/bin/app",
+			"/unikernel/vmlinux",
+		}, cmd)
+	})
+
+	t.Run("devices", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{
+			UnikernelPath: "/unikernel/vmlinux",
+			Command:       "console=ttyS0",
+			MemSizeB:      512 * 1000 * 1000,
+			VCPUs:         2,
+			Seccomp:       true,
+			InitrdPath:    "/unikernel/initrd",
+			Nets:          []types.NetDevParams{{TapDev: "tap0_urunc", MAC: "aa:bb:cc:dd:ee:ff"}},
+			VAccelType:    "vsock",
+			VSockDevID:    42,
+		}
+		ukernel := &fakeUnikernel{blocks: []types.MonitorBlockArgs{{ID: "vol1", Path: "/dev/dm-2"}}}
+		cmd, err := crosvm.BuildExecCmd(args, ukernel)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"/usr/bin/crosvm", "run",
+			"--mem", "512",
+			"--cpus", "2",
+			"--serial", "type=stdout,hardware=serial,num=1,console=true,stdin=true",
+			"--net", "tap-name=tap0_urunc,mac=aa:bb:cc:dd:ee:ff",
+			"--block", "path=/dev/dm-2,id=vol1",
+			"--initrd", "/unikernel/initrd",
+			"--vsock", "cid=42",
+			"--params", "console=ttyS0",
+			"/unikernel/vmlinux",
+		}, cmd)
+	})
+
+	t.Run("shared fs", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{UnikernelPath: "/vmlinux", MemSizeB: DefaultMemory * 1000 * 1000}
+		args.Sharedfs = types.SharedfsParams{Type: "virtiofs", Path: "/cntrRootfs"}
+		cmd, err := crosvm.BuildExecCmd(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Contains(t, cmd, "/cntrRootfs:fs0:type=fs:cache=always")
+
+		args.Sharedfs.Type = "9pfs"
+		cmd, err = crosvm.BuildExecCmd(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Contains(t, cmd, "/cntrRootfs:fs0:type=p9")
+	})
+
+	t.Run("initrd from the unikernel", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{UnikernelPath: "/vmlinux", MemSizeB: DefaultMemory * 1000 * 1000}
+		ukernel := &fakeUnikernel{monCli: types.MonitorCliArgs{ExtraInitrd: "/urunit.conf"}}
+		cmd, err := crosvm.BuildExecCmd(args, ukernel)
+		assert.NoError(t, err)
+		assert.Contains(t, cmd, "/urunit.conf")
+
+		args.InitrdPath = "/initrd"
+		_, err = crosvm.BuildExecCmd(args, ukernel)
+		assert.ErrorIs(t, err, ErrMultipleInitrd)
+	})
+}
@@ -19,7 +19,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"runtime"
 
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
 )
@@ -28,6 +28,8 @@ const (
 	FirecrackerVmm    VmmType = "firecracker"
 	FirecrackerBinary string  = "firecracker"
 	FCJsonFilename    string  = "fc.json"
+	// The only hugepage size that Firecracker supports
+	firecrackerHugepageSize = 2 * 1024 * 1024
 )
 
 type Firecracker struct {
@@ -46,6 +48,7 @@ type FirecrackerMachine struct {
 	MemSizeMiB      uint64 `json:"mem_size_mib"`
 	Smt             bool   `json:"smt"`
 	TrackDirtyPages bool   `json:"track_dirty_pages"`
+	HugePages       string `json:"huge_pages,omitempty"`
 }
 
 type FirecrackerDrive struct {
@@ -83,32 +86,31 @@ func (fc *Firecracker) Ok() error {
 	return nil
 }
 
-func (fc *Firecracker) UsesKVM() bool {
-	return true
-}
-
-// SupportsSharedfs returns a bool value depending on the monitor support for shared-fs
-func (fc *Firecracker) SupportsSharedfs(_ string) bool {
-	return false
+// Capabilities returns the features that Firecracker supports
+func (fc *Firecracker) Capabilities() types.VMMCapabilities {
+	return types.VMMCapabilities{
+		KVM:       true,
+		Block:     true,
+		Vsock:     types.VsockUnix,
+		MultiNIC:  true,
+		Hugepages: true,
+		Archs:     commonArchs,
+	}
 }
 
 func (fc *Firecracker) Path() string {
 	return fc.binaryPath
 }
 
-func (fc *Firecracker) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
+// vmConfig returns the configuration of the VM, as expected by
+// Firecracker's config file and API.
+func (fc *Firecracker) vmConfig(args types.ExecArgs, ukernel types.Unikernel) *FirecrackerConfig {
 	// FIXME: Note for getting unikernel specific options.
 	// Due to the way FC operates, we have not encountered any guest specific
 	// options yet. However, we need to revisit how we can use guest specific
 	// options in FC, since the string return value of the Monitor related
 	// functions in the unikernel interface do not integrate well with FC's
 	// json configuration.
-	cmdString := fc.Path() + " --no-api --config-file "
-	JSONConfigFile := filepath.Join("/tmp/", FCJsonFilename)
-	cmdString += JSONConfigFile
-	if !args.Seccomp {
-		cmdString += " --no-seccomp"
-	}
 
 	// VM config for Firecracker
 	fcMem := DefaultMemory
@@ -135,14 +137,19 @@ func (fc *Firecracker) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel
 		Smt:             false,
 		TrackDirtyPages: false,
 	}
+	if args.HugepageSize != 0 {
+		// The size of the hugepages is validated in checkFirecrackerHugepages
+		FCMachine.MemSizeMiB, _ = hugepageMemMiB(args.MemSizeB, args.HugepageSize)
+		FCMachine.HugePages = hugepageSizeString(args.HugepageSize)
+	}
 
 	// Net config for Firecracker
 	FCNet := make([]FirecrackerNet, 0)
-	if args.Net.TapDev != "" {
+	for i, nic := range args.Nets {
 		AnIF := FirecrackerNet{
-			IfaceID:  "net1",
-			GuestMAC: args.Net.MAC,
-			HostIF:   args.Net.TapDev,
+			IfaceID:  fmt.Sprintf("net%d", i+1),
+			GuestMAC: nic.MAC,
+			HostIF:   nic.TapDev,
 		}
 		FCNet = append(FCNet, AnIF)
 	}
@@ -179,13 +186,42 @@ func (fc *Firecracker) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel
 		}
 	}
 
-	FCConfig := &FirecrackerConfig{
+	return &FirecrackerConfig{
 		Source:  FCSource,
 		Machine: FCMachine,
 		Drives:  FCDrives,
 		NetIfs:  FCNet,
 		VSock:   FCVSockDev,
 	}
+}
+
+// checkFirecrackerHugepages checks that the guest memory can be backed by
+// hugepages, since Firecracker only supports hugepages of 2M.
+func checkFirecrackerHugepages(args types.ExecArgs) error {
+	if args.HugepageSize == 0 {
+		return nil
+	}
+	if args.HugepageSize != firecrackerHugepageSize {
+		return fmt.Errorf("%w: firecracker supports only hugepages of %s, but %s were requested", ErrHugepageSize,
+			hugepageSizeString(firecrackerHugepageSize), hugepageSizeString(args.HugepageSize))
+	}
+	_, err := hugepageMemMiB(args.MemSizeB, args.HugepageSize)
+	return err
+}
+
+func (fc *Firecracker) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
+	err := checkFirecrackerHugepages(args)
+	if err != nil {
+		return nil, err
+	}
+	JSONConfigFile := filepath.Join("/tmp/", FCJsonFilename)
+	cmd := newCmdBuilder(fc.Path())
+	cmd.add("--no-api", "--config-file", JSONConfigFile)
+	if !args.Seccomp {
+		cmd.add("--no-seccomp")
+	}
+
+	FCConfig := fc.vmConfig(args, ukernel)
 	FCConfigJSON, err := json.Marshal(FCConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal Firecracker config: %w", err)
@@ -195,11 +231,92 @@ func (fc *Firecracker) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel
 	}
 	vmmLog.WithField("Json", string(FCConfigJSON)).Debug("Firecracker json config")
 
-	exArgs := strings.Split(cmdString, " ")
-	return exArgs, nil
+	return cmd.build(), nil
 }
 
-// PreExec performs pre-execution setup. Firecracker has no special pre-exec requirements.
-func (fc *Firecracker) PreExec(_ types.ExecArgs) error {
-	return nil
+// firecrackerSeccompSyscalls returns the system calls that the Firecracker
+// process requires, including the ones for loading its own filters.
+func firecrackerSeccompSyscalls() []string {
+	syscalls := []string{
+		"open",
+		"openat",
+		"close",
+		"read",
+		"write",
+		"readv",
+		"writev",
+		"pread64",
+		"pwrite64",
+		"preadv",
+		"pwritev",
+		"lseek",
+		"fstat",
+		"statx",
+		"fstatfs",
+		"statfs",
+		"access",
+		"faccessat",
+		"readlink",
+		"readlinkat",
+		"fcntl",
+		"ioctl",
+		"dup",
+		"dup3",
+		"pipe2",
+		"eventfd2",
+		"fsync",
+		"fdatasync",
+		"ftruncate",
+		"unlink",
+		"unlinkat",
+		"mkdir",
+		"mkdirat",
+		"memfd_create",
+		"io_uring_setup",
+		"io_uring_enter",
+		"io_uring_register",
+		"mremap",
+		"brk",
+		"sched_getaffinity",
+		"prctl",
+		"seccomp",
+		"arch_prctl",
+		"getuid",
+		"geteuid",
+		"getrandom",
+		"uname",
+		"sysinfo",
+		"kill",
+		"tkill",
+		"restart_syscall",
+		"clock_nanosleep",
+		"timerfd_create",
+		"timerfd_settime",
+		"poll",
+		"ppoll",
+		"epoll_create1",
+		"epoll_wait",
+		"socket",
+		"bind",
+		"listen",
+		"accept4",
+		"connect",
+		"getsockname",
+		"recvfrom",
+		"recvmsg",
+		"sendmsg",
+		"sendto",
+	}
+	// The architectures name the stat system call differently
+	if runtime.GOARCH == "arm64" {
+		syscalls = append(syscalls, "fstatat")
+	} else {
+		syscalls = append(syscalls, "newfstatat")
+	}
+	return append(syscalls, goRuntimeSyscalls...)
+}
+
+// PreExec applies the seccomp profile of the container to Firecracker.
+func (fc *Firecracker) PreExec(args types.ExecArgs) error {
+	return loadSeccompProfile(args, firecrackerSeccompSyscalls())
 }
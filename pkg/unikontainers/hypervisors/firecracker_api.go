@@ -0,0 +1,326 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	DefaultFirecrackerAPITimeout = 5 * time.Second
+	firecrackerReadyPoll         = 10 * time.Millisecond
+)
+
+type FirecrackerAction struct {
+	ActionType string `json:"action_type"`
+}
+
+type FirecrackerVMState struct {
+	State string `json:"state"`
+}
+
+type FirecrackerBalloon struct {
+	AmountMiB             uint64 `json:"amount_mib"`
+	DeflateOnOOM          bool   `json:"deflate_on_oom"`
+	StatsPollingIntervalS int    `json:"stats_polling_interval_s,omitempty"`
+}
+
+type FirecrackerSnapshot struct {
+	SnapshotType string `json:"snapshot_type,omitempty"`
+	SnapshotPath string `json:"snapshot_path"`
+	MemFilePath  string `json:"mem_file_path"`
+}
+
+type FirecrackerMetrics struct {
+	MetricsPath string `json:"metrics_path"`
+}
+
+// FirecrackerInstanceInfo is the response of GET /
+type FirecrackerInstanceInfo struct {
+	ID         string `json:"id"`
+	State      string `json:"state"`
+	VMMVersion string `json:"vmm_version"`
+	AppName    string `json:"app_name"`
+}
+
+// FirecrackerClient is a client for the REST API that Firecracker
+// exposes over its API unix socket.
+type FirecrackerClient struct {
+	api *apiClient
+}
+
+// NewFirecrackerClient returns a client for the API socket at socketPath.
+// The timeout applies to every request.
+func NewFirecrackerClient(socketPath string, timeout time.Duration) *FirecrackerClient {
+	api := newAPIClient(socketPath, timeout)
+	api.errMessage = firecrackerFaultMessage
+	return &FirecrackerClient{api: api}
+}
+
+// firecrackerFaultMessage extracts the fault message from an error response
+func firecrackerFaultMessage(body []byte) string {
+	var fault struct {
+		FaultMessage string `json:"fault_message"`
+	}
+	err := json.Unmarshal(body, &fault)
+	if err != nil || fault.FaultMessage == "" {
+		return strings.TrimSpace(string(body))
+	}
+	return fault.FaultMessage
+}
+
+func (c *FirecrackerClient) do(method string, path string, body any, result any) error {
+	return c.api.do(method, path, body, result)
+}
+
+// InstanceInfo returns general information about the Firecracker instance
+func (c *FirecrackerClient) InstanceInfo() (FirecrackerInstanceInfo, error) {
+	var info FirecrackerInstanceInfo
+	err := c.do(http.MethodGet, "/", nil, &info)
+	return info, err
+}
+
+// WaitReady waits until the API server of Firecracker responds or
+// the timeout expires
+func (c *FirecrackerClient) WaitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := c.InstanceInfo()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("firecracker api is not ready: %w", err)
+		}
+		time.Sleep(firecrackerReadyPoll)
+	}
+}
+
+func (c *FirecrackerClient) PutBootSource(src FirecrackerBootSource) error {
+	return c.do(http.MethodPut, "/boot-source", src, nil)
+}
+
+func (c *FirecrackerClient) PutMachineConfig(machine FirecrackerMachine) error {
+	return c.do(http.MethodPut, "/machine-config", machine, nil)
+}
+
+func (c *FirecrackerClient) PutDrive(drive FirecrackerDrive) error {
+	return c.do(http.MethodPut, "/drives/"+drive.DriveID, drive, nil)
+}
+
+func (c *FirecrackerClient) PutNetworkInterface(netIf FirecrackerNet) error {
+	return c.do(http.MethodPut, "/network-interfaces/"+netIf.IfaceID, netIf, nil)
+}
+
+func (c *FirecrackerClient) PutVSock(vsock FirecrackerVSockDev) error {
+	return c.do(http.MethodPut, "/vsock", vsock, nil)
+}
+
+// Configure applies the whole configuration of the VM, before it boots
+func (c *FirecrackerClient) Configure(conf *FirecrackerConfig) error {
+	err := c.PutMachineConfig(conf.Machine)
+	if err != nil {
+		return err
+	}
+	err = c.PutBootSource(conf.Source)
+	if err != nil {
+		return err
+	}
+	for _, drive := range conf.Drives {
+		err = c.PutDrive(drive)
+		if err != nil {
+			return err
+		}
+	}
+	for _, netIf := range conf.NetIfs {
+		err = c.PutNetworkInterface(netIf)
+		if err != nil {
+			return err
+		}
+	}
+	if conf.VSock.UDSPath != "" {
+		return c.PutVSock(conf.VSock)
+	}
+	return nil
+}
+
+// StartInstance boots the configured VM
+func (c *FirecrackerClient) StartInstance() error {
+	return c.do(http.MethodPut, "/actions", FirecrackerAction{ActionType: "InstanceStart"}, nil)
+}
+
+// Pause pauses the execution of the VM
+func (c *FirecrackerClient) Pause() error {
+	return c.do(http.MethodPatch, "/vm", FirecrackerVMState{State: "Paused"}, nil)
+}
+
+// Resume resumes the execution of a paused VM
+func (c *FirecrackerClient) Resume() error {
+	return c.do(http.MethodPatch, "/vm", FirecrackerVMState{State: "Resumed"}, nil)
+}
+
+// PutBalloon adds a balloon device. It can only be called before the VM boots.
+func (c *FirecrackerClient) PutBalloon(balloon FirecrackerBalloon) error {
+	return c.do(http.MethodPut, "/balloon", balloon, nil)
+}
+
+// SetBalloonTarget updates the target size of the balloon of a running VM
+func (c *FirecrackerClient) SetBalloonTarget(amountMiB uint64) error {
+	return c.do(http.MethodPatch, "/balloon", map[string]uint64{"amount_mib": amountMiB}, nil)
+}
+
+// CreateSnapshot creates a snapshot of a paused VM
+func (c *FirecrackerClient) CreateSnapshot(snapshot FirecrackerSnapshot) error {
+	return c.do(http.MethodPut, "/snapshot/create", snapshot, nil)
+}
+
+// PutMetrics sets the file or named pipe where Firecracker writes its metrics
+func (c *FirecrackerClient) PutMetrics(metrics FirecrackerMetrics) error {
+	return c.do(http.MethodPut, "/metrics", metrics, nil)
+}
+
+// FlushMetrics asks Firecracker to write its metrics immediately
+func (c *FirecrackerClient) FlushMetrics() error {
+	return c.do(http.MethodPut, "/actions", FirecrackerAction{ActionType: "FlushMetrics"}, nil)
+}
+
+// FirecrackerAPI runs Firecracker with its API socket enabled and
+// configures the VM through the API, instead of a config file. Since the
+// VM needs to be configured after Firecracker starts, Firecracker runs as
+// a child of the container's process, which waits for it to exit.
+type FirecrackerAPI struct {
+	*Firecracker
+}
+
+// Capabilities returns the features that Firecracker supports. With the
+// API socket, the guest can also get paused and snapshotted.
+func (fc *FirecrackerAPI) Capabilities() types.VMMCapabilities {
+	caps := fc.Firecracker.Capabilities()
+	caps.Pause = true
+	caps.Snapshot = true
+	return caps
+}
+
+func (fc *FirecrackerAPI) BuildExecCmd(args types.ExecArgs, _ types.Unikernel) ([]string, error) {
+	if args.MonitorSocket == "" {
+		return nil, fmt.Errorf("firecracker api mode requires an api socket")
+	}
+	err := checkFirecrackerHugepages(args)
+	if err != nil {
+		return nil, err
+	}
+	cmd := newCmdBuilder(fc.Path())
+	cmd.add("--api-sock", args.MonitorSocket)
+	if !args.Seccomp {
+		cmd.add("--no-seccomp")
+	}
+	return cmd.build(), nil
+}
+
+// PreExec performs pre-execution setup. In API mode, the current process
+// stays alive as the parent of Firecracker and hence, the seccomp profile
+// of the container can not be loaded before Firecracker starts.
+// Firecracker still applies its own filters, unless seccomp is disabled.
+func (fc *FirecrackerAPI) PreExec(_ types.ExecArgs) error {
+	return nil
+}
+
+// Run starts Firecracker, configures and boots the VM through the API and
+// waits for Firecracker to exit. SIGTERM and SIGINT are forwarded to
+// Firecracker, while Firecracker gets killed if the current process dies.
+func (fc *FirecrackerAPI) Run(args types.ExecArgs, ukernel types.Unikernel) error {
+	execCmd, err := fc.BuildExecCmd(args, ukernel)
+	if err != nil {
+		return err
+	}
+	conf := fc.vmConfig(args, ukernel)
+
+	// Pdeathsig is bound to the thread that spawned the child
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// A stale socket makes Firecracker fail
+	err = os.Remove(args.MonitorSocket)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale api socket: %w", err)
+	}
+
+	cmd := exec.Command(execCmd[0], execCmd[1:]...) //nolint: gosec
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = args.Environment
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: unix.SIGKILL}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGTERM, unix.SIGINT)
+	defer signal.Stop(sigs)
+
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start firecracker: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	err = fc.boot(args.MonitorSocket, conf)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		<-exited
+		return err
+	}
+
+	for {
+		select {
+		case sig := <-sigs:
+			vmmLog.Debugf("forwarding %s to firecracker", sig)
+			_ = cmd.Process.Signal(sig)
+		case err := <-exited:
+			return err
+		}
+	}
+}
+
+// boot configures the VM and starts it through the API socket
+func (fc *FirecrackerAPI) boot(socketPath string, conf *FirecrackerConfig) error {
+	client := NewFirecrackerClient(socketPath, DefaultFirecrackerAPITimeout)
+	err := client.WaitReady(DefaultFirecrackerAPITimeout)
+	if err != nil {
+		return err
+	}
+	err = client.Configure(conf)
+	if err != nil {
+		return fmt.Errorf("failed to configure firecracker vm: %w", err)
+	}
+	err = client.StartInstance()
+	if err != nil {
+		return fmt.Errorf("failed to start firecracker vm: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+type fcRequest struct {
+	Method string
+	Path   string
+	Body   map[string]any
+}
+
+// fakeFirecrackerAPI emulates the API server of Firecracker over a unix
+// socket and records the requests it receives
+type fakeFirecrackerAPI struct {
+	socket   string
+	mu       sync.Mutex
+	requests []fcRequest
+	// failPath makes the server fail the requests to the given path
+	failPath string
+}
+
+func newFakeFirecrackerAPI(t *testing.T, failPath string) *fakeFirecrackerAPI {
+	t.Helper()
+	f := &fakeFirecrackerAPI{
+		socket:   filepath.Join(t.TempDir(), MonitorSocketName),
+		failPath: failPath,
+	}
+	l, err := net.Listen("unix", f.socket)
+	assert.NoError(t, err)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(f.handle))
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return f
+}
+
+func (f *fakeFirecrackerAPI) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": "anonymous-instance", "state": "Not started", "vmm_version": "1.10.1", "app_name": "Firecracker"}`))
+		return
+	}
+	req := fcRequest{Method: r.Method, Path: r.URL.Path}
+	data, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(data, &req.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+	if r.URL.Path == f.failPath {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"fault_message": "invalid request"}`))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeFirecrackerAPI) received() []fcRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fcRequest(nil), f.requests...)
+}
+
+func TestFirecrackerClient(t *testing.T) {
+	t.Run("instance info", func(t *testing.T) {
+		t.Parallel()
+		f := newFakeFirecrackerAPI(t, "")
+		c := NewFirecrackerClient(f.socket, time.Second)
+		assert.NoError(t, c.WaitReady(time.Second))
+		info, err := c.InstanceInfo()
+		assert.NoError(t, err)
+		assert.Equal(t, "Not started", info.State)
+		assert.Equal(t, "1.10.1", info.VMMVersion)
+	})
+
+	t.Run("configure and start", func(t *testing.T) {
+		t.Parallel()
+		f := newFakeFirecrackerAPI(t, "")
+		c := NewFirecrackerClient(f.socket, time.Second)
+		fc := &Firecracker{binaryPath: "/usr/bin/firecracker"}
+		args := types.ExecArgs{
+			UnikernelPath: "/unikernel/app",
+			Command:       "app arg1",
+			MemSizeB:      512 * 1024 * 1024,
+			VCPUs:         2,
+			Nets: []types.NetDevParams{
+				{TapDev: "tap0_urunc", MAC: "aa:bb:cc:dd:ee:ff"},
+				{TapDev: "tap1_urunc", MAC: "aa:bb:cc:dd:ee:fe"},
+			},
+			VAccelType:   "vsock",
+			VSockDevPath: "/tmp",
+			VSockDevID:   3,
+		}
+		ukernel := &fakeUnikernel{blocks: []types.MonitorBlockArgs{{ID: "rootfs", Path: "/dev/dm-1"}}}
+
+		assert.NoError(t, c.Configure(fc.vmConfig(args, ukernel)))
+		assert.NoError(t, c.StartInstance())
+
+		reqs := f.received()
+		var paths []string
+		for _, r := range reqs {
+			assert.Equal(t, http.MethodPut, r.Method)
+			paths = append(paths, r.Path)
+		}
+		assert.Equal(t, []string{"/machine-config", "/boot-source", "/drives/rootfs",
+			"/network-interfaces/net1", "/network-interfaces/net2", "/vsock", "/actions"}, paths)
+		assert.Equal(t, float64(512), reqs[0].Body["mem_size_mib"])
+		assert.Equal(t, "app arg1", reqs[1].Body["boot_args"])
+		assert.Equal(t, true, reqs[2].Body["is_root_device"])
+		assert.Equal(t, "tap0_urunc", reqs[3].Body["host_dev_name"])
+		assert.Equal(t, "tap1_urunc", reqs[4].Body["host_dev_name"])
+		assert.Equal(t, "/tmp/vaccel.sock", reqs[5].Body["uds_path"])
+		assert.Equal(t, "InstanceStart", reqs[6].Body["action_type"])
+	})
+
+	t.Run("runtime operations", func(t *testing.T) {
+		t.Parallel()
+		f := newFakeFirecrackerAPI(t, "")
+		c := NewFirecrackerClient(f.socket, time.Second)
+
+		assert.NoError(t, c.Pause())
+		assert.NoError(t, c.Resume())
+		assert.NoError(t, c.SetBalloonTarget(64))
+		assert.NoError(t, c.CreateSnapshot(FirecrackerSnapshot{SnapshotPath: "/snap", MemFilePath: "/mem"}))
+		assert.NoError(t, c.PutMetrics(FirecrackerMetrics{MetricsPath: "/metrics.fifo"}))
+		assert.NoError(t, c.FlushMetrics())
+
+		reqs := f.received()
+		assert.Len(t, reqs, 6)
+		assert.Equal(t, fcRequest{Method: http.MethodPatch, Path: "/vm", Body: map[string]any{"state": "Paused"}}, reqs[0])
+		assert.Equal(t, fcRequest{Method: http.MethodPatch, Path: "/vm", Body: map[string]any{"state": "Resumed"}}, reqs[1])
+		assert.Equal(t, fcRequest{Method: http.MethodPatch, Path: "/balloon", Body: map[string]any{"amount_mib": float64(64)}}, reqs[2])
+		assert.Equal(t, "/snapshot/create", reqs[3].Path)
+		assert.Equal(t, "/metrics", reqs[4].Path)
+		assert.Equal(t, "FlushMetrics", reqs[5].Body["action_type"])
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		t.Parallel()
+		f := newFakeFirecrackerAPI(t, "/boot-source")
+		c := NewFirecrackerClient(f.socket, time.Second)
+		fc := &FirecrackerAPI{Firecracker: &Firecracker{}}
+
+		err := fc.boot(f.socket, fc.vmConfig(types.ExecArgs{Command: "app"}, &fakeUnikernel{}))
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Equal(t, "invalid request", apiErr.Message)
+		// The VM must not start after a failed configuration
+		for _, r := range f.received() {
+			assert.NotEqual(t, "/actions", r.Path)
+		}
+		assert.Error(t, c.PutBootSource(FirecrackerBootSource{}))
+	})
+
+	t.Run("no server", func(t *testing.T) {
+		t.Parallel()
+		c := NewFirecrackerClient(filepath.Join(t.TempDir(), MonitorSocketName), 100*time.Millisecond)
+		assert.Error(t, c.WaitReady(50*time.Millisecond))
+	})
+}
+
+func TestFirecrackerAPIMode(t *testing.T) {
+	t.Run("factory", func(t *testing.T) {
+		t.Parallel()
+		monitors := map[string]types.MonitorConfig{
+			"firecracker": {BinaryPath: "/usr/bin/firecracker", APISocket: true},
+		}
+		vmm, err := NewVMM(FirecrackerVmm, monitors)
+		assert.NoError(t, err)
+		_, ok := vmm.(*FirecrackerAPI)
+		assert.True(t, ok, "factory should return *FirecrackerAPI in api socket mode")
+		_, ok = vmm.(types.VMMRunner)
+		assert.True(t, ok, "firecracker in api socket mode should run the VM itself")
+
+		monitors["firecracker"] = types.MonitorConfig{BinaryPath: "/usr/bin/firecracker"}
+		vmm, err = NewVMM(FirecrackerVmm, monitors)
+		assert.NoError(t, err)
+		_, ok = vmm.(types.VMMRunner)
+		assert.False(t, ok, "firecracker should be execve'd by default")
+	})
+
+	t.Run("exec command", func(t *testing.T) {
+		t.Parallel()
+		fc := &FirecrackerAPI{Firecracker: &Firecracker{binaryPath: "/usr/bin/firecracker"}}
+		cmd, err := fc.BuildExecCmd(types.ExecArgs{MonitorSocket: "/monitor.sock"}, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/usr/bin/firecracker", "--api-sock", "/monitor.sock", "--no-seccomp"}, cmd)
+
+		_, err = fc.BuildExecCmd(types.ExecArgs{Seccomp: true}, &fakeUnikernel{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+var ErrEmptyMonitorCmd = errors.New("monitor template rendered an empty command")
+
+// Generic is a monitor that is declared in the configuration of urunc,
+// rather than implemented in urunc. Its command line is rendered from a
+// text/template, where each non-empty line of the output is a single
+// argument.
+type Generic struct {
+	name       string
+	binaryPath string
+	tmpl       *template.Template
+	usesKVM    bool
+	sharedfs   []string
+}
+
+// GenericTemplateData is the data that the template of a generic monitor
+// gets rendered against.
+type GenericTemplateData struct {
+	Path   string                   // The path of the monitor's binary
+	MemMB  string                   // The memory of the VM in MB
+	Args   types.ExecArgs           // The arguments of the monitor's execution
+	Cli    types.MonitorCliArgs     // The unikernel specific monitor arguments
+	Blocks []types.MonitorBlockArgs // The block devices of the unikernel
+	NetCli string                   // The unikernel specific network arguments, if any
+}
+
+// IsGeneric returns true if the given monitor configuration declares a
+// generic monitor.
+func IsGeneric(cfg types.MonitorConfig) bool {
+	return cfg.Template != ""
+}
+
+func newGeneric(name string, binaryPath string, cfg types.MonitorConfig) (*Generic, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(cfg.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the template of monitor %s: %w", name, err)
+	}
+	return &Generic{
+		name:       name,
+		binaryPath: binaryPath,
+		tmpl:       tmpl,
+		usesKVM:    cfg.UsesKVM,
+		sharedfs:   cfg.Sharedfs,
+	}, nil
+}
+
+func (g *Generic) Stop(pid int) error {
+	return killProcess(pid)
+}
+
+func (g *Generic) Ok() error {
+	return nil
+}
+
+// Capabilities returns the features of the monitor, as declared in its
+// uses_kvm and sharedfs options
+func (g *Generic) Capabilities() types.VMMCapabilities {
+	return types.VMMCapabilities{
+		KVM:      g.usesKVM,
+		Block:    true,
+		NinePfs:  slices.Contains(g.sharedfs, "9p"),
+		Virtiofs: slices.Contains(g.sharedfs, "virtio"),
+	}
+}
+
+func (g *Generic) Path() string {
+	return g.binaryPath
+}
+
+// BuildExecCmd renders the template of the monitor and returns the
+// arguments of the monitor's execution.
+func (g *Generic) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
+	data := GenericTemplateData{
+		Path:   g.binaryPath,
+		MemMB:  BytesToStringMB(args.MemSizeB),
+		Args:   args,
+		Cli:    ukernel.MonitorCli(),
+		Blocks: ukernel.MonitorBlockCli(),
+	}
+	if nic := args.Net(); nic.TapDev != "" {
+		data.NetCli = ukernel.MonitorNetCli(nic.TapDev, nic.MAC)
+	}
+
+	var out strings.Builder
+	err := g.tmpl.Execute(&out, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the template of monitor %s: %w", g.name, err)
+	}
+
+	var exArgs []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		arg := strings.TrimSpace(line)
+		if arg != "" {
+			exArgs = append(exArgs, arg)
+		}
+	}
+	if len(exArgs) == 0 {
+		return nil, ErrEmptyMonitorCmd
+	}
+
+	vmmLog.WithField(g.name+" command", exArgs).Debug("Ready to execve generic monitor")
+
+	return exArgs, nil
+}
+
+// PreExec applies the seccomp profile of the container to the monitor.
+// There is no baseline for generic monitors and the profile gets loaded
+// as is.
+func (g *Generic) PreExec(args types.ExecArgs) error {
+	return loadSeccompProfile(args, nil)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+const testGenericTemplate = `{{.Path}}
+-m
+{{.MemMB}}M
+{{- if .Args.Net.TapDev}}
+-netdev
+tap,id=n0,ifname={{.Args.Net.TapDev}}
+{{- end}}
+{{- range .Blocks}}
+-drive
+file={{.Path}},id={{.ID}}
+{{- end}}
+-kernel
+{{.Args.UnikernelPath}}
+-append
+{{.Args.Command}}
+`
+
+func TestNewVMMGeneric(t *testing.T) {
+	t.Run("monitor declared in the config", func(t *testing.T) {
+		t.Parallel()
+		monitors := map[string]types.MonitorConfig{
+			"vendor-qemu": {
+				BinaryPath: "/opt/vendor/bin/qemu",
+				Template:   testGenericTemplate,
+				UsesKVM:    true,
+				Sharedfs:   []string{"9p"},
+			},
+		}
+		vmm, err := NewVMM("vendor-qemu", monitors)
+		assert.NoError(t, err)
+		generic, ok := vmm.(*Generic)
+		assert.True(t, ok, "NewVMM should return *Generic")
+		assert.Equal(t, "/opt/vendor/bin/qemu", generic.Path())
+		caps := generic.Capabilities()
+		assert.True(t, caps.KVM)
+		assert.True(t, caps.NinePfs)
+		assert.False(t, caps.Virtiofs)
+	})
+
+	t.Run("monitor without template", func(t *testing.T) {
+		t.Parallel()
+		monitors := map[string]types.MonitorConfig{"vendor-qemu": {BinaryPath: "/opt/vendor/bin/qemu"}}
+		_, err := NewVMM("vendor-qemu", monitors)
+		assert.ErrorContains(t, err, "is not supported")
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		t.Parallel()
+		monitors := map[string]types.MonitorConfig{
+			"vendor-qemu": {BinaryPath: "/opt/vendor/bin/qemu", Template: "{{.Path"},
+		}
+		_, err := NewVMM("vendor-qemu", monitors)
+		assert.ErrorContains(t, err, "failed to parse the template of monitor vendor-qemu")
+	})
+}
+
+func TestGenericBuildExecCmd(t *testing.T) {
+	cfg := types.MonitorConfig{Template: testGenericTemplate}
+	generic, err := newGeneric("vendor-qemu", "/opt/vendor/bin/qemu", cfg)
+	assert.NoError(t, err)
+
+	t.Run("minimal", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{
+			UnikernelPath: "/unikernel/app",
+			Command:       "console=ttyS0 init=/bin/app",
+			MemSizeB:      256 * 1000 * 1000,
+		}
+		cmd, err := generic.BuildExecCmd(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"/opt/vendor/bin/qemu",
+			"-m", "256M",
+			"-kernel", "/unikernel/app",
+			"-append", "console=ttyS0 init=/bin/app",
+		}, cmd)
+	})
+
+	t.Run("devices", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{
+			UnikernelPath: "/unikernel/app",
+			Command:       "app",
+			MemSizeB:      512 * 1000 * 1000,
+			Nets:          []types.NetDevParams{{TapDev: "tap0_urunc", MAC: "aa:bb:cc:dd:ee:ff"}},
+		}
+		ukernel := &fakeUnikernel{blocks: []types.MonitorBlockArgs{{ID: "vol1", Path: "/dev/dm-2"}}}
+		cmd, err := generic.BuildExecCmd(args, ukernel)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"/opt/vendor/bin/qemu",
+			"-m", "512M",
+			"-netdev", "tap,id=n0,ifname=tap0_urunc",
+			"-drive", "file=/dev/dm-2,id=vol1",
+			"-kernel", "/unikernel/app",
+			"-append", "app",
+		}, cmd)
+	})
+
+	t.Run("render errors", func(t *testing.T) {
+		t.Parallel()
+		empty, err := newGeneric("empty", "/bin/empty", types.MonitorConfig{Template: "{{if .Args.Seccomp}}x{{end}}"})
+		assert.NoError(t, err)
+		_, err = empty.BuildExecCmd(types.ExecArgs{}, &fakeUnikernel{})
+		assert.ErrorIs(t, err, ErrEmptyMonitorCmd)
+
+		unknown, err := newGeneric("unknown", "/bin/unknown", types.MonitorConfig{Template: "{{.Args.Unknown}}"})
+		assert.NoError(t, err)
+		_, err = unknown.BuildExecCmd(types.ExecArgs{}, &fakeUnikernel{})
+		assert.ErrorContains(t, err, "failed to render the template of monitor unknown")
+	})
+}
@@ -15,59 +15,282 @@
 package hypervisors
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	hedge "github.com/nubificus/hedge_cli/hedge_api"
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+	"golang.org/x/sys/unix"
 )
 
 const (
-	HedgeVmm         VmmType = "hedge"
-	maxVMListRetries int     = 20
-	ConsoleEndpoint          = "/proc/vmcons"
+	HedgeVmm          VmmType = "hedge"
+	maxVMListRetries  int     = 20
+	ConsoleEndpoint           = "/proc/vmcons"
+	MonitorEndpoint           = hedge.MONITOR_ENDPOINT
+	hedgePollInterval         = 100 * time.Millisecond
+	hedgeStopTimeout          = 5 * time.Second
 )
 
-type Hedge struct{}
+var (
+	ErrHedgeVMNotFound = errors.New("hedge vm not found")
+	ErrHedgeVMExited   = errors.New("hedge vm exited")
+)
 
-func (h *Hedge) Ok() error {
-	return fmt.Errorf("hedge not implemented yet")
+// hedgeAPI is the part of the Hedge API that urunc uses. It allows the tests
+// to replace the procfs interface of the Hedge kernel module.
+type hedgeAPI interface {
+	Status() error
+	StartVM(conf hedge.VMConfig) error
+	StopVM(name string) error
+	ListVMs() ([]hedge.VM, error)
+	Console(id int) (string, error)
+}
+
+// hedgeProcfs calls the Hedge API, which talks to the kernel module
+// through its procfs endpoints.
+type hedgeProcfs struct{}
+
+func (hedgeProcfs) Status() error                     { return hedge.Status() }
+func (hedgeProcfs) StartVM(conf hedge.VMConfig) error { return hedge.StartVM(conf) }
+func (hedgeProcfs) StopVM(name string) error          { return hedge.StopVM(name) }
+func (hedgeProcfs) ListVMs() ([]hedge.VM, error)      { return hedge.ListVMs() }
+func (hedgeProcfs) Console(id int) (string, error)    { return hedge.Console(id) }
+
+// Hedge manages VMs through the Hedge API of the Hedge kernel module.
+// In contrast with the rest of the monitors, there is no monitor process in
+// the host. Therefore, Hedge implements types.VMMRunner and the process that
+// would otherwise execve the monitor, launches the VM, forwards its console
+// and waits for it to exit.
+// The zero value uses the Hedge API.
+type Hedge struct {
+	api          hedgeAPI
+	pollInterval time.Duration
+	console      io.Writer // Where to forward the console of the VM
+}
+
+func NewHedge() *Hedge {
+	return &Hedge{}
+}
+
+func (h *Hedge) hedgeAPI() hedgeAPI {
+	if h.api == nil {
+		return hedgeProcfs{}
+	}
+	return h.api
 }
 
-func (h *Hedge) Stop(_ int) error {
-	return fmt.Errorf("hedge not implemented yet")
+func (h *Hedge) interval() time.Duration {
+	if h.pollInterval == 0 {
+		return hedgePollInterval
+	}
+	return h.pollInterval
 }
 
-func (h *Hedge) UsesKVM() bool {
-	return true
+func (h *Hedge) consoleWriter() io.Writer {
+	if h.console == nil {
+		return os.Stdout
+	}
+	return h.console
 }
 
-// SupportsSharedfs returns a bool value depending on the monitor support for shared-fs
-func (h *Hedge) SupportsSharedfs(_ string) bool {
-	return false
+func (h *Hedge) Ok() error {
+	return h.hedgeAPI().Status()
 }
 
+// Stop asks the process that runs the VM to stop it, by sending a SIGTERM.
+// If the process does not exit in time, it gets killed.
+func (h *Hedge) Stop(pid int) error {
+	err := syscall.Kill(pid, unix.SIGTERM)
+	if err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+		return err
+	}
+	if waitProcessExit(pid, hedgeStopTimeout) {
+		return nil
+	}
+	vmmLog.Warnf("process %d did not stop the hedge vm in time, killing it", pid)
+	return killProcess(pid)
+}
+
+// Capabilities returns the features that Hedge supports
+func (h *Hedge) Capabilities() types.VMMCapabilities {
+	return types.VMMCapabilities{
+		KVM:   true,
+		Block: true,
+		Archs: []string{"amd64"},
+	}
+}
+
+// Path returns an empty string, since there is no monitor binary for Hedge
 func (h *Hedge) Path() string {
 	return ""
 }
 
-func (h *Hedge) BuildExecCmd(_ types.ExecArgs, _ types.Unikernel) ([]string, error) {
-	return nil, fmt.Errorf("hedge not implemented yet")
+func (h *Hedge) vmConfig(args types.ExecArgs, ukernel types.Unikernel) (hedge.VMConfig, error) {
+	mem := bytesToMiB(args.MemSizeB)
+	if mem == 0 {
+		mem = DefaultMemory
+	}
+	conf := hedge.VMConfig{
+		Name:    args.ContainerID,
+		Binary:  args.UnikernelPath,
+		CPU:     int(args.VCPUs), //nolint: gosec
+		Mem:     int(mem),        //nolint: gosec
+		Net:     args.Net().TapDev,
+		CmdLine: args.Command,
+	}
+	blocks := ukernel.MonitorBlockCli()
+	if len(blocks) > 1 {
+		vmmLog.Warnf("hedge supports a single block device, ignoring %d block devices", len(blocks)-1)
+	}
+	if len(blocks) > 0 {
+		conf.Blk = blocks[0].Path
+	}
+
+	return conf, conf.Validate()
+}
+
+// BuildExecCmd validates the configuration of the VM and returns the
+// monitor endpoint followed by the command that will start the VM,
+// as the Hedge API writes it.
+func (h *Hedge) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
+	conf, err := h.vmConfig(args, ukernel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hedge vm configuration: %w", err)
+	}
+	start := fmt.Sprintf("start|%s|%s|%d|%d|%s|%s|%s", conf.Name,
+		conf.Binary, conf.CPU, conf.Mem, conf.Blk, conf.Net, conf.CmdLine)
+	return []string{MonitorEndpoint, start}, nil
 }
 
-// PreExec performs pre-execution setup. Hedge is not fully implemented.
+// PreExec performs pre-execution setup. Hedge does not require any.
 func (h *Hedge) PreExec(_ types.ExecArgs) error {
-	return fmt.Errorf("hedge not implemented yet")
+	return nil
 }
 
-func (h *Hedge) VMState(name string) string {
-	vms, err := hedge.ListVMs()
+// Run starts the VM, forwards its console and waits until the VM exits or
+// the process receives a SIGTERM/SIGINT, in which case the VM gets stopped.
+func (h *Hedge) Run(args types.ExecArgs, ukernel types.Unikernel) error {
+	conf, err := h.vmConfig(args, ukernel)
 	if err != nil {
-		return "error"
+		return fmt.Errorf("invalid hedge vm configuration: %w", err)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGTERM, unix.SIGINT)
+	defer signal.Stop(sigs)
+
+	err = h.hedgeAPI().StartVM(conf)
+	if err != nil {
+		return fmt.Errorf("failed to start hedge vm %s: %w", conf.Name, err)
+	}
+
+	vm, err := h.waitVM(conf.Name)
+	if err != nil {
+		// The VM might still show up, since StartVM succeeded
+		stopErr := h.hedgeAPI().StopVM(conf.Name)
+		if stopErr != nil {
+			vmmLog.WithError(stopErr).Warnf("failed to stop hedge vm %s", conf.Name)
+		}
+		return err
+	}
+	vmmLog.WithField("id", vm.ID).Debugf("hedge vm %s started", vm.Name)
+
+	return h.watchVM(vm, sigs)
+}
+
+// waitVM waits until the VM with the given name appears in the list of VMs
+func (h *Hedge) waitVM(name string) (hedge.VM, error) {
+	for i := 0; i < maxVMListRetries; i++ {
+		vm, err := h.findVM(name)
+		if err == nil {
+			return vm, nil
+		}
+		if !errors.Is(err, ErrHedgeVMNotFound) {
+			return hedge.VM{}, err
+		}
+		time.Sleep(h.interval())
+	}
+	return hedge.VM{}, fmt.Errorf("%w: %s did not start", ErrHedgeVMNotFound, name)
+}
+
+// watchVM forwards the console of the VM until it exits or
+// a termination signal is received. Hedge does not report the exit status of
+// the VM and hence, a VM that exits on its own is reported with
+// ErrHedgeVMExited, since the process can not tell whether the VM failed.
+func (h *Hedge) watchVM(vm hedge.VM, sigs <-chan os.Signal) error {
+	ticker := time.NewTicker(h.interval())
+	defer ticker.Stop()
+	printed := 0
+	for {
+		printed = h.forwardConsole(vm.ID, printed)
+		select {
+		case sig := <-sigs:
+			vmmLog.Debugf("received %s, stopping hedge vm %s", sig, vm.Name)
+			err := h.hedgeAPI().StopVM(vm.Name)
+			h.forwardConsole(vm.ID, printed)
+			return err
+		case <-ticker.C:
+		}
+		_, err := h.findVM(vm.Name)
+		if errors.Is(err, ErrHedgeVMNotFound) {
+			h.forwardConsole(vm.ID, printed)
+			return fmt.Errorf("%w: %s", ErrHedgeVMExited, vm.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// forwardConsole writes the console output of the VM that was not
+// written yet and returns the new amount of written bytes.
+func (h *Hedge) forwardConsole(id int, printed int) int {
+	out, err := h.hedgeAPI().Console(id)
+	if err != nil {
+		vmmLog.WithError(err).Debugf("failed to read console of hedge vm %d", id)
+		return printed
+	}
+	// The console buffer got reset or wrapped around
+	if len(out) < printed {
+		printed = 0
+	}
+	_, err = io.WriteString(h.consoleWriter(), out[printed:])
+	if err != nil {
+		vmmLog.WithError(err).Debugf("failed to forward console of hedge vm %d", id)
+	}
+	return len(out)
+}
+
+func (h *Hedge) findVM(name string) (hedge.VM, error) {
+	vms, err := h.hedgeAPI().ListVMs()
+	if err != nil {
+		return hedge.VM{}, err
 	}
 	for _, vm := range vms {
 		if vm.Name == name {
-			return "running"
+			return vm, nil
 		}
 	}
-	return "unknown"
+	return hedge.VM{}, ErrHedgeVMNotFound
+}
+
+func (h *Hedge) VMState(name string) string {
+	_, err := h.findVM(name)
+	switch {
+	case err == nil:
+		return "running"
+	case errors.Is(err, ErrHedgeVMNotFound):
+		return "unknown"
+	default:
+		return "error"
+	}
 }
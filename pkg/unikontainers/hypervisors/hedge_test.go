@@ -0,0 +1,284 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	hedge "github.com/nubificus/hedge_cli/hedge_api"
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+type fakeUnikernel struct {
+	blocks []types.MonitorBlockArgs
+	monCli types.MonitorCliArgs
+}
+
+func (f *fakeUnikernel) Init(types.UnikernelParams) error    { return nil }
+func (f *fakeUnikernel) CommandString() (string, error)      { return "", nil }
+func (f *fakeUnikernel) MonitorNetCli(string, string) string { return "" }
+func (f *fakeUnikernel) Capabilities() types.UnikernelCapabilities {
+	return types.UnikernelCapabilities{Block: true}
+}
+func (f *fakeUnikernel) MonitorBlockCli() []types.MonitorBlockArgs {
+	return f.blocks
+}
+func (f *fakeUnikernel) MonitorCli() types.MonitorCliArgs { return f.monCli }
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// fakeHedgeAPI emulates the Hedge kernel module. A started VM gets
+// listed with the ID of the next VM.
+type fakeHedgeAPI struct {
+	mu       sync.Mutex
+	status   error
+	listErr  error
+	vms      []hedge.VM
+	consoles map[int]string
+	started  []hedge.VMConfig
+	stopped  []string
+	nextID   int
+}
+
+func (f *fakeHedgeAPI) Status() error {
+	return f.status
+}
+
+func (f *fakeHedgeAPI) StartVM(conf hedge.VMConfig) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.started = append(f.started, conf)
+	f.vms = append(f.vms, hedge.VM{ID: f.nextID, Name: conf.Name})
+	return nil
+}
+
+func (f *fakeHedgeAPI) StopVM(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = append(f.stopped, name)
+	f.removeVM(name)
+	return nil
+}
+
+func (f *fakeHedgeAPI) ListVMs() ([]hedge.VM, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]hedge.VM(nil), f.vms...), f.listErr
+}
+
+func (f *fakeHedgeAPI) Console(id int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out, ok := f.consoles[id]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return out, nil
+}
+
+func (f *fakeHedgeAPI) setConsole(id int, out string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.consoles[id] = out
+}
+
+// removeVM removes a VM from the list, as if it exited.
+// It must be called with the lock held.
+func (f *fakeHedgeAPI) removeVM(name string) {
+	for i, vm := range f.vms {
+		if vm.Name == name {
+			f.vms = append(f.vms[:i], f.vms[i+1:]...)
+			return
+		}
+	}
+}
+
+func (f *fakeHedgeAPI) exitVM(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removeVM(name)
+}
+
+func (f *fakeHedgeAPI) running(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, vm := range f.vms {
+		if vm.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newFakeHedge returns a Hedge instance that uses a fake Hedge API
+func newFakeHedge() (*Hedge, *fakeHedgeAPI, *syncBuffer) {
+	api := &fakeHedgeAPI{consoles: map[int]string{}, nextID: 7}
+	out := &syncBuffer{}
+	return &Hedge{
+		api:          api,
+		pollInterval: 5 * time.Millisecond,
+		console:      out,
+	}, api, out
+}
+
+func testExecArgs() types.ExecArgs {
+	return types.ExecArgs{
+		ContainerID:   "hedge-test",
+		UnikernelPath: "/unikernel/app",
+		Command:       "app arg1",
+		MemSizeB:      512 * 1024 * 1024,
+		VCPUs:         2,
+		Nets:          []types.NetDevParams{{TapDev: "tap0_urunc"}},
+	}
+}
+
+func TestHedgeBuildExecCmd(t *testing.T) {
+	t.Run("valid configuration", func(t *testing.T) {
+		t.Parallel()
+		h, _, _ := newFakeHedge()
+		ukernel := &fakeUnikernel{blocks: []types.MonitorBlockArgs{{ID: "rootfs", Path: "/dev/dm-1"}}}
+		cmd, err := h.BuildExecCmd(testExecArgs(), ukernel)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{MonitorEndpoint, "start|hedge-test|/unikernel/app|2|512|/dev/dm-1|tap0_urunc|app arg1"}, cmd)
+	})
+
+	t.Run("default memory", func(t *testing.T) {
+		t.Parallel()
+		h, _, _ := newFakeHedge()
+		args := testExecArgs()
+		args.MemSizeB = 0
+		conf, err := h.vmConfig(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Equal(t, int(DefaultMemory), conf.Mem)
+	})
+
+	t.Run("missing command line", func(t *testing.T) {
+		t.Parallel()
+		h, _, _ := newFakeHedge()
+		args := testExecArgs()
+		args.Command = ""
+		_, err := h.BuildExecCmd(args, &fakeUnikernel{})
+		assert.Error(t, err)
+	})
+}
+
+func TestHedgeVMState(t *testing.T) {
+	t.Run("no vms", func(t *testing.T) {
+		t.Parallel()
+		h, _, _ := newFakeHedge()
+		assert.Equal(t, "unknown", h.VMState("hedge-test"))
+	})
+
+	t.Run("running vm", func(t *testing.T) {
+		t.Parallel()
+		h, api, _ := newFakeHedge()
+		api.vms = []hedge.VM{{ID: 1, Name: "hedge-test"}, {ID: 2, Name: "other"}}
+		assert.Equal(t, "running", h.VMState("hedge-test"))
+	})
+
+	t.Run("list error", func(t *testing.T) {
+		t.Parallel()
+		h, api, _ := newFakeHedge()
+		api.listErr = errors.New("malformed listing")
+		assert.Equal(t, "error", h.VMState("hedge-test"))
+	})
+
+	t.Run("missing module", func(t *testing.T) {
+		t.Parallel()
+		h, api, _ := newFakeHedge()
+		api.status = os.ErrNotExist
+		assert.ErrorIs(t, h.Ok(), os.ErrNotExist)
+	})
+}
+
+func TestHedgeRun(t *testing.T) {
+	t.Run("vm exits", func(t *testing.T) {
+		t.Parallel()
+		h, api, out := newFakeHedge()
+
+		// Emulate Hedge: when the VM starts, write its console.
+		// Then, the VM exits.
+		go func() {
+			for !api.running("hedge-test") {
+				time.Sleep(time.Millisecond)
+			}
+			api.setConsole(7, "booting\n")
+			time.Sleep(20 * time.Millisecond)
+			api.setConsole(7, "booting\nhello from hedge\n")
+			time.Sleep(20 * time.Millisecond)
+			api.exitVM("hedge-test")
+		}()
+
+		err := h.Run(testExecArgs(), &fakeUnikernel{})
+		assert.ErrorIs(t, err, ErrHedgeVMExited)
+		assert.Equal(t, "booting\nhello from hedge\n", out.String())
+		assert.Len(t, api.started, 1)
+		assert.Equal(t, "tap0_urunc", api.started[0].Net)
+	})
+
+	t.Run("vm never starts", func(t *testing.T) {
+		t.Parallel()
+		h, _, _ := newFakeHedge()
+		h.pollInterval = time.Millisecond
+		_, err := h.waitVM("hedge-test")
+		assert.ErrorIs(t, err, ErrHedgeVMNotFound)
+	})
+
+	t.Run("vm gets stopped when it can not be listed", func(t *testing.T) {
+		t.Parallel()
+		h, api, _ := newFakeHedge()
+		api.listErr = errors.New("malformed listing")
+		err := h.Run(testExecArgs(), &fakeUnikernel{})
+		assert.ErrorContains(t, err, "malformed listing")
+		assert.Equal(t, []string{"hedge-test"}, api.stopped)
+		assert.False(t, api.running("hedge-test"))
+	})
+
+	t.Run("stop on signal", func(t *testing.T) {
+		t.Parallel()
+		h, api, _ := newFakeHedge()
+		api.vms = []hedge.VM{{ID: 3, Name: "hedge-test"}}
+		vm, err := h.findVM("hedge-test")
+		assert.NoError(t, err)
+
+		sigs := make(chan os.Signal, 1)
+		sigs <- os.Interrupt
+		err = h.watchVM(vm, sigs)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"hedge-test"}, api.stopped)
+	})
+}
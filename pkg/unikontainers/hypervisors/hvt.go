@@ -17,9 +17,7 @@ package hypervisors
 import (
 	"os/exec"
 	"runtime"
-	"strings"
 
-	seccomp "github.com/elastic/go-seccomp-bpf"
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
 )
 
@@ -33,9 +31,9 @@ type HVT struct {
 	binary     string
 }
 
-// applySeccompFilter applies some secomp filters for the Hvt process.
-// By default all systemcalls will cause a SIGSYS, except the ones that we whitelist
-func applySeccompFilter() error {
+// hvtSeccompSyscalls returns the system calls that the Hvt process
+// requires. The seccomp profile of the container gets restricted to them.
+func hvtSeccompSyscalls() []string {
 	syscalls := []string{
 		"rt_sigaction",
 		"ioctl",
@@ -82,41 +80,7 @@ func applySeccompFilter() error {
 	} else {
 		syscalls = append(syscalls, "open", "stat", "access", "arch_prctl", "newfstatat")
 	}
-	// Some of the actions that we can take for accessing non-permitted system calls are:
-	// - seccomp.ActionKillThread will kill the thread that tried to use a non-permitted
-	//	system call, but the rest of the threads can still run
-	// - seccomp.ActionErrno will result to returning EPERM error in all non-permitted
-	//	system calls.
-	// - ActionTrap will cause a SIGSYS trap to the process.
-	//
-	// For the time being, we choose ActionTrap, but we can change this in the future.
-	filter := seccomp.Filter{
-		// Set the threads no_new_privs bit, disabling any new child or execve
-		// system call to grant privileges that the parent does not have.
-		NoNewPrivs: true,
-		// Sync the filter to all threads created by the Go runtime.
-		Flag: seccomp.FilterFlagTSync,
-		Policy: seccomp.Policy{
-			DefaultAction: seccomp.ActionTrap,
-			Syscalls: []seccomp.SyscallGroup{
-				{
-					Action: seccomp.ActionAllow,
-					Names:  syscalls,
-				},
-			},
-		},
-	}
-
-	err := seccomp.LoadFilter(filter)
-	if err != nil {
-		vmmLog.Error("Could not load seccomp filters")
-		return err
-	}
-
-	vmmLog.Debug("Loaded seccomp filters")
-	vmmLog.WithField("allowed syscalls", syscalls).Debug("Whitelisted system calls")
-
-	return nil
+	return syscalls
 }
 
 // Stop kills the hvt process
@@ -124,14 +88,14 @@ func (h *HVT) Stop(pid int) error {
 	return killProcess(pid)
 }
 
-// UsesKVM returns a bool value depending on if the monitor uses KVM
-func (h *HVT) UsesKVM() bool {
-	return true
-}
-
-// SupportsSharedfs returns a bool value depending on the monitor support for shared-fs
-func (h *HVT) SupportsSharedfs(_ string) bool {
-	return false
+// Capabilities returns the features that Solo5-hvt supports
+func (h *HVT) Capabilities() types.VMMCapabilities {
+	return types.VMMCapabilities{
+		KVM:      true,
+		Block:    true,
+		MultiNIC: true,
+		Archs:    commonArchs,
+	}
 }
 
 // Path returns the path to the hvt binary.
@@ -148,31 +112,28 @@ func (h *HVT) Ok() error {
 }
 
 func (h *HVT) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
-	hvtMem := BytesToStringMB(args.MemSizeB)
-	cmdString := h.binaryPath + " --mem=" + hvtMem
-	if args.Net.TapDev != "" {
-		cmdString += " "
-		cmdString += ukernel.MonitorNetCli(args.Net.TapDev, args.Net.MAC)
+	cmd := newCmdBuilder(h.binaryPath)
+	cmd.add("--mem=" + BytesToStringMB(args.MemSizeB))
+	for _, nic := range args.Nets {
+		cmd.addFragment(ukernel.MonitorNetCli(nic.TapDev, nic.MAC))
+	}
+	for _, blockArg := range ukernel.MonitorBlockCli() {
+		if blockArg.Path != "" {
+			cmd.add("--block:" + blockArg.ID + "=" + blockArg.Path)
+		}
 	}
 	extraMonArgs := ukernel.MonitorCli()
-	bArgs := ukernel.MonitorBlockCli()
-	for _, blockArg := range bArgs {
-		cmdString = appendNonEmpty(cmdString, " --block:"+blockArg.ID+"=",
-			blockArg.Path)
+	cmd.addFragment(extraMonArgs.OtherArgs)
+	cmd.add(args.UnikernelPath)
+	// Solo5 passes the rest of the arguments to the guest as its command line
+	if args.Command != "" {
+		cmd.add(args.Command)
 	}
-	cmdString = appendNonEmpty(cmdString, " ", extraMonArgs.OtherArgs)
-	cmdString += " " + args.UnikernelPath + " " + args.Command
-	cmdArgs := strings.Split(cmdString, " ")
-	return cmdArgs, nil
+	return cmd.build(), nil
 }
 
 // PreExec performs pre-execution setup for HVT.
 // HVT requires applying seccomp filters before syscall.Exec if Seccomp is enabled.
 func (h *HVT) PreExec(args types.ExecArgs) error {
-	if args.Seccomp {
-		if err := applySeccompFilter(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return loadSeccompProfile(args, hvtSeccompSyscalls())
 }
@@ -0,0 +1,233 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+const (
+	KvmtoolVmm    VmmType = "kvmtool"
+	KvmtoolBinary string  = "lkvm"
+	// kvmtoolDir is the directory under $HOME where kvmtool creates the
+	// control sockets of its guests
+	kvmtoolDir = ".lkvm"
+)
+
+type Kvmtool struct {
+	binaryPath string
+	binary     string
+}
+
+// kvmtoolSeccompSyscalls returns the system calls that `lkvm run` requires.
+// The list follows the code paths of `lkvm run` in kvmtool (kvm.c,
+// kvm-cpu.c, kvm-ipc.c, term.c, ioeventfd.c, irq.c, virtio/net.c in tap mode
+// with an existing tap device, virtio/blk.c, disk/raw.c and virtio/9p.c) and
+// the glibc wrappers they use. The system calls that the Go runtime might
+// use until execve get added separately.
+func kvmtoolSeccompSyscalls(sharedfs bool) []string {
+	syscalls := []string{
+		// Guest memory, vCPUs and devices through /dev/kvm
+		"ioctl",
+		"mmap",
+		"munmap",
+		"mprotect",
+		"madvise",
+		"mremap",
+		"brk",
+		"eventfd2",
+		// Kernel, initrd, disks and the tap device
+		"openat",
+		"close",
+		"fstat",
+		"lseek",
+		"fcntl",
+		"read",
+		"write",
+		"readv",
+		"writev",
+		"pread64",
+		"pwrite64",
+		"preadv",
+		"pwritev",
+		// Threads of the vCPUs and the devices
+		"prctl",
+		"tgkill",
+		"rt_sigaction",
+		"rt_sigprocmask",
+		"epoll_create1",
+		"epoll_ctl",
+		"epoll_pwait",
+		"ppoll",
+		"clock_nanosleep",
+		"sysinfo",
+		"uname",
+		"getrandom",
+		// The control socket under $HOME/.lkvm and the socket that
+		// virtio-net opens for the interface ioctls
+		"socket",
+		"bind",
+		"listen",
+		"accept4",
+		"mkdirat",
+		"unlinkat",
+	}
+	if sharedfs {
+		// kvmtool serves 9p from its own process
+		syscalls = append(syscalls,
+			"statfs",
+			"getdents64",
+			"readlinkat",
+			"renameat",
+			"linkat",
+			"symlinkat",
+			"mknodat",
+			"fchmod",
+			"fchmodat",
+			"fchown",
+			"fchownat",
+			"ftruncate",
+			"fallocate",
+			"fsync",
+			"fdatasync",
+			"utimensat",
+			"getxattr",
+			"lgetxattr",
+			"setxattr",
+			"lsetxattr",
+			"listxattr",
+			"llistxattr",
+			"removexattr",
+			"lremovexattr",
+			"flock",
+		)
+	}
+
+	if runtime.GOARCH == "arm64" {
+		syscalls = append(syscalls, "fstatat", "faccessat")
+	} else {
+		syscalls = append(syscalls, "newfstatat", "access", "arch_prctl", "epoll_wait",
+			"poll", "accept", "mkdir", "unlink")
+		if sharedfs {
+			syscalls = append(syscalls, "readlink")
+		}
+	}
+	return append(syscalls, goRuntimeSyscalls...)
+}
+
+func (k *Kvmtool) Stop(pid int) error {
+	return killProcess(pid)
+}
+
+func (k *Kvmtool) Ok() error {
+	return nil
+}
+
+// Capabilities returns the features that kvmtool supports. 9p is
+// implemented by kvmtool itself.
+func (k *Kvmtool) Capabilities() types.VMMCapabilities {
+	return types.VMMCapabilities{
+		KVM:      true,
+		Block:    true,
+		NinePfs:  true,
+		MultiNIC: true,
+		Archs:    commonArchs,
+	}
+}
+
+func (k *Kvmtool) Path() string {
+	return k.binaryPath
+}
+
+// BuildExecCmd builds and validates the lkvm command arguments without executing.
+func (k *Kvmtool) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
+	cmd := newCmdBuilder(k.binaryPath)
+	cmd.add("run")
+
+	cmd.add("--mem", BytesToStringMB(args.MemSizeB))
+	if args.VCPUs > 0 {
+		cmd.add("--cpus", fmt.Sprintf("%d", args.VCPUs))
+	}
+	cmd.add("--kernel", args.UnikernelPath, "--console", "serial")
+
+	for _, nic := range args.Nets {
+		netCli := ukernel.MonitorNetCli(nic.TapDev, nic.MAC)
+		if netCli == "" {
+			cmd.add("--network", fmt.Sprintf("mode=tap,tapif=%s,guest_mac=%s", nic.TapDev, nic.MAC))
+		} else {
+			cmd.addFragment(netCli)
+		}
+	}
+	if len(args.Nets) == 0 {
+		cmd.add("--network", "mode=none")
+	}
+
+	for _, blockArg := range ukernel.MonitorBlockCli() {
+		if blockArg.ExactArgs != "" {
+			cmd.addFragment(blockArg.ExactArgs)
+		} else {
+			cmd.addOpt("--disk", blockArg.Path)
+		}
+	}
+
+	extraMonArgs := ukernel.MonitorCli()
+	initrd := args.InitrdPath
+	if extraMonArgs.ExtraInitrd != "" {
+		if initrd != "" {
+			return nil, ErrMultipleInitrd
+		}
+		initrd = extraMonArgs.ExtraInitrd
+	}
+	cmd.addOpt("--initrd", initrd)
+
+	switch args.Sharedfs.Type {
+	case "9pfs":
+		cmd.add("--9p", args.Sharedfs.Path+",fs0")
+	default:
+		// No shared filesystem
+	}
+
+	cmd.addFragment(extraMonArgs.OtherArgs)
+
+	cmd.add("--params", args.Command)
+
+	exArgs := cmd.build()
+	vmmLog.WithField("kvmtool command", exArgs).Debug("Ready to execve kvmtool")
+
+	return exArgs, nil
+}
+
+// PreExec creates the directory where kvmtool places its control socket
+// and applies the seccomp profile of the container to kvmtool.
+func (k *Kvmtool) PreExec(args types.ExecArgs) error {
+	home := "/"
+	for _, env := range args.Environment {
+		if value, ok := strings.CutPrefix(env, "HOME="); ok && value != "" {
+			home = value
+		}
+	}
+	err := os.MkdirAll(filepath.Join(home, kvmtoolDir), 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create kvmtool's directory: %w", err)
+	}
+
+	return loadSeccompProfile(args, kvmtoolSeccompSyscalls(args.Sharedfs.Type == "9pfs"))
+}
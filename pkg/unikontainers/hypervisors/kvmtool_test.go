@@ -0,0 +1,132 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"path/filepath"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+func TestVMMFactoryKvmtool(t *testing.T) {
+	t.Parallel()
+	factory, exists := vmmFactories[KvmtoolVmm]
+	assert.True(t, exists, "kvmtool factory should exist")
+
+	vmm := factory.createFunc(KvmtoolBinary, "/usr/bin/lkvm", true)
+	kvmtool, ok := vmm.(*Kvmtool)
+	assert.True(t, ok, "factory should return *Kvmtool")
+	assert.Equal(t, "/usr/bin/lkvm", kvmtool.Path())
+	caps := kvmtool.Capabilities()
+	assert.True(t, caps.KVM)
+	assert.True(t, caps.NinePfs)
+	assert.False(t, caps.Virtiofs)
+}
+
+func TestKvmtoolBuildExecCmd(t *testing.T) {
+	kvmtool := &Kvmtool{binary: KvmtoolBinary, binaryPath: "/usr/bin/lkvm"}
+
+	t.Run("minimal", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{
+			UnikernelPath: "/unikernel/bzImage",
+			Command:       "console=ttyS0 init=/bin/app",
+			MemSizeB:      256 * 1000 * 1000,
+		}
+		cmd, err := kvmtool.BuildExecCmd(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"/usr/bin/lkvm", "run",
+			"--mem", "256",
+			"--kernel", "/unikernel/bzImage",
+			"--console", "serial",
+			"--network", "mode=none",
+			"--params", "console=ttyS0 init=/bin/app",
+		}, cmd)
+	})
+
+	t.Run("devices", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{
+			UnikernelPath: "/unikernel/bzImage",
+			Command:       "console=ttyS0",
+			MemSizeB:      512 * 1000 * 1000,
+			VCPUs:         2,
+			InitrdPath:    "/unikernel/initrd",
+			Nets:          []types.NetDevParams{{TapDev: "tap0_urunc", MAC: "aa:bb:cc:dd:ee:ff"}},
+			Sharedfs:      types.SharedfsParams{Type: "9pfs", Path: "/cntrRootfs"},
+		}
+		ukernel := &fakeUnikernel{blocks: []types.MonitorBlockArgs{{ID: "rootfs", Path: "/dev/dm-1"}}}
+		cmd, err := kvmtool.BuildExecCmd(args, ukernel)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"/usr/bin/lkvm", "run",
+			"--mem", "512",
+			"--cpus", "2",
+			"--kernel", "/unikernel/bzImage",
+			"--console", "serial",
+			"--network", "mode=tap,tapif=tap0_urunc,guest_mac=aa:bb:cc:dd:ee:ff",
+			"--disk", "/dev/dm-1",
+			"--initrd", "/unikernel/initrd",
+			"--9p", "/cntrRootfs,fs0",
+			"--params", "console=ttyS0",
+		}, cmd)
+	})
+
+	t.Run("multiple initrds", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{UnikernelPath: "/bzImage", InitrdPath: "/initrd"}
+		ukernel := &fakeUnikernel{monCli: types.MonitorCliArgs{ExtraInitrd: "/urunit.conf"}}
+		_, err := kvmtool.BuildExecCmd(args, ukernel)
+		assert.ErrorIs(t, err, ErrMultipleInitrd)
+	})
+}
+
+func TestKvmtoolSeccomp(t *testing.T) {
+	t.Run("policies are valid", func(t *testing.T) {
+		t.Parallel()
+		for _, sharedfs := range []bool{false, true} {
+			policy, _, err := compileSeccompProfile(&specs.LinuxSeccomp{DefaultAction: specs.ActAllow}, kvmtoolSeccompSyscalls(sharedfs))
+			assert.NoError(t, err, "sharedfs: %v", sharedfs)
+			_, err = policy.Assemble()
+			assert.NoError(t, err, "sharedfs: %v", sharedfs)
+		}
+	})
+
+	t.Run("9p requires more system calls", func(t *testing.T) {
+		t.Parallel()
+		assert.NotContains(t, kvmtoolSeccompSyscalls(false), "getdents64")
+		assert.Contains(t, kvmtoolSeccompSyscalls(true), "getdents64")
+	})
+
+	t.Run("lkvm run does not signal or connect", func(t *testing.T) {
+		t.Parallel()
+		for _, syscall := range []string{"kill", "connect", "sendmsg", "pipe2"} {
+			assert.NotContains(t, kvmtoolSeccompSyscalls(true), syscall)
+		}
+	})
+
+	t.Run("pre exec creates the control socket directory", func(t *testing.T) {
+		t.Parallel()
+		home := t.TempDir()
+		kvmtool := &Kvmtool{}
+		err := kvmtool.PreExec(types.ExecArgs{Environment: []string{"PATH=/bin", "HOME=" + home}})
+		assert.NoError(t, err)
+		assert.DirExists(t, filepath.Join(home, kvmtoolDir))
+	})
+}
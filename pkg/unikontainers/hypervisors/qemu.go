@@ -15,8 +15,11 @@
 package hypervisors
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
@@ -25,6 +28,23 @@ import (
 const (
 	QemuVmm    VmmType = "qemu"
 	QemuBinary string  = "qemu-system-"
+	// QemuMachineMicrovm is the minimal machine type of Qemu, without PCI
+	// and with virtio-mmio devices. The guest finds the virtio-mmio
+	// transports through its cmdline and hence ACPI and the automatic
+	// addition of the transports to the cmdline are disabled.
+	QemuMachineMicrovm = "microvm"
+	qemuMicrovmOptions = "acpi=off,ioapic2=off,auto-kernel-cmdline=off"
+	// The accelerators that Qemu can use. With QemuAccelAuto, Qemu uses
+	// KVM if it is available and falls back to TCG otherwise.
+	QemuAccelKVM  = "kvm"
+	QemuAccelTCG  = "tcg"
+	QemuAccelAuto = "auto"
+	kvmDevice     = "/dev/kvm"
+)
+
+var (
+	ErrMicrovmArch  = errors.New("the microvm machine type is only supported on x86_64")
+	ErrUnknownAccel = errors.New("unknown accelerator")
 )
 
 type Qemu struct {
@@ -41,109 +61,354 @@ func (q *Qemu) Ok() error {
 	return nil
 }
 
-// UsesKVM returns a bool value depending on if the monitor uses KVM
-func (q *Qemu) UsesKVM() bool {
-	return true
-}
-
-// SupportsSharedfs returns a bool value depending on the monitor support for shared-fs
-func (q *Qemu) SupportsSharedfs(_ string) bool {
-	return true
+// Capabilities returns the features that Qemu supports. The guest can get
+// paused through QMP.
+func (q *Qemu) Capabilities() types.VMMCapabilities {
+	return types.VMMCapabilities{
+		KVM:       true,
+		Block:     true,
+		NinePfs:   true,
+		Virtiofs:  true,
+		Vsock:     types.VsockVhost,
+		MultiNIC:  true,
+		Pause:     true,
+		Hugepages: true,
+		Archs:     commonArchs,
+	}
 }
 
 func (q *Qemu) Path() string {
 	return q.binaryPath
 }
 
+// ResolveQemuAccel returns the accelerator that Qemu will use for the
+// given accel setting. An empty setting defaults to KVM.
+func ResolveQemuAccel(accel string) (string, error) {
+	return resolveQemuAccel(accel, kvmDevice)
+}
+
+func resolveQemuAccel(accel string, kvmDev string) (string, error) {
+	switch accel {
+	case "", QemuAccelKVM:
+		return QemuAccelKVM, nil
+	case QemuAccelTCG:
+		return QemuAccelTCG, nil
+	case QemuAccelAuto:
+		f, err := os.OpenFile(kvmDev, os.O_RDWR, 0)
+		if err != nil {
+			vmmLog.WithError(err).Warn("KVM is not available, falling back to TCG")
+			return QemuAccelTCG, nil
+		}
+		_ = f.Close()
+		return QemuAccelKVM, nil
+	default:
+		return "", fmt.Errorf("%w %q for qemu", ErrUnknownAccel, accel)
+	}
+}
+
+// virtioDevice returns the name of the Qemu device for the given virtio
+// device (e.g. virtio-blk) depending on the transport of the machine.
+func virtioDevice(name string, microvm bool) string {
+	if microvm {
+		return name + "-device"
+	}
+	return name + "-pci"
+}
+
+// nolint:gocyclo
 func (q *Qemu) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
+	microvm := args.Machine == QemuMachineMicrovm
+	if microvm && runtime.GOARCH != "amd64" {
+		return nil, ErrMicrovmArch
+	}
 	qemuMem := BytesToStringMB(args.MemSizeB)
-	cmdString := q.binaryPath + " -m " + qemuMem + "M"
-	cmdString += " -L /usr/share/qemu"   // Set the path for qemu bios/data
-	cmdString += " -cpu host"            // Choose CPU
-	cmdString += " -enable-kvm"          // Enable KVM to use CPU virt extensions
-	cmdString += " -display none -vga none -serial stdio -monitor null" // Disable graphic output
+	if args.HugepageSize != 0 {
+		memMiB, err := hugepageMemMiB(args.MemSizeB, args.HugepageSize)
+		if err != nil {
+			return nil, err
+		}
+		qemuMem = strconv.FormatUint(memMiB, 10)
+	}
+	// The memory of the guest needs a file backend when it is shared with
+	// virtiofsd or it resides in hugepages
+	memBackend := ""
+	switch {
+	case args.HugepageSize != 0:
+		memBackend = "memory-backend-file,id=mem,size=" + qemuMem + "M,mem-path=" + HugepagesMountPath + ",prealloc=on"
+		if args.Sharedfs.Type == "virtiofs" {
+			memBackend += ",share=on"
+		}
+	case args.Sharedfs.Type == "virtiofs":
+		memBackend = "memory-backend-file,id=mem,size=" + qemuMem + "M,mem-path=/tmp,share=on"
+	}
+	cmd := newCmdBuilder(q.binaryPath)
+	cmd.add("-m", qemuMem+"M")
+	cmd.add("-L", "/usr/share/qemu") // Set the path for qemu bios/data
+	if args.Accel == QemuAccelTCG {
+		// Emulate all the features that TCG supports
+		cmd.add("-accel", "tcg", "-cpu", "max")
+	} else {
+		cmd.add("-cpu", "host") // Choose CPU
+		cmd.add("-enable-kvm")  // Enable KVM to use CPU virt extensions
+	}
+	cmd.add("-display", "none", "-vga", "none", "-serial", "stdio", "-monitor", "null") // Disable graphic output
+
+	if args.MonitorSocket != "" {
+		// Expose a QMP control socket, without waiting for a client to connect
+		cmd.add("-qmp", "unix:"+qemuEscape(args.MonitorSocket)+",server=on,wait=off")
+	}
 
 	if args.VCPUs > 0 {
-		cmdString += fmt.Sprintf(" -smp %d", args.VCPUs)
+		cmd.add("-smp", fmt.Sprintf("%d", args.VCPUs))
 	}
 
 	if args.Seccomp {
 		// Enable Seccomp in QEMU
-		cmdString += " --sandbox on"
-		// Allow or Deny Obsolete system calls
-		cmdString += ",obsolete=deny"
-		// Allow or Deny set*uid|gid system calls
-		cmdString += ",elevateprivileges=deny"
-		// Allow or Deny *fork and execve
-		cmdString += ",spawn=deny"
-		// Allow or Deny process affinity and schedular priority
-		cmdString += ",resourcecontrol=deny"
+		sandbox := []string{
+			"on",
+			"obsolete=deny",          // Allow or Deny Obsolete system calls
+			"elevateprivileges=deny", // Allow or Deny set*uid|gid system calls
+			"spawn=deny",             // Allow or Deny *fork and execve
+			"resourcecontrol=deny",   // Allow or Deny process affinity and schedular priority
+		}
+		cmd.add("--sandbox", strings.Join(sandbox, ","))
 	}
 
 	// TODO: Check if this check causes any performance drop
 	// or explore alternative implementations
-	if runtime.GOARCH == "arm64" {
-		machineType := " -M virt"
-		cmdString += machineType
-	}
-
-	cmdString += " -kernel " + args.UnikernelPath
-	if args.Net.TapDev != "" {
-		netcli := ukernel.MonitorNetCli(args.Net.TapDev, args.Net.MAC)
-		if netcli == "" {
-			netcli += " -net nic,model=virtio,macaddr="
-			netcli += args.Net.MAC
-			netcli += " -net tap,script=no,downscript=no,ifname="
-			netcli += args.Net.TapDev
+	switch {
+	case microvm:
+		machine := QemuMachineMicrovm + "," + qemuMicrovmOptions
+		if memBackend != "" {
+			// microvm does not support NUMA nodes
+			machine += ",memory-backend=mem"
+		}
+		cmd.add("-M", machine)
+	case args.Machine != "":
+		cmd.add("-M", args.Machine)
+	case runtime.GOARCH == "arm64":
+		cmd.add("-M", "virt")
+	}
+
+	cmd.add("-kernel", args.UnikernelPath)
+	for i, nic := range args.Nets {
+		netcli := ukernel.MonitorNetCli(nic.TapDev, nic.MAC)
+		id := fmt.Sprintf("net%d", i)
+		switch {
+		case netcli != "":
+			cmd.addFragment(netcli)
+		case microvm, i > 0:
+			// The NICs of the legacy -net option share a hub, so every
+			// NIC besides the first one gets its own netdev
+			netdev := "tap,id=" + id + ",script=no,downscript=no," + qemuOpt("ifname", nic.TapDev)
+			if q.vhost {
+				netdev += ",vhost=on"
+			}
+			device := "virtio-net-pci"
+			if microvm {
+				device = "virtio-net-device"
+			}
+			cmd.add("-netdev", netdev)
+			cmd.add("-device", device+",netdev="+id+",mac="+nic.MAC)
+		default:
+			cmd.add("-net", "nic,model=virtio,macaddr="+nic.MAC)
+			tap := "tap,script=no,downscript=no," + qemuOpt("ifname", nic.TapDev)
 			if q.vhost {
-				netcli += ",vhost=on"
+				tap += ",vhost=on"
 			}
+			cmd.add("-net", tap)
 		}
-		cmdString += netcli
-	} else {
-		cmdString += " -nic none"
+	}
+	if len(args.Nets) == 0 {
+		cmd.add("-nic", "none")
 	}
 	blockArgs := ukernel.MonitorBlockCli()
 	for _, blockArg := range blockArgs {
-		blockCli := blockArg.ExactArgs
-		if blockCli == "" && blockArg.ID != "" && blockArg.Path != "" {
-			blockCli1 := fmt.Sprintf(" -device virtio-blk-pci,serial=%s,drive=%s,scsi=off", blockArg.ID, blockArg.ID)
-			blockCli2 := fmt.Sprintf(" -drive format=raw,if=none,id=%s,file=%s", blockArg.ID, blockArg.Path)
-			blockCli = blockCli1 + blockCli2
+		if blockArg.ExactArgs != "" {
+			cmd.addFragment(blockArg.ExactArgs)
+			continue
 		}
-		cmdString += blockCli
-	}
-	if args.InitrdPath != "" {
-		cmdString += " -initrd " + args.InitrdPath
+		if blockArg.ID == "" || blockArg.Path == "" {
+			continue
+		}
+		device := fmt.Sprintf("%s,serial=%s,drive=%s", virtioDevice("virtio-blk", microvm), blockArg.ID, blockArg.ID)
+		if !microvm {
+			device += ",scsi=off"
+		}
+		cmd.add("-device", device)
+		cmd.add("-drive", "format=raw,if=none,id="+blockArg.ID+","+qemuOpt("file", blockArg.Path))
 	}
+	cmd.addOpt("-initrd", args.InitrdPath)
 	switch args.Sharedfs.Type {
 	case "9pfs":
-		cmdString += " -fsdev local,id=rootfs9p,security_model=none,path=" + args.Sharedfs.Path
-		cmdString += " -device virtio-9p-pci,fsdev=rootfs9p,mount_tag=fs0"
+		cmd.add("-fsdev", "local,id=rootfs9p,security_model=none,"+qemuOpt("path", args.Sharedfs.Path))
+		cmd.add("-device", virtioDevice("virtio-9p", microvm)+",fsdev=rootfs9p,mount_tag=fs0")
 	case "virtiofs":
-		cmdString += " -object memory-backend-file,id=mem,size=" + qemuMem + "M,mem-path=/tmp,share=on"
-		cmdString += " -numa node,memdev=mem"
-		cmdString += " -chardev socket,id=char0,path=/tmp/vhostqemu"
-		cmdString += " -device vhost-user-fs-pci,queue-size=1024,chardev=char0,tag=fs0"
+		cmd.add("-chardev", "socket,id=char0,path=/tmp/vhostqemu")
+		cmd.add("-device", virtioDevice("vhost-user-fs", microvm)+",queue-size=1024,chardev=char0,tag=fs0")
 	default:
 		// Nothing to add
 	}
-	extraMonArgs := ukernel.MonitorCli()
-	if extraMonArgs.ExtraInitrd != "" {
-		cmdString += " -initrd " + extraMonArgs.ExtraInitrd
+	if memBackend != "" {
+		cmd.add("-object", memBackend)
+		if !microvm {
+			cmd.add("-numa", "node,memdev=mem")
+		}
 	}
-	cmdString += extraMonArgs.OtherArgs
+	extraMonArgs := ukernel.MonitorCli()
+	cmd.addOpt("-initrd", extraMonArgs.ExtraInitrd)
+	cmd.addFragment(extraMonArgs.OtherArgs)
 
 	if args.VAccelType == "vsock" {
-		cmdString += " -device vhost-vsock-pci,id=vhost-vsock-pci0,guest-cid=" + fmt.Sprintf("%d", args.VSockDevID)
+		vsockDev := virtioDevice("vhost-vsock", microvm)
+		cmd.add("-device", fmt.Sprintf("%s,id=%s0,guest-cid=%d", vsockDev, vsockDev, args.VSockDevID))
 	}
 
-	exArgs := strings.Split(cmdString, " ")
-	exArgs = append(exArgs, "-append", args.Command)
-	return exArgs, nil
+	cmd.add("-append", args.Command)
+	return cmd.build(), nil
 }
 
-// PreExec performs pre-execution setup. QEMU has no special pre-exec requirements.
-func (q *Qemu) PreExec(_ types.ExecArgs) error {
-	return nil
+// qemuSeccompSyscalls returns the system calls that the Qemu process
+// requires, including the ones for loading the filter of its own sandbox.
+func qemuSeccompSyscalls() []string {
+	syscalls := []string{
+		"open",
+		"openat",
+		"close",
+		"read",
+		"write",
+		"readv",
+		"writev",
+		"pread64",
+		"pwrite64",
+		"preadv",
+		"pwritev",
+		"preadv2",
+		"pwritev2",
+		"lseek",
+		"fstat",
+		"stat",
+		"lstat",
+		"statx",
+		"fstatfs",
+		"statfs",
+		"access",
+		"faccessat",
+		"faccessat2",
+		"readlink",
+		"readlinkat",
+		"getdents64",
+		"fcntl",
+		"ioctl",
+		"dup",
+		"dup2",
+		"dup3",
+		"pipe",
+		"pipe2",
+		"eventfd2",
+		"fallocate",
+		"fdatasync",
+		"fsync",
+		"ftruncate",
+		"unlink",
+		"unlinkat",
+		"mkdir",
+		"mkdirat",
+		"flock",
+		"getcwd",
+		"umask",
+		"memfd_create",
+		"io_setup",
+		"io_submit",
+		"io_getevents",
+		"io_destroy",
+		"io_uring_setup",
+		"io_uring_enter",
+		"io_uring_register",
+		"mmap",
+		"munmap",
+		"mprotect",
+		"mremap",
+		"madvise",
+		"mbind",
+		"mlock",
+		"munlock",
+		"mlockall",
+		"brk",
+		"get_mempolicy",
+		"set_mempolicy",
+		"userfaultfd",
+		"getppid",
+		"getuid",
+		"geteuid",
+		"getgid",
+		"getegid",
+		"getresuid",
+		"getresgid",
+		"getrlimit",
+		"setrlimit",
+		"sched_getaffinity",
+		"sched_setaffinity",
+		"sched_getparam",
+		"sched_getscheduler",
+		"sched_setscheduler",
+		"getpriority",
+		"setpriority",
+		"prctl",
+		"arch_prctl",
+		"seccomp",
+		"capget",
+		"uname",
+		"sysinfo",
+		"getrandom",
+		"membarrier",
+		"kill",
+		"tkill",
+		"wait4",
+		"rt_sigtimedwait",
+		"signalfd4",
+		"restart_syscall",
+		"clock_getres",
+		"clock_nanosleep",
+		"gettimeofday",
+		"timerfd_create",
+		"timerfd_settime",
+		"timer_create",
+		"timer_settime",
+		"timer_delete",
+		"poll",
+		"ppoll",
+		"select",
+		"pselect6",
+		"epoll_create1",
+		"epoll_wait",
+		"socket",
+		"socketpair",
+		"bind",
+		"listen",
+		"accept",
+		"accept4",
+		"connect",
+		"getsockname",
+		"getpeername",
+		"getsockopt",
+		"setsockopt",
+		"sendmsg",
+		"recvmsg",
+		"sendto",
+		"recvfrom",
+		"shutdown",
+	}
+	// The architectures name the stat system call differently
+	if runtime.GOARCH == "arm64" {
+		syscalls = append(syscalls, "fstatat")
+	} else {
+		syscalls = append(syscalls, "newfstatat")
+	}
+	return append(syscalls, goRuntimeSyscalls...)
+}
+
+// PreExec applies the seccomp profile of the container to QEMU.
+func (q *Qemu) PreExec(args types.ExecArgs) error {
+	return loadSeccompProfile(args, qemuSeccompSyscalls())
 }
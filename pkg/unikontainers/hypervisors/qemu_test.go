@@ -0,0 +1,173 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+func TestQemuMachine(t *testing.T) {
+	q := &Qemu{binaryPath: "/usr/bin/qemu-system-x86_64", vhost: true}
+	devArgs := types.ExecArgs{
+		UnikernelPath: "/unikernel/app",
+		Command:       "app",
+		Nets:          []types.NetDevParams{{TapDev: "tap0_urunc", MAC: "aa:bb:cc:dd:ee:ff"}},
+		Sharedfs:      types.SharedfsParams{Type: "9pfs", Path: "/cntrRootfs"},
+		VAccelType:    "vsock",
+		VSockDevID:    3,
+	}
+	ukernel := &fakeUnikernel{blocks: []types.MonitorBlockArgs{{ID: "vol1", Path: "/dev/dm-2"}}}
+
+	t.Run("default machine uses pci devices", func(t *testing.T) {
+		t.Parallel()
+		cmd, err := q.BuildExecCmd(devArgs, ukernel)
+		assert.NoError(t, err)
+		cmdline := strings.Join(cmd, " ")
+		assert.NotContains(t, cmdline, "microvm")
+		assert.Contains(t, cmdline, "-device virtio-blk-pci,serial=vol1,drive=vol1,scsi=off")
+		assert.Contains(t, cmdline, "-device virtio-9p-pci,fsdev=rootfs9p,mount_tag=fs0")
+		assert.Contains(t, cmdline, "-device vhost-vsock-pci,id=vhost-vsock-pci0,guest-cid=3")
+	})
+
+	t.Run("custom machine", func(t *testing.T) {
+		t.Parallel()
+		args := devArgs
+		args.Machine = "q35"
+		cmd, err := q.BuildExecCmd(args, ukernel)
+		assert.NoError(t, err)
+		assert.Contains(t, strings.Join(cmd, " "), "-M q35")
+	})
+
+	t.Run("microvm uses virtio-mmio devices", func(t *testing.T) {
+		t.Parallel()
+		if runtime.GOARCH != "amd64" {
+			t.Skip("microvm is only supported on x86_64")
+		}
+		args := devArgs
+		args.Machine = QemuMachineMicrovm
+		cmd, err := q.BuildExecCmd(args, ukernel)
+		assert.NoError(t, err)
+		cmdline := strings.Join(cmd, " ")
+		assert.Contains(t, cmdline, "-M microvm,acpi=off,ioapic2=off,auto-kernel-cmdline=off")
+		assert.Contains(t, cmdline, "-netdev tap,id=net0,script=no,downscript=no,ifname=tap0_urunc,vhost=on")
+		assert.Contains(t, cmdline, "-device virtio-net-device,netdev=net0,mac=aa:bb:cc:dd:ee:ff")
+		assert.Contains(t, cmdline, "-device virtio-blk-device,serial=vol1,drive=vol1 ")
+		assert.Contains(t, cmdline, "-device virtio-9p-device,fsdev=rootfs9p,mount_tag=fs0")
+		assert.Contains(t, cmdline, "-device vhost-vsock-device,id=vhost-vsock-device0,guest-cid=3")
+		assert.NotContains(t, cmdline, "-pci")
+	})
+
+	t.Run("every nic besides the first gets its own netdev", func(t *testing.T) {
+		t.Parallel()
+		args := devArgs
+		args.Nets = []types.NetDevParams{
+			{TapDev: "tap0_urunc", MAC: "aa:bb:cc:dd:ee:ff"},
+			{TapDev: "tap1_urunc", MAC: "aa:bb:cc:dd:ee:fe"},
+		}
+		cmd, err := q.BuildExecCmd(args, ukernel)
+		assert.NoError(t, err)
+		cmdline := strings.Join(cmd, " ")
+		assert.Contains(t, cmdline, "-net nic,model=virtio,macaddr=aa:bb:cc:dd:ee:ff -net tap,script=no,downscript=no,ifname=tap0_urunc,vhost=on")
+		assert.Contains(t, cmdline, "-netdev tap,id=net1,script=no,downscript=no,ifname=tap1_urunc,vhost=on")
+		assert.Contains(t, cmdline, "-device virtio-net-pci,netdev=net1,mac=aa:bb:cc:dd:ee:fe")
+		assert.NotContains(t, cmdline, "-nic none")
+	})
+
+	t.Run("no nics", func(t *testing.T) {
+		t.Parallel()
+		args := devArgs
+		args.Nets = nil
+		cmd, err := q.BuildExecCmd(args, ukernel)
+		assert.NoError(t, err)
+		cmdline := strings.Join(cmd, " ")
+		assert.Contains(t, cmdline, "-nic none")
+		assert.NotContains(t, cmdline, "-net ")
+	})
+
+	t.Run("microvm with virtiofs", func(t *testing.T) {
+		t.Parallel()
+		if runtime.GOARCH != "amd64" {
+			t.Skip("microvm is only supported on x86_64")
+		}
+		args := types.ExecArgs{
+			UnikernelPath: "/unikernel/app",
+			Machine:       QemuMachineMicrovm,
+			Sharedfs:      types.SharedfsParams{Type: "virtiofs", Path: "/cntrRootfs"},
+		}
+		cmd, err := q.BuildExecCmd(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		cmdline := strings.Join(cmd, " ")
+		assert.Contains(t, cmdline, "auto-kernel-cmdline=off,memory-backend=mem")
+		assert.Contains(t, cmdline, "-device vhost-user-fs-device,queue-size=1024,chardev=char0,tag=fs0")
+		assert.NotContains(t, cmdline, "-numa")
+	})
+}
+
+func TestQemuAccel(t *testing.T) {
+	q := &Qemu{binaryPath: "/usr/bin/qemu-system-x86_64"}
+
+	t.Run("kvm by default", func(t *testing.T) {
+		t.Parallel()
+		cmd, err := q.BuildExecCmd(types.ExecArgs{UnikernelPath: "/unikernel/app"}, &fakeUnikernel{})
+		assert.NoError(t, err)
+		cmdline := strings.Join(cmd, " ")
+		assert.Contains(t, cmdline, "-cpu host -enable-kvm")
+		assert.NotContains(t, cmdline, "tcg")
+	})
+
+	t.Run("tcg uses a generic cpu model", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{UnikernelPath: "/unikernel/app", Accel: QemuAccelTCG}
+		cmd, err := q.BuildExecCmd(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		cmdline := strings.Join(cmd, " ")
+		assert.Contains(t, cmdline, "-accel tcg -cpu max")
+		assert.NotContains(t, cmdline, "-enable-kvm")
+		assert.NotContains(t, cmdline, "-cpu host")
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		t.Parallel()
+		kvmDev := filepath.Join(t.TempDir(), "kvm")
+		for _, accel := range []string{"", QemuAccelKVM} {
+			resolved, err := resolveQemuAccel(accel, kvmDev)
+			assert.NoError(t, err)
+			assert.Equal(t, QemuAccelKVM, resolved)
+		}
+		resolved, err := resolveQemuAccel(QemuAccelTCG, kvmDev)
+		assert.NoError(t, err)
+		assert.Equal(t, QemuAccelTCG, resolved)
+
+		// auto falls back to tcg, while kvmDev does not exist
+		resolved, err = resolveQemuAccel(QemuAccelAuto, kvmDev)
+		assert.NoError(t, err)
+		assert.Equal(t, QemuAccelTCG, resolved)
+
+		assert.NoError(t, os.WriteFile(kvmDev, nil, 0o600))
+		resolved, err = resolveQemuAccel(QemuAccelAuto, kvmDev)
+		assert.NoError(t, err)
+		assert.Equal(t, QemuAccelKVM, resolved)
+
+		_, err = resolveQemuAccel("hvf", kvmDev)
+		assert.ErrorIs(t, err, ErrUnknownAccel)
+	})
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const DefaultQMPTimeout = 5 * time.Second
+
+var ErrQMPGreeting = errors.New("invalid qmp greeting")
+
+// QMPError is an error returned by Qemu as a response to a QMP command
+type QMPError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+func (e *QMPError) Error() string {
+	return fmt.Sprintf("qmp error %s: %s", e.Class, e.Desc)
+}
+
+// QMPVersion is the version of Qemu, as reported in the QMP greeting
+type QMPVersion struct {
+	Qemu struct {
+		Major int `json:"major"`
+		Minor int `json:"minor"`
+		Micro int `json:"micro"`
+	} `json:"qemu"`
+	Package string `json:"package"`
+}
+
+// QMPStatus is the response of the query-status command
+type QMPStatus struct {
+	Running bool   `json:"running"`
+	Status  string `json:"status"`
+}
+
+// QMPCPUInfo describes a vCPU, as returned by the query-cpus-fast command
+type QMPCPUInfo struct {
+	CPUIndex int    `json:"cpu-index"`
+	QOMPath  string `json:"qom-path"`
+	ThreadID int    `json:"thread-id"`
+	Target   string `json:"target"`
+}
+
+type qmpCommand struct {
+	Execute   string `json:"execute"`
+	Arguments any    `json:"arguments,omitempty"`
+}
+
+// qmpMessage holds any message that Qemu sends over QMP. A message
+// is either the greeting, a response to a command or an event.
+type qmpMessage struct {
+	Greeting *struct {
+		Version QMPVersion `json:"version"`
+	} `json:"QMP,omitempty"`
+	Return json.RawMessage `json:"return,omitempty"`
+	Error  *QMPError       `json:"error,omitempty"`
+	Event  string          `json:"event,omitempty"`
+}
+
+// QMPClient is a client for the QEMU Machine Protocol over a unix socket.
+// Commands are executed one at a time and asynchronous events are ignored.
+type QMPClient struct {
+	conn    net.Conn
+	dec     *json.Decoder
+	enc     *json.Encoder
+	timeout time.Duration
+	mu      sync.Mutex
+	Version QMPVersion
+}
+
+// DialQMP connects to the QMP socket at path and negotiates the
+// capabilities, so that the returned client is ready to execute commands.
+// The timeout applies to every command, including the negotiation.
+func DialQMP(path string, timeout time.Duration) (*QMPClient, error) {
+	conn, err := dialUnix(path, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to qmp socket %s: %w", path, err)
+	}
+	q := &QMPClient{
+		conn:    conn,
+		dec:     json.NewDecoder(conn),
+		enc:     json.NewEncoder(conn),
+		timeout: timeout,
+	}
+	err = q.handshake()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *QMPClient) handshake() error {
+	err := q.conn.SetDeadline(time.Now().Add(q.timeout))
+	if err != nil {
+		return err
+	}
+	var greeting qmpMessage
+	err = q.dec.Decode(&greeting)
+	if err != nil {
+		return fmt.Errorf("failed to read qmp greeting: %w", err)
+	}
+	if greeting.Greeting == nil {
+		return ErrQMPGreeting
+	}
+	q.Version = greeting.Greeting.Version
+	vmmLog.Debugf("connected to qemu %d.%d.%d over qmp", q.Version.Qemu.Major,
+		q.Version.Qemu.Minor, q.Version.Qemu.Micro)
+
+	return q.Execute("qmp_capabilities", nil, nil)
+}
+
+// Execute sends a QMP command with the given arguments and waits for its
+// response. If result is not nil, the return value of the command gets
+// unmarshalled into it.
+func (q *QMPClient) Execute(cmd string, args any, result any) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := q.conn.SetDeadline(time.Now().Add(q.timeout))
+	if err != nil {
+		return err
+	}
+	err = q.enc.Encode(qmpCommand{Execute: cmd, Arguments: args})
+	if err != nil {
+		return fmt.Errorf("failed to send qmp command %s: %w", cmd, err)
+	}
+
+	for {
+		var msg qmpMessage
+		err = q.dec.Decode(&msg)
+		if err != nil {
+			return fmt.Errorf("failed to read response of qmp command %s: %w", cmd, err)
+		}
+		if msg.Event != "" {
+			vmmLog.Debugf("ignoring qmp event %s", msg.Event)
+			continue
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Return) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Return, result)
+	}
+}
+
+// QueryStatus returns the run state of the VM
+func (q *QMPClient) QueryStatus() (QMPStatus, error) {
+	var status QMPStatus
+	err := q.Execute("query-status", nil, &status)
+	return status, err
+}
+
+// SystemPowerdown requests a graceful shutdown of the guest (ACPI power button)
+func (q *QMPClient) SystemPowerdown() error {
+	return q.Execute("system_powerdown", nil, nil)
+}
+
+// Stop pauses the execution of the VM
+func (q *QMPClient) Stop() error {
+	return q.Execute("stop", nil, nil)
+}
+
+// Cont resumes the execution of a paused VM
+func (q *QMPClient) Cont() error {
+	return q.Execute("cont", nil, nil)
+}
+
+// QueryCPUsFast returns information about the vCPUs of the VM
+func (q *QMPClient) QueryCPUsFast() ([]QMPCPUInfo, error) {
+	var cpus []QMPCPUInfo
+	err := q.Execute("query-cpus-fast", nil, &cpus)
+	return cpus, err
+}
+
+// Balloon sets the target memory size of the guest in bytes. It requires
+// a virtio-balloon device in the VM.
+func (q *QMPClient) Balloon(sizeB uint64) error {
+	return q.Execute("balloon", map[string]uint64{"value": sizeB}, nil)
+}
+
+// Quit terminates Qemu immediately. Qemu might close the connection
+// before it responds and hence, a closed connection is not an error.
+func (q *QMPClient) Quit() error {
+	err := q.Execute("quit", nil, nil)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) {
+		return nil
+	}
+	return err
+}
+
+// Close closes the connection to the QMP socket
+func (q *QMPClient) Close() error {
+	return q.conn.Close()
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+const qmpGreeting = `{"QMP": {"version": {"qemu": {"micro": 1, "minor": 2, "major": 8}, "package": ""}, "capabilities": ["oob"]}}`
+
+// fakeQMPServer emulates the QMP server of Qemu. It replies to each
+// command with the respective response and records the received commands.
+type fakeQMPServer struct {
+	path      string
+	responses map[string]string
+	mu        sync.Mutex
+	commands  []qmpCommand
+}
+
+func newFakeQMPServer(t *testing.T, dir string, responses map[string]string) *fakeQMPServer {
+	t.Helper()
+	s := &fakeQMPServer{
+		path:      filepath.Join(dir, MonitorSocketName),
+		responses: responses,
+	}
+	l, err := net.Listen("unix", s.path)
+	assert.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeQMPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	_, err := conn.Write([]byte(qmpGreeting + "\n"))
+	if err != nil {
+		return
+	}
+	dec := json.NewDecoder(conn)
+	for {
+		var cmd qmpCommand
+		err := dec.Decode(&cmd)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		s.mu.Unlock()
+		if cmd.Execute == "quit" {
+			return
+		}
+		resp, ok := s.responses[cmd.Execute]
+		if !ok {
+			resp = `{"return": {}}`
+		}
+		_, err = conn.Write([]byte(resp + "\n"))
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeQMPServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cmds []string
+	for _, c := range s.commands {
+		cmds = append(cmds, c.Execute)
+	}
+	return cmds
+}
+
+func TestQMPClient(t *testing.T) {
+	responses := map[string]string{
+		"query-status": `{"event": "RESUME", "data": {}, "timestamp": {"seconds": 1, "microseconds": 2}}
+{"return": {"status": "running", "singlestep": false, "running": true}}`,
+		"query-cpus-fast": `{"return": [{"thread-id": 1234, "props": {"core-id": 0}, "qom-path": "/machine/unattached/device[0]", "cpu-index": 0, "target": "x86_64"}]}`,
+		"balloon":         `{"error": {"class": "DeviceNotActive", "desc": "No balloon device has been activated"}}`,
+	}
+
+	t.Run("handshake and commands", func(t *testing.T) {
+		t.Parallel()
+		s := newFakeQMPServer(t, t.TempDir(), responses)
+		q, err := DialQMP(s.path, time.Second)
+		assert.NoError(t, err)
+		defer q.Close()
+		assert.Equal(t, 8, q.Version.Qemu.Major)
+		assert.Equal(t, 2, q.Version.Qemu.Minor)
+
+		status, err := q.QueryStatus()
+		assert.NoError(t, err)
+		assert.True(t, status.Running)
+		assert.Equal(t, "running", status.Status)
+
+		cpus, err := q.QueryCPUsFast()
+		assert.NoError(t, err)
+		assert.Len(t, cpus, 1)
+		assert.Equal(t, 1234, cpus[0].ThreadID)
+		assert.Equal(t, "x86_64", cpus[0].Target)
+
+		assert.NoError(t, q.Stop())
+		assert.NoError(t, q.Cont())
+		assert.NoError(t, q.SystemPowerdown())
+		assert.NoError(t, q.Quit())
+
+		assert.Equal(t, []string{"qmp_capabilities", "query-status", "query-cpus-fast",
+			"stop", "cont", "system_powerdown", "quit"}, s.received())
+	})
+
+	t.Run("command error", func(t *testing.T) {
+		t.Parallel()
+		s := newFakeQMPServer(t, t.TempDir(), responses)
+		q, err := DialQMP(s.path, time.Second)
+		assert.NoError(t, err)
+		defer q.Close()
+
+		err = q.Balloon(128 * 1024 * 1024)
+		var qmpErr *QMPError
+		assert.ErrorAs(t, err, &qmpErr)
+		assert.Equal(t, "DeviceNotActive", qmpErr.Class)
+		s.mu.Lock()
+		assert.Equal(t, map[string]any{"value": float64(128 * 1024 * 1024)}, s.commands[1].Arguments)
+		s.mu.Unlock()
+	})
+
+	t.Run("long socket path", func(t *testing.T) {
+		t.Parallel()
+		dir := filepath.Join(t.TempDir(), strings.Repeat("d", 60), strings.Repeat("e", 60))
+		assert.NoError(t, os.MkdirAll(dir, 0o755))
+		// Listen through a short path, since the server can not bind to a long one
+		cwd := filepath.Join(t.TempDir(), "short")
+		assert.NoError(t, os.Symlink(dir, cwd))
+		s := newFakeQMPServer(t, cwd, responses)
+		longPath := filepath.Join(dir, filepath.Base(s.path))
+		assert.GreaterOrEqual(t, len(longPath), maxUnixPathLen)
+
+		q, err := DialQMP(longPath, time.Second)
+		assert.NoError(t, err)
+		defer q.Close()
+		_, err = q.QueryStatus()
+		assert.NoError(t, err)
+	})
+
+	t.Run("no server", func(t *testing.T) {
+		t.Parallel()
+		_, err := DialQMP(filepath.Join(t.TempDir(), MonitorSocketName), time.Second)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid greeting", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), MonitorSocketName)
+		l, err := net.Listen("unix", path)
+		assert.NoError(t, err)
+		defer l.Close()
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			_, _ = conn.Write([]byte(`{"return": {}}` + "\n"))
+		}()
+		_, err = DialQMP(path, time.Second)
+		assert.ErrorIs(t, err, ErrQMPGreeting)
+	})
+}
+
+func TestQemuMonitorSocket(t *testing.T) {
+	q := &Qemu{binaryPath: "/usr/bin/qemu-system-x86_64"}
+	args := types.ExecArgs{
+		UnikernelPath: "/unikernel/app",
+		Command:       "app",
+		MonitorSocket: "/" + MonitorSocketName,
+	}
+
+	cmd, err := q.BuildExecCmd(args, &fakeUnikernel{})
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(cmd, " "), "-qmp unix:/monitor.sock,server=on,wait=off")
+
+	args.MonitorSocket = ""
+	cmd, err = q.BuildExecCmd(args, &fakeUnikernel{})
+	assert.NoError(t, err)
+	assert.NotContains(t, cmd, "-qmp")
+}
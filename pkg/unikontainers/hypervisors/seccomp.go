@@ -0,0 +1,360 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"slices"
+	"strings"
+
+	seccomp "github.com/elastic/go-seccomp-bpf"
+	"github.com/elastic/go-seccomp-bpf/arch"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+const (
+	// The modes of the seccomp filter of a monitor. With SeccompModeLog,
+	// the system calls that the filter denies are logged instead, while
+	// with SeccompModeLearn, no system call gets denied and the system
+	// calls that the monitor uses get recorded in a profile.
+	SeccompModeEnforce = "enforce"
+	SeccompModeLog     = "log"
+	SeccompModeLearn   = "learn"
+	// The default directory of the profiles that learn mode records
+	DefaultSeccompLearnDir = "/var/lib/urunc/seccomp"
+)
+
+var ErrUnknownSeccompMode = errors.New("unknown seccomp mode")
+
+// ValidateSeccompMode checks the seccomp mode of a monitor. An empty mode
+// defaults to SeccompModeEnforce.
+func ValidateSeccompMode(mode string) error {
+	switch mode {
+	case "", SeccompModeEnforce, SeccompModeLog, SeccompModeLearn:
+		return nil
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownSeccompMode, mode)
+	}
+}
+
+// goRuntimeSyscalls are the system calls that the Go runtime might use
+// after urunc loads the seccomp filter of a monitor and until execve.
+var goRuntimeSyscalls = []string{
+	"execve",
+	"futex",
+	"clone",
+	"clone3",
+	"mmap",
+	"munmap",
+	"madvise",
+	"mprotect",
+	"read",
+	"write",
+	"close",
+	"fcntl",
+	"rt_sigaction",
+	"rt_sigprocmask",
+	"rt_sigreturn",
+	"sigaltstack",
+	"sched_yield",
+	"nanosleep",
+	"clock_gettime",
+	"epoll_ctl",
+	"epoll_pwait",
+	"getpid",
+	"gettid",
+	"tgkill",
+	"set_robust_list",
+	"set_tid_address",
+	"rseq",
+	"prlimit64",
+	"exit",
+	"exit_group",
+}
+
+// supervisorSyscalls are the system calls that urunc additionally uses,
+// when it executes the monitor as its child and waits for it to exit. The
+// Go runtime keeps running in the meantime and hence, a supervised monitor
+// also gets goRuntimeSyscalls (e.g. clone and execve for the fork).
+var supervisorSyscalls = []string{
+	"pipe2",
+	"prctl",
+	"getppid",
+	"dup3",
+	"wait4",
+	"waitid",
+	"kill",
+}
+
+// seccompBaseline returns the allowlist of the filter for the monitor with
+// the given baseline. Not every baseline includes goRuntimeSyscalls (e.g. the
+// one of Solo5-hvt), hence they get added for a supervised monitor, along
+// with supervisorSyscalls.
+func seccompBaseline(args types.ExecArgs, baseline []string) []string {
+	if !args.Supervised || baseline == nil {
+		return baseline
+	}
+	baseline = append(slices.Clone(baseline), goRuntimeSyscalls...)
+	return append(baseline, supervisorSyscalls...)
+}
+
+// seccompAction converts the action of an OCI seccomp rule to an action
+// of the seccomp filter. Actions that can not be expressed in the filter
+// fall back to returning an error.
+func seccompAction(action specs.LinuxSeccompAction, errnoRet *uint) seccomp.Action {
+	switch action {
+	case specs.ActAllow:
+		return seccomp.ActionAllow
+	case specs.ActLog:
+		return seccomp.ActionLog
+	case specs.ActKill, specs.ActKillThread:
+		return seccomp.ActionKillThread
+	case specs.ActKillProcess:
+		return seccomp.ActionKillProcess
+	case specs.ActTrap:
+		return seccomp.ActionTrap
+	case specs.ActTrace:
+		return seccomp.ActionTrace
+	default:
+		if errnoRet != nil && *errnoRet != 0 {
+			return seccomp.ActionErrno | seccomp.Action(*errnoRet&0xffff) //nolint: gosec
+		}
+		return seccomp.ActionErrno
+	}
+}
+
+func isAllowAction(action specs.LinuxSeccompAction) bool {
+	return action == specs.ActAllow || action == specs.ActLog
+}
+
+// seccompConditions converts the argument comparisons of an OCI seccomp
+// rule to conditions of the seccomp filter. It returns false if any of
+// the comparisons can not be expressed in the filter.
+func seccompConditions(args []specs.LinuxSeccompArg) (seccomp.ArgumentConditions, bool) {
+	conditions := make(seccomp.ArgumentConditions, 0, len(args))
+	for _, arg := range args {
+		c := seccomp.Condition{Argument: uint32(arg.Index), Value: arg.Value} //nolint: gosec
+		switch arg.Op {
+		case specs.OpEqualTo:
+			c.Operation = seccomp.Equal
+		case specs.OpNotEqual:
+			c.Operation = seccomp.NotEqual
+		case specs.OpLessThan:
+			c.Operation = seccomp.LessThan
+		case specs.OpLessEqual:
+			c.Operation = seccomp.LessOrEqual
+		case specs.OpGreaterThan:
+			c.Operation = seccomp.GreaterThan
+		case specs.OpGreaterEqual:
+			c.Operation = seccomp.GreaterOrEqual
+		case specs.OpMaskedEqual:
+			// (arg & Value) == ValueTwo can only be expressed when none
+			// or a single bit of the mask needs to be set
+			switch {
+			case arg.ValueTwo == 0:
+				c.Operation = seccomp.BitsNotSet
+			case arg.ValueTwo == arg.Value && bits.OnesCount64(arg.Value) == 1:
+				c.Operation = seccomp.BitsSet
+			default:
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, true
+}
+
+// compileSeccompProfile compiles the OCI seccomp profile of a container
+// to a seccomp policy for a monitor. The policy allows only the system
+// calls that both the baseline allowlist of the monitor and the profile
+// allow. A nil baseline compiles the profile as is. It also returns the
+// system calls of the baseline that the profile denies.
+//
+// Rules with argument comparisons that the filter can not express are
+// made stricter: such allow rules are dropped and such deny rules
+// apply regardless of the arguments.
+func compileSeccompProfile(profile *specs.LinuxSeccomp, baseline []string) (seccomp.Policy, []string, error) {
+	info, err := arch.GetInfo("")
+	if err != nil {
+		return seccomp.Policy{}, nil, err
+	}
+	inScope := func(name string) bool {
+		_, known := info.SyscallNames[name]
+		return known && (baseline == nil || slices.Contains(baseline, name))
+	}
+
+	var groups []seccomp.SyscallGroup
+	denied := map[string]bool{}
+	allowed := map[string]bool{}
+	conditional := map[string][]seccomp.ArgumentConditions{}
+	for _, rule := range profile.Syscalls {
+		names := slices.DeleteFunc(slices.Clone(rule.Names), func(name string) bool { return !inScope(name) })
+		conditions, ok := seccompConditions(rule.Args)
+		if !ok && len(names) > 0 {
+			vmmLog.WithField("syscalls", names).Warn("Unsupported argument comparison in seccomp profile")
+		}
+		group := seccomp.SyscallGroup{Action: seccompAction(rule.Action, rule.ErrnoRet)}
+		for _, name := range names {
+			switch {
+			case isAllowAction(rule.Action) && len(rule.Args) == 0:
+				allowed[name] = true
+			case isAllowAction(rule.Action) && ok:
+				conditional[name] = append(conditional[name], conditions)
+			case isAllowAction(rule.Action):
+				// Drop the allow rule
+			case len(rule.Args) == 0 || !ok:
+				denied[name] = true
+				if !slices.Contains(group.Names, name) {
+					group.Names = append(group.Names, name)
+				}
+			default:
+				group.NamesWithCondtions = append(group.NamesWithCondtions,
+					seccomp.NameWithConditions{Name: name, Conditions: conditions})
+			}
+		}
+		// Rules that deny system calls get checked before the allowed ones
+		if len(group.Names)+len(group.NamesWithCondtions) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	policy := seccomp.Policy{DefaultAction: seccompAction(profile.DefaultAction, profile.DefaultErrnoRet)}
+	allowGroup := seccomp.SyscallGroup{Action: seccomp.ActionAllow}
+	if isAllowAction(profile.DefaultAction) {
+		// Everything that the profile does not deny is allowed
+		for _, name := range baseline {
+			if inScope(name) && !denied[name] && !slices.Contains(allowGroup.Names, name) {
+				allowGroup.Names = append(allowGroup.Names, name)
+			}
+		}
+		if baseline != nil {
+			// The allowlist of the monitor denies everything else
+			policy.DefaultAction = seccomp.ActionTrap
+		}
+	} else {
+		for name := range allowed {
+			allowGroup.Names = append(allowGroup.Names, name)
+		}
+		for name, conditionSets := range conditional {
+			if allowed[name] {
+				continue
+			}
+			for _, conditions := range conditionSets {
+				allowGroup.NamesWithCondtions = append(allowGroup.NamesWithCondtions,
+					seccomp.NameWithConditions{Name: name, Conditions: conditions})
+			}
+		}
+		slices.Sort(allowGroup.Names)
+		slices.SortStableFunc(allowGroup.NamesWithCondtions, func(a, b seccomp.NameWithConditions) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+	if len(allowGroup.Names)+len(allowGroup.NamesWithCondtions) > 0 {
+		groups = append(groups, allowGroup)
+	}
+	policy.Syscalls = groups
+
+	var deniedBaseline []string
+	for _, name := range baseline {
+		if !inScope(name) || slices.Contains(deniedBaseline, name) {
+			continue
+		}
+		permitted := allowed[name] || len(conditional[name]) > 0
+		if isAllowAction(profile.DefaultAction) {
+			permitted = !denied[name]
+		}
+		if !permitted {
+			deniedBaseline = append(deniedBaseline, name)
+		}
+	}
+
+	return policy, deniedBaseline, nil
+}
+
+// logOnlyPolicy changes all the actions of a policy that deny system
+// calls to logging them.
+func logOnlyPolicy(policy seccomp.Policy) seccomp.Policy {
+	if policy.DefaultAction != seccomp.ActionAllow {
+		policy.DefaultAction = seccomp.ActionLog
+	}
+	groups := make([]seccomp.SyscallGroup, 0, len(policy.Syscalls))
+	for _, group := range policy.Syscalls {
+		if group.Action != seccomp.ActionAllow {
+			group.Action = seccomp.ActionLog
+		}
+		groups = append(groups, group)
+	}
+	policy.Syscalls = groups
+	return policy
+}
+
+// loadSeccompProfile compiles the seccomp profile of the container for
+// a monitor with the given baseline allowlist and loads it in the current
+// process. The filter is inherited by the monitor after execve. The
+// system calls of the baseline that the profile denies get logged, since
+// the monitor will fail when it uses them. In log mode, the filter logs
+// the system calls instead of denying them, while in learn mode, the
+// filter is not loaded at all. A supervised monitor inherits the filter
+// after fork and execve, hence the baseline also allows the system calls
+// that urunc needs to supervise it.
+func loadSeccompProfile(args types.ExecArgs, baseline []string) error {
+	if args.SeccompProfile == nil || args.SeccompMode == SeccompModeLearn {
+		return nil
+	}
+	policy, denied, err := compileSeccompProfile(args.SeccompProfile, seccompBaseline(args, baseline))
+	if err != nil {
+		return err
+	}
+	if len(denied) > 0 {
+		vmmLog.WithField("syscalls", denied).Warn("The seccomp profile denies system calls that the monitor requires")
+	}
+	if args.SeccompMode == SeccompModeLog {
+		policy = logOnlyPolicy(policy)
+	}
+	if len(policy.Syscalls) == 0 {
+		if policy.DefaultAction == seccomp.ActionAllow {
+			vmmLog.Debug("The seccomp profile allows all system calls")
+			return nil
+		}
+		// A policy needs at least one group of system calls
+		policy.Syscalls = []seccomp.SyscallGroup{{Names: []string{"exit_group"}, Action: seccomp.ActionAllow}}
+	}
+
+	filter := seccomp.Filter{
+		// Set the threads no_new_privs bit, disabling any new child or execve
+		// system call to grant privileges that the parent does not have.
+		NoNewPrivs: true,
+		// Sync the filter to all threads created by the Go runtime and
+		// let the kernel log the system calls that the filter denies.
+		Flag:   seccomp.FilterFlagTSync | seccomp.FilterFlagLog,
+		Policy: policy,
+	}
+
+	err = seccomp.LoadFilter(filter)
+	if err != nil {
+		vmmLog.Error("Could not load seccomp filters")
+		return err
+	}
+
+	vmmLog.Debug("Loaded seccomp filters")
+	vmmLog.WithField("policy", policy).Debug("Seccomp policy of the monitor")
+
+	return nil
+}
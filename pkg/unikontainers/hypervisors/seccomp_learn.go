@@ -0,0 +1,216 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"syscall"
+	"unsafe"
+
+	"github.com/elastic/go-seccomp-bpf/arch"
+	"golang.org/x/sys/unix"
+)
+
+// The layout of struct ptrace_syscall_info, up to the arguments of a
+// system call entry
+type ptraceSyscallInfo struct {
+	Op                 uint8
+	_                  [3]uint8
+	Arch               uint32
+	InstructionPointer uint64
+	StackPointer       uint64
+	Nr                 uint64
+	Args               [6]uint64
+}
+
+// syscallRecorder keeps the system calls that a monitor used and writes
+// them as an OCI seccomp profile in a file.
+type syscallRecorder struct {
+	info     *arch.Info
+	syscalls []string
+	out      *os.File
+}
+
+// record adds a system call to the profile and rewrites the profile, if
+// the system call is new. Rewriting the profile on each new system call
+// keeps it up to date, even if the current process gets killed.
+func (r *syscallRecorder) record(nr int) error {
+	name, ok := r.info.SyscallNumbers[nr]
+	if !ok || slices.Contains(r.syscalls, name) {
+		return nil
+	}
+	r.syscalls = append(r.syscalls, name)
+	slices.Sort(r.syscalls)
+	vmmLog.WithField("syscall", name).Debug("Recorded system call of the monitor")
+	return r.write()
+}
+
+func (r *syscallRecorder) write() error {
+	content, err := json.MarshalIndent(learnedProfile(r.syscalls), "", "  ")
+	if err != nil {
+		return err
+	}
+	err = r.out.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = r.out.WriteAt(content, 0)
+	return err
+}
+
+type learnedSyscalls struct {
+	Names  []string `json:"names"`
+	Action string   `json:"action"`
+}
+
+type learnedSeccompProfile struct {
+	DefaultAction string            `json:"defaultAction"`
+	Syscalls      []learnedSyscalls `json:"syscalls"`
+}
+
+// learnedProfile returns the OCI seccomp profile that allows only the
+// given system calls.
+func learnedProfile(syscalls []string) learnedSeccompProfile {
+	return learnedSeccompProfile{
+		DefaultAction: "SCMP_ACT_ERRNO",
+		Syscalls:      []learnedSyscalls{{Names: syscalls, Action: "SCMP_ACT_ALLOW"}},
+	}
+}
+
+// traceSyscalls resumes the traced monitor and its threads until the
+// monitor exits and records every system call that they enter. It returns
+// the wait status of the monitor.
+func traceSyscalls(pid int, r *syscallRecorder) (unix.WaitStatus, error) {
+	const syscallStop = unix.SIGTRAP | 0x80
+	// The threads and processes of the monitor that have stopped at least
+	// once. New ones start with a SIGSTOP that must not be delivered.
+	seen := map[int]bool{pid: true}
+	for {
+		var ws unix.WaitStatus
+		tid, err := unix.Wait4(-1, &ws, unix.WALL, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case (ws.Exited() || ws.Signaled()) && tid == pid:
+			return ws, nil
+		case !ws.Stopped():
+			delete(seen, tid)
+			continue
+		}
+
+		sig := ws.StopSignal()
+		switch {
+		case sig == syscallStop:
+			var info ptraceSyscallInfo
+			_, _, errno := unix.Syscall6(unix.SYS_PTRACE, unix.PTRACE_GET_SYSCALL_INFO, uintptr(tid),
+				unsafe.Sizeof(info), uintptr(unsafe.Pointer(&info)), 0, 0)
+			if errno == 0 && info.Op == unix.PTRACE_SYSCALL_INFO_ENTRY && info.Arch == uint32(r.info.ID) {
+				err = r.record(int(info.Nr)) //nolint: gosec
+				if err != nil {
+					vmmLog.WithError(err).Warn("Failed to write the recorded seccomp profile")
+				}
+			}
+			sig = 0
+		case sig == unix.SIGTRAP:
+			// Events of new threads, processes and execve
+			sig = 0
+		case sig == unix.SIGSTOP && !seen[tid]:
+			sig = 0
+		}
+		seen[tid] = true
+		// The thread might have been killed meanwhile
+		_ = unix.PtraceSyscall(tid, int(sig))
+	}
+}
+
+// RunLearning executes the monitor as a child of the current process and
+// records all the system calls that it uses in out, as an OCI seccomp
+// profile. No system call gets denied. The monitor gets traced with
+// ptrace and hence, it runs slower than usual. SIGTERM and SIGINT are
+// forwarded to the monitor, while the monitor gets killed if the current
+// process dies. Similarly to RunSupervised, it returns the wait status of
+// the monitor.
+func RunLearning(path string, argv []string, env []string, out *os.File) (unix.WaitStatus, error) {
+	info, err := arch.GetInfo("")
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	// The execve of the monitor is part of the profile, since the filter
+	// gets loaded before it
+	r := &syscallRecorder{info: info, syscalls: []string{"execve"}, out: out}
+	err = r.write()
+	if err != nil {
+		return 0, fmt.Errorf("failed to write the recorded seccomp profile: %w", err)
+	}
+
+	// All the ptrace requests must come from the thread that started
+	// the monitor.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cmd := exec.Command(path) //nolint: gosec
+	cmd.Args = argv
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Ptrace: true, Pdeathsig: unix.SIGKILL}
+
+	vmmLog.WithField("profile", out.Name()).Info("Recording the system calls of the monitor")
+	err = cmd.Start()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start the monitor: %w", err)
+	}
+	pid := cmd.Process.Pid
+
+	stopForwarding := forwardSignals(pid)
+	defer stopForwarding()
+
+	// The monitor stops right after execve
+	var ws unix.WaitStatus
+	_, err = unix.Wait4(pid, &ws, 0, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to wait for the monitor: %w", err)
+	}
+	err = unix.PtraceSetOptions(pid, unix.PTRACE_O_TRACESYSGOOD|unix.PTRACE_O_EXITKILL|
+		unix.PTRACE_O_TRACECLONE|unix.PTRACE_O_TRACEFORK|unix.PTRACE_O_TRACEVFORK|unix.PTRACE_O_TRACEEXEC)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return 0, fmt.Errorf("failed to trace the monitor: %w", err)
+	}
+	err = unix.PtraceSyscall(pid, 0)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return 0, fmt.Errorf("failed to trace the monitor: %w", err)
+	}
+
+	ws, err = traceSyscalls(pid, r)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return 0, fmt.Errorf("failed to trace the monitor: %w", err)
+	}
+	vmmLog.WithField("status", ws.ExitStatus()).Info("The monitor exited")
+	return ws, nil
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastic/go-seccomp-bpf/arch"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyscallRecorder(t *testing.T) {
+	t.Parallel()
+	info, err := arch.GetInfo("")
+	assert.NoError(t, err)
+	out, err := os.Create(filepath.Join(t.TempDir(), "profile.json"))
+	assert.NoError(t, err)
+	defer out.Close()
+
+	r := &syscallRecorder{info: info, syscalls: []string{"write"}, out: out}
+	assert.NoError(t, r.record(info.SyscallNames["read"]))
+	assert.NoError(t, r.record(info.SyscallNames["ioctl"]))
+	assert.NoError(t, r.record(info.SyscallNames["read"]))
+
+	content, err := os.ReadFile(out.Name())
+	assert.NoError(t, err)
+	var profile specs.LinuxSeccomp
+	assert.NoError(t, json.Unmarshal(content, &profile))
+	assert.Equal(t, specs.LinuxSeccomp{
+		DefaultAction: specs.ActErrno,
+		Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"ioctl", "read", "write"}, Action: specs.ActAllow},
+		},
+	}, profile)
+}
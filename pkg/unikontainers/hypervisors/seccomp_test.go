@@ -0,0 +1,224 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"runtime"
+	"testing"
+
+	seccomp "github.com/elastic/go-seccomp-bpf"
+	"github.com/elastic/go-seccomp-bpf/arch"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+func TestSeccompBaselines(t *testing.T) {
+	baselines := map[string][]string{
+		"hvt":              hvtSeccompSyscalls(),
+		"spt":              sptSeccompSyscalls(),
+		"qemu":             qemuSeccompSyscalls(),
+		"firecracker":      firecrackerSeccompSyscalls(),
+		"cloud-hypervisor": cloudHypervisorSeccompSyscalls(),
+		"kvmtool":          kvmtoolSeccompSyscalls(true),
+	}
+	amd64, err := arch.GetInfo("amd64")
+	assert.NoError(t, err)
+	arm64, err := arch.GetInfo("arm64")
+	assert.NoError(t, err)
+
+	// Every monitor stats files and the architectures name the system call
+	// differently
+	stat := "newfstatat"
+	if runtime.GOARCH == "arm64" {
+		stat = "fstatat"
+	}
+
+	for name, baseline := range baselines {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Contains(t, baseline, stat)
+			for _, syscall := range baseline {
+				_, onAmd64 := amd64.SyscallNames[syscall]
+				_, onArm64 := arm64.SyscallNames[syscall]
+				assert.True(t, onAmd64 || onArm64, "unknown system call %s", syscall)
+			}
+			policy, denied, err := compileSeccompProfile(&specs.LinuxSeccomp{DefaultAction: specs.ActAllow}, baseline)
+			assert.NoError(t, err)
+			assert.Empty(t, denied)
+			_, err = policy.Assemble()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCompileSeccompProfile(t *testing.T) {
+	t.Run("allowlist profile", func(t *testing.T) {
+		t.Parallel()
+		profile := &specs.LinuxSeccomp{
+			DefaultAction: specs.ActErrno,
+			Syscalls: []specs.LinuxSyscall{
+				{Names: []string{"write", "read", "openat", "mount", "not_a_syscall"}, Action: specs.ActAllow},
+			},
+		}
+		policy, denied, err := compileSeccompProfile(profile, []string{"read", "write", "openat", "ioctl"})
+		assert.NoError(t, err)
+		assert.Equal(t, seccomp.Policy{
+			DefaultAction: seccomp.ActionErrno,
+			Syscalls: []seccomp.SyscallGroup{
+				{Names: []string{"openat", "read", "write"}, Action: seccomp.ActionAllow},
+			},
+		}, policy)
+		assert.Equal(t, []string{"ioctl"}, denied)
+	})
+
+	t.Run("denylist profile", func(t *testing.T) {
+		t.Parallel()
+		errno := uint(38)
+		profile := &specs.LinuxSeccomp{
+			DefaultAction: specs.ActAllow,
+			Syscalls: []specs.LinuxSyscall{
+				{Names: []string{"ioctl", "mount"}, Action: specs.ActErrno, ErrnoRet: &errno},
+			},
+		}
+		policy, denied, err := compileSeccompProfile(profile, []string{"read", "ioctl"})
+		assert.NoError(t, err)
+		assert.Equal(t, seccomp.Policy{
+			DefaultAction: seccomp.ActionTrap,
+			Syscalls: []seccomp.SyscallGroup{
+				{Names: []string{"ioctl"}, Action: seccomp.ActionErrno | 38},
+				{Names: []string{"read"}, Action: seccomp.ActionAllow},
+			},
+		}, policy)
+		assert.Equal(t, []string{"ioctl"}, denied)
+	})
+
+	t.Run("argument comparisons", func(t *testing.T) {
+		t.Parallel()
+		profile := &specs.LinuxSeccomp{
+			DefaultAction: specs.ActErrno,
+			Syscalls: []specs.LinuxSyscall{
+				{
+					Names:  []string{"personality"},
+					Action: specs.ActAllow,
+					Args:   []specs.LinuxSeccompArg{{Index: 0, Value: 8, Op: specs.OpEqualTo}},
+				},
+				{
+					Names:  []string{"clone"},
+					Action: specs.ActAllow,
+					Args:   []specs.LinuxSeccompArg{{Index: 0, Value: 0x7e020000, Op: specs.OpMaskedEqual}},
+				},
+				{
+					Names:  []string{"ioctl"},
+					Action: specs.ActAllow,
+					Args:   []specs.LinuxSeccompArg{{Index: 1, Value: 0x3, ValueTwo: 0x1, Op: specs.OpMaskedEqual}},
+				},
+			},
+		}
+		policy, denied, err := compileSeccompProfile(profile, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, seccomp.Policy{
+			DefaultAction: seccomp.ActionErrno,
+			Syscalls: []seccomp.SyscallGroup{
+				{
+					NamesWithCondtions: []seccomp.NameWithConditions{
+						{Name: "clone", Conditions: seccomp.ArgumentConditions{{Argument: 0, Operation: seccomp.BitsNotSet, Value: 0x7e020000}}},
+						{Name: "personality", Conditions: seccomp.ArgumentConditions{{Argument: 0, Operation: seccomp.Equal, Value: 8}}},
+					},
+					Action: seccomp.ActionAllow,
+				},
+			},
+		}, policy)
+		assert.Empty(t, denied)
+		_, err = policy.Assemble()
+		assert.NoError(t, err)
+	})
+
+	t.Run("unrestricted profile", func(t *testing.T) {
+		t.Parallel()
+		policy, denied, err := compileSeccompProfile(&specs.LinuxSeccomp{DefaultAction: specs.ActAllow}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, seccomp.Policy{DefaultAction: seccomp.ActionAllow}, policy)
+		assert.Empty(t, denied)
+	})
+
+	t.Run("no profile", func(t *testing.T) {
+		t.Parallel()
+		assert.NoError(t, loadSeccompProfile(types.ExecArgs{}, hvtSeccompSyscalls()))
+	})
+}
+
+func TestSeccompModes(t *testing.T) {
+	t.Run("validation", func(t *testing.T) {
+		t.Parallel()
+		for _, mode := range []string{"", SeccompModeEnforce, SeccompModeLog, SeccompModeLearn} {
+			assert.NoError(t, ValidateSeccompMode(mode))
+		}
+		assert.ErrorIs(t, ValidateSeccompMode("audit"), ErrUnknownSeccompMode)
+	})
+
+	t.Run("log mode", func(t *testing.T) {
+		t.Parallel()
+		policy := seccomp.Policy{
+			DefaultAction: seccomp.ActionTrap,
+			Syscalls: []seccomp.SyscallGroup{
+				{Names: []string{"ioctl"}, Action: seccomp.ActionErrno | 38},
+				{Names: []string{"read"}, Action: seccomp.ActionAllow},
+			},
+		}
+		assert.Equal(t, seccomp.Policy{
+			DefaultAction: seccomp.ActionLog,
+			Syscalls: []seccomp.SyscallGroup{
+				{Names: []string{"ioctl"}, Action: seccomp.ActionLog},
+				{Names: []string{"read"}, Action: seccomp.ActionAllow},
+			},
+		}, logOnlyPolicy(policy))
+		assert.Equal(t, seccomp.ActionTrap, policy.DefaultAction)
+		assert.Equal(t, seccomp.ActionErrno|38, policy.Syscalls[0].Action)
+	})
+
+	t.Run("supervised hvt", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{
+			SeccompProfile: &specs.LinuxSeccomp{DefaultAction: specs.ActAllow},
+			Supervised:     true,
+		}
+		policy, denied, err := compileSeccompProfile(args.SeccompProfile, seccompBaseline(args, hvtSeccompSyscalls()))
+		assert.NoError(t, err)
+		assert.Empty(t, denied)
+		assert.Equal(t, seccomp.ActionTrap, policy.DefaultAction)
+		assert.Len(t, policy.Syscalls, 1)
+		for _, syscall := range []string{"clone", "clone3", "rt_sigprocmask", "execve", "fcntl", "wait4", "prctl"} {
+			assert.Contains(t, policy.Syscalls[0].Names, syscall)
+		}
+		_, err = policy.Assemble()
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupervised hvt", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{SeccompProfile: &specs.LinuxSeccomp{DefaultAction: specs.ActAllow}}
+		assert.Equal(t, hvtSeccompSyscalls(), seccompBaseline(args, hvtSeccompSyscalls()))
+	})
+
+	t.Run("learn mode loads no filter", func(t *testing.T) {
+		t.Parallel()
+		args := types.ExecArgs{
+			SeccompProfile: &specs.LinuxSeccomp{DefaultAction: specs.ActErrno},
+			SeccompMode:    SeccompModeLearn,
+		}
+		assert.NoError(t, loadSeccompProfile(args, hvtSeccompSyscalls()))
+	})
+}
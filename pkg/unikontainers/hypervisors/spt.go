@@ -16,7 +16,7 @@ package hypervisors
 
 import (
 	"os/exec"
-	"strings"
+	"runtime"
 
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
 )
@@ -36,14 +36,13 @@ func (s *SPT) Stop(pid int) error {
 	return killProcess(pid)
 }
 
-// UsesKVM returns a bool value depending on if the monitor uses KVM
-func (s *SPT) UsesKVM() bool {
-	return false
-}
-
-// SupportsSharedfs returns a bool value depending on the monitor support for shared-fs
-func (s *SPT) SupportsSharedfs(_ string) bool {
-	return false
+// Capabilities returns the features that Solo5-spt supports
+func (s *SPT) Capabilities() types.VMMCapabilities {
+	return types.VMMCapabilities{
+		Block:    true,
+		MultiNIC: true,
+		Archs:    commonArchs,
+	}
 }
 
 // Path returns the path to the spt binary.
@@ -60,25 +59,78 @@ func (s *SPT) Ok() error {
 }
 
 func (s *SPT) BuildExecCmd(args types.ExecArgs, ukernel types.Unikernel) ([]string, error) {
-	sptMem := BytesToStringMB(args.MemSizeB)
-	cmdString := s.binaryPath + " --mem=" + sptMem
-	if args.Net.TapDev != "" {
-		cmdString += " "
-		cmdString += ukernel.MonitorNetCli(args.Net.TapDev, args.Net.MAC)
+	cmd := newCmdBuilder(s.binaryPath)
+	cmd.add("--mem=" + BytesToStringMB(args.MemSizeB))
+	for _, nic := range args.Nets {
+		cmd.addFragment(ukernel.MonitorNetCli(nic.TapDev, nic.MAC))
 	}
-	bArgs := ukernel.MonitorBlockCli()
-	for _, blockArg := range bArgs {
-		cmdString = appendNonEmpty(cmdString, " --block:"+blockArg.ID+"=",
-			blockArg.Path)
+	for _, blockArg := range ukernel.MonitorBlockCli() {
+		if blockArg.Path != "" {
+			cmd.add("--block:" + blockArg.ID + "=" + blockArg.Path)
+		}
 	}
 	extraMonArgs := ukernel.MonitorCli()
-	cmdString = appendNonEmpty(cmdString, " ", extraMonArgs.OtherArgs)
-	cmdString += " " + args.UnikernelPath + " " + args.Command
-	cmdArgs := strings.Split(cmdString, " ")
-	return cmdArgs, nil
+	cmd.addFragment(extraMonArgs.OtherArgs)
+	cmd.add(args.UnikernelPath)
+	// Solo5 passes the rest of the arguments to the guest as its command line
+	if args.Command != "" {
+		cmd.add(args.Command)
+	}
+	return cmd.build(), nil
 }
 
-// PreExec performs pre-execution setup. SPT has no special pre-exec requirements.
-func (s *SPT) PreExec(_ types.ExecArgs) error {
-	return nil
+// sptSeccompSyscalls returns the system calls that the Spt process
+// requires. Along with the ones of the tender, the list contains the
+// system calls of the guest, since Spt stacks its own filter.
+func sptSeccompSyscalls() []string {
+	syscalls := []string{
+		"openat",
+		"open",
+		"ioctl",
+		"pread64",
+		"pwrite64",
+		"ppoll",
+		"poll",
+		"fstat",
+		"stat",
+		"lseek",
+		"brk",
+		"personality",
+		"arch_prctl",
+		"access",
+		"faccessat",
+		"readlink",
+		"readlinkat",
+		"getrandom",
+		"prctl",
+		"seccomp",
+		"uname",
+		"sysinfo",
+		"getuid",
+		"geteuid",
+		"getgid",
+		"getegid",
+		"clock_nanosleep",
+		"timerfd_create",
+		"timerfd_settime",
+		"epoll_create1",
+		"epoll_wait",
+		"socket",
+		"bind",
+		"getsockname",
+		"sendto",
+		"recvmsg",
+	}
+	// The architectures name the stat system call differently
+	if runtime.GOARCH == "arm64" {
+		syscalls = append(syscalls, "fstatat")
+	} else {
+		syscalls = append(syscalls, "newfstatat")
+	}
+	return append(syscalls, goRuntimeSyscalls...)
+}
+
+// PreExec applies the seccomp profile of the container to Spt.
+func (s *SPT) PreExec(args types.ExecArgs) error {
+	return loadSeccompProfile(args, sptSeccompSyscalls())
 }
@@ -0,0 +1,82 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// forwardSignals forwards SIGTERM and SIGINT of the current process to the
+// monitor, until the returned function gets called.
+func forwardSignals(pid int) func() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGTERM, unix.SIGINT)
+	go func() {
+		for sig := range sigs {
+			vmmLog.Debugf("forwarding %s to the monitor", sig)
+			_ = unix.Kill(pid, sig.(syscall.Signal))
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}
+}
+
+// RunSupervised executes the monitor as a child of the current process and
+// waits for it to exit. SIGTERM and SIGINT are forwarded to the monitor,
+// while the monitor gets killed if the current process dies. It returns
+// the wait status of the monitor.
+//
+// The monitor gets started with fork and execve directly, since the
+// seccomp filter of the monitor is already loaded and allows only the
+// system calls in goRuntimeSyscalls and supervisorSyscalls, on top of the
+// ones of the monitor.
+func RunSupervised(path string, argv []string, env []string) (unix.WaitStatus, error) {
+	// The parent death signal is delivered when the thread that
+	// started the monitor exits.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	pid, err := syscall.ForkExec(path, argv, &syscall.ProcAttr{
+		Env:   env,
+		Files: []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()},
+		Sys:   &syscall.SysProcAttr{Pdeathsig: unix.SIGKILL},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start the monitor: %w", err)
+	}
+	stopForwarding := forwardSignals(pid)
+	defer stopForwarding()
+
+	var ws unix.WaitStatus
+	for {
+		_, err = unix.Wait4(pid, &ws, 0, nil)
+		if !errors.Is(err, unix.EINTR) {
+			break
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to wait for the monitor: %w", err)
+	}
+	return ws, nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hypervisors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestRunSupervised(t *testing.T) {
+	t.Run("exit status", func(t *testing.T) {
+		t.Parallel()
+		ws, err := RunSupervised("/bin/sh", []string{"sh", "-c", "exit 7"}, nil)
+		assert.NoError(t, err)
+		assert.True(t, ws.Exited())
+		assert.Equal(t, 7, ws.ExitStatus())
+	})
+
+	t.Run("killed", func(t *testing.T) {
+		t.Parallel()
+		ws, err := RunSupervised("/bin/sh", []string{"sh", "-c", "kill -KILL $$"}, nil)
+		assert.NoError(t, err)
+		assert.True(t, ws.Signaled())
+		assert.Equal(t, unix.SIGKILL, ws.Signal())
+	})
+
+	t.Run("missing monitor", func(t *testing.T) {
+		t.Parallel()
+		_, err := RunSupervised("/nonexistent", []string{"nonexistent"}, nil)
+		assert.ErrorContains(t, err, "failed to start the monitor")
+	})
+}
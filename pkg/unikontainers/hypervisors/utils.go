@@ -17,6 +17,9 @@ package hypervisors
 import (
 	"errors"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"syscall"
@@ -36,13 +39,6 @@ func cpuArch() string {
 	}
 }
 
-func appendNonEmpty(body, prefix, value string) string {
-	if value != "" {
-		return body + prefix + value
-	}
-	return body
-}
-
 func bytesToMiB(bytes uint64) uint64 {
 	const bytesInMiB = 1024 * 1024
 	return bytes / bytesInMiB
@@ -67,6 +63,36 @@ func BytesToStringMB(argMem uint64) string {
 	return stringMem
 }
 
+// hugepageSizeString returns the size of a hugepage in the format that
+// the monitors expect (e.g. 2M or 1G).
+func hugepageSizeString(size uint64) string {
+	for _, unit := range []struct {
+		suffix string
+		bytes  uint64
+	}{
+		{"G", 1024 * 1024 * 1024},
+		{"M", 1024 * 1024},
+		{"K", 1024},
+	} {
+		if size%unit.bytes == 0 {
+			return strconv.FormatUint(size/unit.bytes, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatUint(size, 10)
+}
+
+// hugepageMemMiB returns the memory of the guest in MiB, rounded down to
+// a multiple of the hugepage size, since the guest memory must consist of
+// whole hugepages.
+func hugepageMemMiB(memSizeB uint64, pageSize uint64) (uint64, error) {
+	mem := bytesToMiB(memSizeB - memSizeB%pageSize)
+	if mem == 0 {
+		return 0, fmt.Errorf("%w: the memory of the guest (%d bytes) is smaller than a hugepage of %s",
+			ErrHugepageSize, memSizeB, hugepageSizeString(pageSize))
+	}
+	return mem, nil
+}
+
 func killProcess(pid int) error {
 	const timeout = 2 * time.Second
 	err := syscall.Kill(pid, unix.SIGKILL)
@@ -90,3 +116,38 @@ func killProcess(pid int) error {
 
 	return nil
 }
+
+// waitProcessExit waits until the process with the given pid exits or
+// the timeout expires. It returns true if the process exited.
+func waitProcessExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// The maximum length of a unix socket path, including the NULL terminator
+const maxUnixPathLen = len(unix.RawSockaddrUnix{}.Path)
+
+// dialUnix connects to the unix socket at path. Since the control sockets
+// of the monitors reside in the bundle, their path can exceed the limit of
+// the sun_path. In that case, we connect through the /proc/self/fd entry
+// of the socket's parent directory.
+func dialUnix(path string, timeout time.Duration) (net.Conn, error) {
+	if len(path) < maxUnixPathLen {
+		return net.DialTimeout("unix", path, timeout)
+	}
+	dir, err := os.OpenFile(filepath.Dir(path), unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	shortPath := fmt.Sprintf("/proc/self/fd/%d/%s", dir.Fd(), filepath.Base(path))
+	return net.DialTimeout("unix", shortPath, timeout)
+}
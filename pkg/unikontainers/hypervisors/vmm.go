@@ -25,9 +25,25 @@ import (
 
 const DefaultMemory uint64 = 256 // The default memory for every hypervisor: 256 MB
 
+// MonitorSocketName is the name of the control socket (e.g. QMP for Qemu)
+// of the monitors that support one. The socket gets created in the root
+// directory of the monitor's rootfs.
+const MonitorSocketName = "monitor.sock"
+
+// HugepagesMountPath is where the hugetlbfs that backs the memory of the
+// guest gets mounted in the monitor's rootfs, when the container requests
+// hugepages.
+const HugepagesMountPath = "/dev/hugepages"
+
+var ErrHugepageSize = errors.New("unsupported hugepage size")
+
 type VmmType string
 
+// commonArchs are the architectures that most monitors support
+var commonArchs = []string{"amd64", "arm64"}
+
 var ErrVMMNotInstalled = errors.New("vmm not found")
+var ErrMultipleInitrd = errors.New("monitor supports a single initrd")
 var vmmLog = logrus.WithField("subsystem", "monitors")
 
 type VMMFactory struct {
@@ -66,6 +82,18 @@ var vmmFactories = map[VmmType]VMMFactory{
 			return &CloudHypervisor{binary: binary, binaryPath: binaryPath}
 		},
 	},
+	CrosvmVmm: {
+		binary: CrosvmBinary,
+		createFunc: func(binary, binaryPath string, _ bool) types.VMM {
+			return &Crosvm{binary: binary, binaryPath: binaryPath}
+		},
+	},
+	KvmtoolVmm: {
+		binary: KvmtoolBinary,
+		createFunc: func(binary, binaryPath string, _ bool) types.VMM {
+			return &Kvmtool{binary: binary, binaryPath: binaryPath}
+		},
+	},
 }
 
 func NewVMM(vmmType VmmType, monitors map[string]types.MonitorConfig) (vmm types.VMM, err error) {
@@ -77,15 +105,23 @@ func NewVMM(vmmType VmmType, monitors map[string]types.MonitorConfig) (vmm types
 
 	// Handle Hedge separately since it is not in vmmFactories
 	if vmmType == HedgeVmm {
-		hedge := Hedge{}
+		hedge := NewHedge()
 		if err := hedge.Ok(); err != nil {
 			return nil, ErrVMMNotInstalled
 		}
-		return &hedge, nil
+		return hedge, nil
 	}
 
 	factory, exists := vmmFactories[vmmType]
 	if !exists {
+		// Monitors declared in the config of urunc
+		if cfg := monitors[string(vmmType)]; IsGeneric(cfg) {
+			vmmPath, err := getVMMPath(vmmType, string(vmmType), monitors)
+			if err != nil {
+				return nil, err
+			}
+			return newGeneric(string(vmmType), vmmPath, cfg)
+		}
 		return nil, fmt.Errorf("vmm \"%s\" is not supported", vmmType)
 	}
 
@@ -94,7 +130,38 @@ func NewVMM(vmmType VmmType, monitors map[string]types.MonitorConfig) (vmm types
 		return nil, err
 	}
 
-	return factory.createFunc(factory.binary, vmmPath, monitors[string(vmmType)].Vhost), nil
+	vmm = factory.createFunc(factory.binary, vmmPath, monitors[string(vmmType)].Vhost)
+	// Firecracker can optionally get configured through its API socket
+	if fc, ok := vmm.(*Firecracker); ok && monitors[string(vmmType)].APISocket {
+		return &FirecrackerAPI{Firecracker: fc}, nil
+	}
+	return vmm, nil
+}
+
+// Installed returns true if the binary of a monitor that urunc supports
+// can be found, either from the config of urunc or in the PATH.
+func Installed(vmmType VmmType, monitors map[string]types.MonitorConfig) bool {
+	factory, exists := vmmFactories[vmmType]
+	if !exists {
+		return false
+	}
+	_, err := getVMMPath(vmmType, factory.binary, monitors)
+	return err == nil
+}
+
+// HasMonitorSocket returns true if the monitor exposes a control socket
+// with the given configuration
+func HasMonitorSocket(vmmType VmmType, cfg types.MonitorConfig) bool {
+	switch vmmType {
+	case QemuVmm:
+		return true
+	case CloudHypervisorVmm:
+		return true
+	case FirecrackerVmm:
+		return cfg.APISocket
+	default:
+		return false
+	}
 }
 
 func getVMMPath(vmmType VmmType, binary string, monitors map[string]types.MonitorConfig) (string, error) {
@@ -15,9 +15,11 @@
 package hypervisors
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
 )
 
 func TestVMMFactoryQemuVhostFalse(t *testing.T) {
@@ -69,3 +71,78 @@ func TestVMMFactoryNonQemuIgnoresVhost(t *testing.T) {
 	_, ok = vmm.(*Firecracker)
 	assert.True(t, ok, "factory should return *Firecracker")
 }
+
+func TestHugepages(t *testing.T) {
+	const MiB = 1024 * 1024
+	// 999 MiB in hugepages of 2M get rounded down to 998 MiB
+	args := types.ExecArgs{UnikernelPath: "/unikernel/app", MemSizeB: 999 * MiB, HugepageSize: 2 * MiB}
+
+	t.Run("qemu", func(t *testing.T) {
+		t.Parallel()
+		q := &Qemu{binaryPath: "/usr/bin/qemu-system-x86_64"}
+		cmd, err := q.BuildExecCmd(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		cmdline := strings.Join(cmd, " ")
+		assert.Contains(t, cmdline, "-m 998M")
+		assert.Contains(t, cmdline, "-object memory-backend-file,id=mem,size=998M,mem-path=/dev/hugepages,prealloc=on -numa node,memdev=mem")
+
+		shared := args
+		shared.Sharedfs = types.SharedfsParams{Type: "virtiofs", Path: "/cntrRootfs"}
+		cmd, err = q.BuildExecCmd(shared, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Contains(t, strings.Join(cmd, " "), "mem-path=/dev/hugepages,prealloc=on,share=on")
+	})
+
+	t.Run("cloud-hypervisor", func(t *testing.T) {
+		t.Parallel()
+		ch := &CloudHypervisor{binaryPath: "/usr/bin/cloud-hypervisor"}
+		gbArgs := args
+		gbArgs.MemSizeB = 2048 * MiB
+		gbArgs.HugepageSize = 1024 * MiB
+		cmd, err := ch.BuildExecCmd(gbArgs, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Contains(t, strings.Join(cmd, " "), "--memory size=2048M,hugepages=on,hugepage_size=1G")
+	})
+
+	t.Run("firecracker", func(t *testing.T) {
+		t.Parallel()
+		fc := &Firecracker{binaryPath: "/usr/bin/firecracker"}
+		machine := fc.vmConfig(args, &fakeUnikernel{}).Machine
+		assert.Equal(t, uint64(998), machine.MemSizeMiB)
+		assert.Equal(t, "2M", machine.HugePages)
+
+		gbArgs := args
+		gbArgs.HugepageSize = 1024 * MiB
+		_, err := fc.BuildExecCmd(gbArgs, &fakeUnikernel{})
+		assert.ErrorIs(t, err, ErrHugepageSize)
+	})
+
+	t.Run("memory smaller than a hugepage", func(t *testing.T) {
+		t.Parallel()
+		small := args
+		small.MemSizeB = MiB
+		_, err := (&Qemu{}).BuildExecCmd(small, &fakeUnikernel{})
+		assert.ErrorIs(t, err, ErrHugepageSize)
+	})
+}
+
+func TestCloudHypervisorPanicEvents(t *testing.T) {
+	ch := &CloudHypervisor{binaryPath: "/usr/bin/cloud-hypervisor"}
+	args := types.ExecArgs{UnikernelPath: "/unikernel/app", MemSizeB: 256 * 1024 * 1024}
+
+	t.Run("without event fd", func(t *testing.T) {
+		t.Parallel()
+		cmd, err := ch.BuildExecCmd(args, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.NotContains(t, cmd, "--pvpanic")
+	})
+
+	t.Run("with event fd", func(t *testing.T) {
+		t.Parallel()
+		eventArgs := args
+		eventArgs.EventFd = 7
+		cmd, err := ch.BuildExecCmd(eventArgs, &fakeUnikernel{})
+		assert.NoError(t, err)
+		assert.Contains(t, strings.Join(cmd, " "), "--pvpanic --event-monitor fd=7")
+	})
+}
@@ -21,9 +21,12 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
 type IPCMessage string
@@ -38,11 +41,16 @@ const (
 	StartExecve   IPCMessage = "UC_START"
 	StartSuccess  IPCMessage = "RX_SUCCESS"
 	StartErr      IPCMessage = "RX_ERROR"
-	maxRetries               = 50
 	waitTime                 = 5 * time.Millisecond
 	FromReexec               = true
+	// How long we keep accepting connections after the peer process exited,
+	// in order to drain any message it managed to send right before exiting.
+	peerExitGrace = 100 * time.Millisecond
 )
 
+var ErrIPCTimeout = errors.New("timed out waiting for IPC peer")
+var ErrPeerExited = errors.New("IPC peer process exited")
+
 func getSockAddr(dir string, name string) string {
 	return filepath.Join(dir, name)
 }
@@ -93,39 +101,20 @@ func SendIPCMessage(socketAddress string, message IPCMessage) error {
 	return nil
 }
 
-// sendIPCMessageWithRetry attempts to connect to socketAddress. if successful, sends the message and closes the connection
-func sendIPCMessageWithRetry(socketAddress string, message IPCMessage, mustBeValid bool) error {
-	if mustBeValid {
-		err := ensureValidSockAddr(socketAddress)
-		if err != nil {
-			return err
-		}
-	}
-	var conn *net.UnixConn
-	var err error
-	retry := 0
+// dialWithRetry attempts to connect to socketAddress until it succeeds or
+// the timeout expires. A zero timeout results in a single attempt.
+func dialWithRetry(socketAddress string, timeout time.Duration) (*net.UnixConn, error) {
+	deadline := time.Now().Add(timeout)
 	for {
-		conn, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: socketAddress, Net: "unix"})
+		conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socketAddress, Net: "unix"})
 		if err == nil {
-			break
+			return conn, nil
 		}
-		retry++
-		if retry >= maxRetries {
-			return fmt.Errorf("failed to connect to %s, exceeded max retries", socketAddress)
+		if time.Now().Add(waitTime).After(deadline) {
+			return nil, fmt.Errorf("failed to connect to %s within %s: %w", socketAddress, timeout, errors.Join(ErrIPCTimeout, err))
 		}
 		time.Sleep(waitTime)
 	}
-	defer func() {
-		err = conn.Close()
-		if err != nil {
-			logrus.WithError(err).Error("failed to close connection")
-		}
-	}()
-	_, err = conn.Write([]byte(message))
-	if err != nil {
-		logrus.WithError(err).Errorf("failed to send message \"%s\" to \"%s\"", message, socketAddress)
-	}
-	return err
 }
 
 // createListener sets up a listener for new connection to socketAddress
@@ -145,23 +134,74 @@ func createListener(socketAddress string, mustBeValid bool) (*net.UnixListener,
 	return listener, nil
 }
 
-// awaitMessage opens a new connection to socketAddress
-// and waits for a given message
-func AwaitMessage(listener *net.UnixListener, expectedMessage IPCMessage) error {
-	conn, err := listener.AcceptUnix()
+// AwaitMessage waits for a new connection in listener and checks that
+// the first message received is expectedMessage.
+// A zero timeout waits forever. If peerPid is positive, the wait is
+// aborted with ErrPeerExited as soon as the peer process exits, so callers
+// do not hang on a counterpart that will never send anything.
+func AwaitMessage(listener *net.UnixListener, expectedMessage IPCMessage, timeout time.Duration, peerPid int) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	err := listener.SetDeadline(deadline)
 	if err != nil {
+		return fmt.Errorf("failed to set listener deadline: %w", err)
+	}
+
+	peerExited := make(chan struct{})
+	stopWatch := make(chan struct{})
+	defer close(stopWatch)
+	if peerPid > 0 {
+		go func() {
+			if !waitPeerExit(peerPid, stopWatch) {
+				return
+			}
+			close(peerExited)
+			// Do not stop immediately. The peer might have sent
+			// a message right before exiting.
+			_ = listener.SetDeadline(time.Now().Add(peerExitGrace))
+		}()
+	}
+	awaitErr := func(err error) error {
+		select {
+		case <-peerExited:
+			return fmt.Errorf("process %d: %w", peerPid, ErrPeerExited)
+		default:
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("no message received within %s: %w", timeout, ErrIPCTimeout)
+		}
 		return err
 	}
+
+	conn, err := listener.AcceptUnix()
+	if err != nil {
+		return awaitErr(err)
+	}
 	defer func() {
 		err = conn.Close()
 		if err != nil {
 			logrus.WithError(err).Error("failed to close connection")
 		}
 	}()
+	err = conn.SetReadDeadline(deadline)
+	if err != nil {
+		return fmt.Errorf("failed to set read deadline: %w", err)
+	}
+	if peerPid > 0 {
+		go func() {
+			select {
+			case <-peerExited:
+				_ = conn.SetReadDeadline(time.Now().Add(peerExitGrace))
+			case <-stopWatch:
+			}
+		}()
+	}
 	buf := make([]byte, len(expectedMessage))
 	n, err := conn.Read(buf)
 	if err != nil {
-		return fmt.Errorf("failed to read from socket: %w", err)
+		return fmt.Errorf("failed to read from socket: %w", awaitErr(err))
 	}
 	msg := string(buf[0:n])
 	if msg != string(expectedMessage) {
@@ -169,3 +209,67 @@ func AwaitMessage(listener *net.UnixListener, expectedMessage IPCMessage) error
 	}
 	return nil
 }
+
+// waitPeerExit blocks until the process with the given pid exits or stop
+// gets closed. It returns true only if the process exited.
+// A pidfd is used when available. Otherwise we fall back to polling procfs,
+// since a simple kill(pid, 0) succeeds for zombie processes too.
+func waitPeerExit(pid int, stop <-chan struct{}) bool {
+	pidfd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return true
+		}
+		return pollPeerExit(pid, stop)
+	}
+	defer unix.Close(pidfd)
+
+	pollFds := []unix.PollFd{{Fd: int32(pidfd), Events: unix.POLLIN}} //nolint: gosec
+	for {
+		// Use a short poll timeout so that we notice when we get stopped.
+		n, err := unix.Poll(pollFds, int(peerExitGrace.Milliseconds()))
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			uniklog.WithError(err).Warnf("failed to poll pidfd of process %d", pid)
+			return pollPeerExit(pid, stop)
+		}
+		if n > 0 {
+			return true
+		}
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+	}
+}
+
+func pollPeerExit(pid int, stop <-chan struct{}) bool {
+	ticker := time.NewTicker(peerExitGrace)
+	defer ticker.Stop()
+	for {
+		if !processAlive(pid) {
+			return true
+		}
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// processAlive returns false if the process does not exist or is a zombie
+func processAlive(pid int) bool {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	// The state follows the command name, which is enclosed in parentheses
+	// and might contain spaces.
+	idx := strings.LastIndexByte(string(data), ')')
+	if idx < 0 || idx+2 >= len(data) {
+		return true
+	}
+	state := data[idx+2]
+	return state != 'Z' && state != 'X'
+}
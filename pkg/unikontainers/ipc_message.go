@@ -87,3 +87,26 @@ func (msg *bytemsg) Serialize() []byte {
 func (msg *bytemsg) Len() int {
 	return unix.NLA_HDRLEN + len(msg.Value) + 1 // null-terminated
 }
+
+// boolmsg has the following representation
+// | nlattr len | nlattr type |
+// | uint8 value | pad        |
+type boolmsg struct {
+	Type  uint16
+	Value bool
+}
+
+func (msg *boolmsg) Serialize() []byte {
+	buf := make([]byte, msg.Len())
+	native := nl.NativeEndian()
+	native.PutUint16(buf[0:2], uint16(msg.Len())) //nolint: gosec
+	native.PutUint16(buf[2:4], msg.Type)
+	if msg.Value {
+		buf[4] = 1
+	}
+	return buf
+}
+
+func (msg *boolmsg) Len() int {
+	return unix.NLA_HDRLEN + 1 // uint8 value
+}
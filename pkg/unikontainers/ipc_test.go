@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 	"time"
@@ -126,18 +127,6 @@ func TestSendIPCMessage(t *testing.T) {
 	testSendIPCMessageHelper(t, socketAddress, message, SendIPCMessage)
 }
 
-func TestSendIPCMessageWithRetry(t *testing.T) {
-	socketAddress := "/tmp/test_retry.sock"
-	message := ReexecStarted
-
-	// Wrapping the sendIPCMessageWithRetry function to match the expected function signature.
-	sendWithRetry := func(addr string, msg IPCMessage) error {
-		return sendIPCMessageWithRetry(addr, msg, true)
-	}
-
-	testSendIPCMessageHelper(t, socketAddress, message, sendWithRetry)
-}
-
 func TestCreateListener(t *testing.T) {
 	socketAddress := "/tmp/test_create_listener.sock"
 
@@ -173,6 +162,123 @@ func TestAwaitMessage(t *testing.T) {
 		}
 	}()
 
-	err = AwaitMessage(listener, expectedMessage)
+	err = AwaitMessage(listener, expectedMessage, 0, 0)
 	assert.NoError(t, err, "Expected no error in awaiting message")
 }
+
+func TestDialWithRetryLateListener(t *testing.T) {
+	socketAddress := "/tmp/test_retry_late.sock"
+	message := StartExecve
+
+	errChan := make(chan error, 1)
+	go func() {
+		conn, err := dialWithRetry(socketAddress, 2*time.Second)
+		if err == nil {
+			defer conn.Close()
+			_, err = conn.Write([]byte(message))
+		}
+		errChan <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	listener, err := createListener(socketAddress, true)
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	defer listener.Close()
+
+	err = AwaitMessage(listener, message, 2*time.Second, 0)
+	assert.NoError(t, err, "Expected to receive the message from a delayed listener")
+	assert.NoError(t, <-errChan, "Expected no error in sending IPC message")
+}
+
+func TestDialWithRetryTimeout(t *testing.T) {
+	socketAddress := "/tmp/test_retry_timeout.sock"
+
+	start := time.Now()
+	_, err := dialWithRetry(socketAddress, 50*time.Millisecond)
+	assert.ErrorIs(t, err, ErrIPCTimeout)
+	assert.Less(t, time.Since(start), time.Second, "Expected retries to stop at the deadline")
+
+	// A zero timeout makes a single attempt
+	start = time.Now()
+	_, err = dialWithRetry(socketAddress, 0)
+	assert.ErrorIs(t, err, ErrIPCTimeout)
+	assert.Less(t, time.Since(start), waitTime, "Expected a single attempt")
+}
+
+func TestAwaitMessageTimeout(t *testing.T) {
+	socketAddress := "/tmp/test_await_timeout.sock"
+
+	listener, err := createListener(socketAddress, true)
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	defer listener.Close()
+
+	t.Run("no connection", func(t *testing.T) {
+		err := AwaitMessage(listener, StartSuccess, 50*time.Millisecond, 0)
+		assert.ErrorIs(t, err, ErrIPCTimeout)
+	})
+
+	t.Run("connection without message", func(t *testing.T) {
+		conn, err := net.Dial("unix", socketAddress)
+		if err != nil {
+			t.Fatalf("Failed to dial connection: %v", err)
+		}
+		defer conn.Close()
+
+		err = AwaitMessage(listener, StartSuccess, 50*time.Millisecond, 0)
+		assert.ErrorIs(t, err, ErrIPCTimeout)
+	})
+}
+
+func TestAwaitMessagePeerExited(t *testing.T) {
+	socketAddress := "/tmp/test_await_peer.sock"
+
+	listener, err := createListener(socketAddress, true)
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	defer listener.Close()
+
+	t.Run("peer already dead", func(t *testing.T) {
+		cmd := exec.Command("true")
+		if err := cmd.Run(); err != nil {
+			t.Skipf("could not run helper process: %v", err)
+		}
+		err := AwaitMessage(listener, StartSuccess, 10*time.Second, cmd.Process.Pid)
+		assert.ErrorIs(t, err, ErrPeerExited)
+	})
+
+	t.Run("peer dies while waiting", func(t *testing.T) {
+		cmd := exec.Command("sleep", "0.2")
+		if err := cmd.Start(); err != nil {
+			t.Skipf("could not start helper process: %v", err)
+		}
+		// Do not reap the process, to make sure zombies are treated as dead.
+		defer func() { _ = cmd.Wait() }()
+
+		start := time.Now()
+		err := AwaitMessage(listener, StartSuccess, 10*time.Second, cmd.Process.Pid)
+		assert.ErrorIs(t, err, ErrPeerExited)
+		assert.Less(t, time.Since(start), 5*time.Second, "Expected peer exit to be detected early")
+	})
+
+	t.Run("message sent before peer exit", func(t *testing.T) {
+		conn, err := net.Dial("unix", socketAddress)
+		if err != nil {
+			t.Fatalf("Failed to dial connection: %v", err)
+		}
+		_, err = conn.Write([]byte(StartErr))
+		assert.NoError(t, err)
+		conn.Close()
+
+		cmd := exec.Command("true")
+		if err := cmd.Run(); err != nil {
+			t.Skipf("could not run helper process: %v", err)
+		}
+		err = AwaitMessage(listener, StartErr, 10*time.Second, cmd.Process.Pid)
+		assert.NoError(t, err, "Expected pending message to be received")
+	})
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// State annotation with the arguments of the monitor's execution,
+	// encoded as a JSON array
+	annotMonitorCmd = "urunc_state.monitor_cmd"
+	// The file where reexec writes the arguments of the monitor's
+	// execution, since reexec can not update the state
+	monitorCmdFilename = "monitor_cmd.json"
+)
+
+// openMonitorCmdFile creates the file where reexec writes the arguments
+// of the monitor's execution. It must be called before reexec changes
+// its root, since the file resides in the container's state directory.
+func (u *Unikontainer) openMonitorCmdFile() (*os.File, error) {
+	return os.OpenFile(filepath.Join(u.BaseDir, monitorCmdFilename), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644) //nolint: gosec
+}
+
+// writeMonitorCmd writes the arguments of the monitor's execution in
+// the given file and closes it.
+func writeMonitorCmd(f *os.File, execCmd []string) error {
+	err := json.NewEncoder(f).Encode(execCmd)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordMonitorCmd stores the arguments of the monitor's execution that
+// reexec wrote in the state directory, in the state annotations.
+func (u *Unikontainer) recordMonitorCmd() {
+	content, err := os.ReadFile(filepath.Join(u.BaseDir, monitorCmdFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		uniklog.WithError(err).Warn("failed to read the command of the monitor")
+		return
+	}
+	u.State.Annotations[annotMonitorCmd] = strings.TrimSpace(string(content))
+}
+
+// MonitorCmd returns the arguments of the monitor's execution, or nil
+// if they were not recorded.
+func (u *Unikontainer) MonitorCmd() []string {
+	var execCmd []string
+	err := json.Unmarshal([]byte(u.State.Annotations[annotMonitorCmd]), &execCmd)
+	if err != nil {
+		return nil
+	}
+	return execCmd
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+)
+
+func TestRecordMonitorCmd(t *testing.T) {
+	t.Run("command written by reexec", func(t *testing.T) {
+		t.Parallel()
+		u := newMonitorSocketUnikontainer(t.TempDir(), string(hypervisors.QemuVmm))
+		u.BaseDir = t.TempDir()
+		execCmd := []string{"/usr/bin/qemu-system-x86_64", "-kernel", "/my images/app,v2", "-append", "console=ttyS0 quiet"}
+
+		f, err := u.openMonitorCmdFile()
+		assert.NoError(t, err)
+		assert.NoError(t, writeMonitorCmd(f, execCmd))
+
+		u.recordMonitorCmd()
+		assert.Equal(t, `["/usr/bin/qemu-system-x86_64","-kernel","/my images/app,v2","-append","console=ttyS0 quiet"]`,
+			u.State.Annotations[annotMonitorCmd])
+		assert.Equal(t, execCmd, u.MonitorCmd())
+	})
+
+	t.Run("command not written", func(t *testing.T) {
+		t.Parallel()
+		u := newMonitorSocketUnikontainer(t.TempDir(), string(hypervisors.QemuVmm))
+		u.BaseDir = t.TempDir()
+		u.recordMonitorCmd()
+		assert.NotContains(t, u.State.Annotations, annotMonitorCmd)
+		assert.Nil(t, u.MonitorCmd())
+	})
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"path/filepath"
+
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+)
+
+// State annotation with the path in the host of the monitor's control
+// socket (e.g. QMP for Qemu)
+const annotMonitorSocket = "urunc_state.monitor_socket"
+
+// hostMonRootfs returns the path in the host of the monitor's rootfs.
+// If urunc created a new rootfs for the monitor, it resides under the
+// bundle. Otherwise, the monitor executes in the container's rootfs.
+func (u *Unikontainer) hostMonRootfs() string {
+	bundleDir := filepath.Clean(u.State.Bundle)
+	monRootfs := filepath.Join(bundleDir, monitorRootfsDirName)
+	if !isEmptyDir(monRootfs) {
+		return monRootfs
+	}
+	rootfsDir := filepath.Clean(u.Spec.Root.Path)
+	if !filepath.IsAbs(rootfsDir) {
+		rootfsDir = filepath.Join(bundleDir, rootfsDir)
+	}
+	return rootfsDir
+}
+
+// recordMonitorSocket stores the path of the monitor's control socket
+// in the state annotations. It must be called after reexec has prepared
+// the monitor's rootfs.
+func (u *Unikontainer) recordMonitorSocket() {
+	vmmType := u.State.Annotations[annotHypervisor]
+	if !hypervisors.HasMonitorSocket(hypervisors.VmmType(vmmType), u.UruncCfg.Monitors[vmmType]) {
+		return
+	}
+	u.State.Annotations[annotMonitorSocket] = filepath.Join(u.hostMonRootfs(), hypervisors.MonitorSocketName)
+}
+
+// MonitorSocket returns the path in the host of the monitor's control
+// socket, or an empty string if the monitor does not expose one.
+func (u *Unikontainer) MonitorSocket() string {
+	return u.State.Annotations[annotMonitorSocket]
+}
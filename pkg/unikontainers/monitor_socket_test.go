@@ -0,0 +1,85 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+)
+
+func newMonitorSocketUnikontainer(bundle string, hypervisor string) *Unikontainer {
+	return &Unikontainer{
+		Spec: &specs.Spec{Root: &specs.Root{Path: "rootfs"}},
+		State: &specs.State{
+			Bundle:      bundle,
+			Annotations: map[string]string{annotHypervisor: hypervisor},
+		},
+		UruncCfg: &UruncConfig{Monitors: defaultMonitorsConfig()},
+	}
+}
+
+func TestRecordMonitorSocket(t *testing.T) {
+	t.Run("monitor executes in the container's rootfs", func(t *testing.T) {
+		t.Parallel()
+		bundle := t.TempDir()
+		u := newMonitorSocketUnikontainer(bundle, string(hypervisors.QemuVmm))
+		u.recordMonitorSocket()
+		assert.Equal(t, filepath.Join(bundle, "rootfs", hypervisors.MonitorSocketName), u.MonitorSocket())
+	})
+
+	t.Run("monitor executes in a new rootfs", func(t *testing.T) {
+		t.Parallel()
+		bundle := t.TempDir()
+		monRootfs := filepath.Join(bundle, monitorRootfsDirName)
+		assert.NoError(t, os.MkdirAll(filepath.Join(monRootfs, "dev"), 0o755))
+		u := newMonitorSocketUnikontainer(bundle, string(hypervisors.QemuVmm))
+		u.recordMonitorSocket()
+		assert.Equal(t, filepath.Join(monRootfs, hypervisors.MonitorSocketName), u.MonitorSocket())
+	})
+
+	t.Run("cloud hypervisor api socket", func(t *testing.T) {
+		t.Parallel()
+		bundle := t.TempDir()
+		u := newMonitorSocketUnikontainer(bundle, string(hypervisors.CloudHypervisorVmm))
+		u.recordMonitorSocket()
+		assert.Equal(t, filepath.Join(bundle, "rootfs", hypervisors.MonitorSocketName), u.MonitorSocket())
+	})
+
+	t.Run("firecracker in api socket mode", func(t *testing.T) {
+		t.Parallel()
+		bundle := t.TempDir()
+		u := newMonitorSocketUnikontainer(bundle, string(hypervisors.FirecrackerVmm))
+		u.recordMonitorSocket()
+		assert.Empty(t, u.MonitorSocket())
+
+		fcCfg := u.UruncCfg.Monitors["firecracker"]
+		fcCfg.APISocket = true
+		u.UruncCfg.Monitors["firecracker"] = fcCfg
+		u.recordMonitorSocket()
+		assert.Equal(t, filepath.Join(bundle, "rootfs", hypervisors.MonitorSocketName), u.MonitorSocket())
+	})
+
+	t.Run("monitor without control socket", func(t *testing.T) {
+		t.Parallel()
+		u := newMonitorSocketUnikontainer(t.TempDir(), string(hypervisors.HvtVmm))
+		u.recordMonitorSocket()
+		assert.Empty(t, u.MonitorSocket())
+	})
+}
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/moby/sys/userns"
 	"golang.org/x/sys/unix"
@@ -73,6 +74,32 @@ func createTmpfs(monRootfs string, path string, flags uint64, mode string, size
 	return nil
 }
 
+// createHugetlbfs creates a new hugetlbfs at path inside monRootfs with
+// hugepages of pageSize bytes. The monitor backs the memory of the guest
+// with files in it and hence, it is writable by the user of the monitor.
+func createHugetlbfs(monRootfs string, path string, pageSize uint64) error {
+	dstPath := filepath.Join(monRootfs, path)
+	mountType := "hugetlbfs"
+	data := "mode=1777,pagesize=" + strconv.FormatUint(pageSize, 10)
+
+	err := os.MkdirAll(dstPath, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create %s dir: %w", path, err)
+	}
+
+	err = unix.Mount(mountType, dstPath, mountType, unix.MS_NOSUID|unix.MS_NODEV, data)
+	if err != nil {
+		return fmt.Errorf("failed to mount %s hugetlbfs: %w", path, err)
+	}
+
+	// Remove propagation
+	err = unix.Mount("", dstPath, "", unix.MS_PRIVATE, "")
+	if err != nil {
+		return fmt.Errorf("failed to create %s hugetlbfs: %w", path, err)
+	}
+	return nil
+}
+
 // SetupDev set ups one new device in the container's rootfs.
 // This function will get the major and minor number of
 // the device from the host's rootfs and it will replicate the device
@@ -206,6 +233,14 @@ func fileFromHost(monRootfs string, hostPath string, target string, mFlags int,
 		}
 
 		err = os.Chown(dstPath, int(fileInfo.Uid), int(fileInfo.Gid))
+		// Inside a user namespace the owner of the original file might
+		// not be mapped (e.g. root of the host). In that case, keep the
+		// current owner, which is the root of the user namespace.
+		if err != nil && userns.RunningInUserNS() &&
+			(errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EPERM)) {
+			uniklog.Debugf("could not preserve ownership of %s in user namespace: %v", dstPath, err)
+			err = nil
+		}
 		if err != nil {
 			return fmt.Errorf("failed to chown %s: %w", dstPath, err)
 		}
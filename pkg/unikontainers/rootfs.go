@@ -15,11 +15,13 @@
 package unikontainers
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 
+	"github.com/moby/sys/userns"
 	"golang.org/x/sys/unix"
 
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
@@ -45,6 +47,12 @@ func newRootfsResult(rootfsType string, path string, mountedPath string, monRoot
 	}
 }
 
+// supportsBlock returns true if both the guest and the monitor support
+// block devices
+func (rs *rootfsSelector) supportsBlock() bool {
+	return rs.unikernel.Capabilities().Block && rs.vmm.Capabilities().Block
+}
+
 // tryInitrd checks for initrd-based rootfs based on annotation values
 func (rs *rootfsSelector) tryInitrd() (types.RootfsParams, bool) {
 	initrdPath := rs.annot[annotInitrd]
@@ -62,7 +70,7 @@ func (rs *rootfsSelector) tryExplicitBlock() (types.RootfsParams, bool) {
 	blockMntPoint := rs.annot[annotBlockMntPoint]
 
 	// Only use explicit block if it's meant to be root (mounted at /)
-	if blockPath == "" || blockMntPoint != "/" || !rs.unikernel.SupportsBlock() {
+	if blockPath == "" || blockMntPoint != "/" || !rs.supportsBlock() {
 		return types.RootfsParams{}, false
 	}
 
@@ -89,7 +97,14 @@ func (rs *rootfsSelector) shouldMountContainerRootfs() bool {
 // tryContainerBlockRootfs checks if container rootfs can be used as a block device
 // for guest's rootfs
 func (rs *rootfsSelector) tryContainerBlockRootfs() (types.RootfsParams, bool) {
-	if !rs.unikernel.SupportsBlock() {
+	if !rs.supportsBlock() {
+		return types.RootfsParams{}, false
+	}
+
+	// Using the devmapper snapshot requires unmounting it and accessing
+	// the block device, which is not possible in rootless mode.
+	if rs.annot[annotRootless] == "true" {
+		uniklog.Info("block based rootfs is not supported in rootless mode, trying shared-fs")
 		return types.RootfsParams{}, false
 	}
 
@@ -99,7 +114,7 @@ func (rs *rootfsSelector) tryContainerBlockRootfs() (types.RootfsParams, bool) {
 		return types.RootfsParams{}, false
 	}
 
-	if !rs.unikernel.SupportsFS(rootFsDevice.FsType) {
+	if !rs.unikernel.Capabilities().SupportsBlockFS(rootFsDevice.FsType) {
 		return types.RootfsParams{}, false
 	}
 
@@ -108,15 +123,12 @@ func (rs *rootfsSelector) tryContainerBlockRootfs() (types.RootfsParams, bool) {
 
 // tryVirtiofs checks if virtiofs can be used
 func (rs *rootfsSelector) tryVirtiofs() (types.RootfsParams, bool) {
-	if !rs.unikernel.SupportsFS("virtiofs") {
+	if !rs.unikernel.Capabilities().Virtiofs || !rs.vmm.Capabilities().Virtiofs {
 		return types.RootfsParams{}, false
 	}
 
-	if !rs.vmm.SupportsSharedfs("virtio") {
-		return types.RootfsParams{}, false
-	}
-
-	if !fileExists(rs.vfsdPath) {
+	// Some monitors (e.g. crosvm) implement virtiofs without virtiofsd
+	if !rs.vmm.Capabilities().BuiltinVirtiofs && !fileExists(rs.vfsdPath) {
 		return types.RootfsParams{}, false
 	}
 
@@ -125,11 +137,7 @@ func (rs *rootfsSelector) tryVirtiofs() (types.RootfsParams, bool) {
 
 // try9pfs checks if 9pfs can be used
 func (rs *rootfsSelector) try9pfs() (types.RootfsParams, bool) {
-	if !rs.unikernel.SupportsFS("9pfs") {
-		return types.RootfsParams{}, false
-	}
-
-	if !rs.vmm.SupportsSharedfs("9p") {
+	if !rs.unikernel.Capabilities().NinePfs || !rs.vmm.Capabilities().NinePfs {
 		return types.RootfsParams{}, false
 	}
 
@@ -315,14 +323,19 @@ func changeRoot(rootfsDir string, pivot bool) error {
 // essentially sets up the devices (KVM, snapshotter block device) that are required
 // for the guest execution and any other files (e.g. binaries).
 func prepareMonRootfs(monRootfs string, monitorPath string, monitorDataPath string, needsKVM bool, needsTAP bool) error {
-	err := fileFromHost(monRootfs, monitorPath, "", unix.MS_BIND|unix.MS_PRIVATE, false)
-	if err != nil {
-		return err
+	var err error
+	// Some monitors (e.g. Hedge) do not execute as a process in the host
+	// and hence there is no monitor binary or libraries to set up.
+	if monitorPath != "" {
+		err = fileFromHost(monRootfs, monitorPath, "", unix.MS_BIND|unix.MS_PRIVATE, false)
+		if err != nil {
+			return err
+		}
 	}
 
 	// TODO: Remove these when we switch to static binaries
 	monitorName := filepath.Base(monitorPath)
-	if monitorName != "firecracker" {
+	if monitorPath != "" && monitorName != "firecracker" {
 		err = fileFromHost(monRootfs, "/lib", "", unix.MS_BIND|unix.MS_PRIVATE, false)
 		if err != nil {
 			return err
@@ -431,7 +444,14 @@ func prepareMonRootfs(monRootfs string, monitorPath string, monitorDataPath stri
 
 	// Mount devpts filesystem
 	// Using newinstance creates an isolated pts namespace for this container
-	err = unix.Mount("devpts", devPtsDir, "devpts", unix.MS_NOSUID|unix.MS_NOEXEC, "newinstance,ptmxmode=0666,mode=0620,gid=5")
+	devPtsData := "newinstance,ptmxmode=0666,mode=0620"
+	err = unix.Mount("devpts", devPtsDir, "devpts", unix.MS_NOSUID|unix.MS_NOEXEC, devPtsData+",gid=5")
+	if errors.Is(err, unix.EINVAL) && userns.RunningInUserNS() {
+		// The tty group might not be mapped in the user namespace
+		// (e.g. in rootless mode). In that case, use the group of
+		// the current process.
+		err = unix.Mount("devpts", devPtsDir, "devpts", unix.MS_NOSUID|unix.MS_NOEXEC, devPtsData)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to mount devpts: %w", err)
 	}
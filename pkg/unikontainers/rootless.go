@@ -0,0 +1,264 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups/v3"
+	"github.com/moby/sys/userns"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// State annotations to keep track of rootless execution.
+	// The uid/gid annotations are set only when urunc had to create
+	// a user namespace on its own, mapping the root of the container
+	// to the unprivileged user that invoked urunc.
+	annotRootless    = "urunc_state.rootless"
+	annotRootlessUID = "urunc_state.rootless_uid"
+	annotRootlessGID = "urunc_state.rootless_gid"
+)
+
+// SetRootless marks the container as a rootless one. If urunc runs as an
+// unprivileged user in the initial user namespace, the container will also
+// get a new user, mount and network namespace, so that urunc can prepare
+// the monitor's rootfs and create the tap device.
+// The change is recorded in the state annotations and therefore, the caller
+// needs to save the state afterwards.
+func (u *Unikontainer) SetRootless() {
+	u.State.Annotations[annotRootless] = "true"
+	if os.Geteuid() != 0 && (!u.usesUserNS() || len(u.Spec.Linux.UIDMappings) == 0) {
+		u.State.Annotations[annotRootlessUID] = strconv.Itoa(os.Geteuid())
+		u.State.Annotations[annotRootlessGID] = strconv.Itoa(os.Getegid())
+	}
+	u.setupRootlessSpec()
+}
+
+// IsRootless returns true if the container was created in rootless mode
+func (u *Unikontainer) IsRootless() bool {
+	return u.State.Annotations[annotRootless] == "true"
+}
+
+// setupRootlessSpec adds the namespaces and the uid/gid mappings that a
+// rootless container requires, in case urunc had to create its own user
+// namespace. Since the spec is always read from the bundle, it needs to be
+// called every time we load the container.
+func (u *Unikontainer) setupRootlessSpec() {
+	uidStr := u.State.Annotations[annotRootlessUID]
+	gidStr := u.State.Annotations[annotRootlessGID]
+	if uidStr == "" || gidStr == "" || u.Spec.Linux == nil {
+		return
+	}
+	uid, err := strconv.ParseUint(uidStr, 10, 32)
+	if err != nil {
+		uniklog.Warnf("invalid rootless uid %s: %v", uidStr, err)
+		return
+	}
+	gid, err := strconv.ParseUint(gidStr, 10, 32)
+	if err != nil {
+		uniklog.Warnf("invalid rootless gid %s: %v", gidStr, err)
+		return
+	}
+
+	for _, nsType := range []specs.LinuxNamespaceType{
+		specs.UserNamespace,
+		specs.MountNamespace,
+		specs.NetworkNamespace,
+	} {
+		if hasNS(u.Spec.Linux.Namespaces, nsType) {
+			continue
+		}
+		u.Spec.Linux.Namespaces = append(u.Spec.Linux.Namespaces, specs.LinuxNamespace{Type: nsType})
+	}
+	u.Spec.Linux.UIDMappings = []specs.LinuxIDMapping{{ContainerID: 0, HostID: uint32(uid), Size: 1}}
+	u.Spec.Linux.GIDMappings = []specs.LinuxIDMapping{{ContainerID: 0, HostID: uint32(gid), Size: 1}}
+}
+
+// hasNS returns true if a namespace of the given type exists in the list
+func hasNS(namespaces []specs.LinuxNamespace, nsType specs.LinuxNamespaceType) bool {
+	for _, ns := range namespaces {
+		if ns.Type == nsType {
+			return true
+		}
+	}
+	return false
+}
+
+// rootlessEUID returns true if urunc runs as an unprivileged user in the
+// initial user namespace and hence the uid/gid mappings of a new user
+// namespace are restricted.
+func (u *Unikontainer) rootlessEUID() bool {
+	return u.IsRootless() && os.Geteuid() != 0
+}
+
+// needsIDMapTools returns true if the mappings contain more than the
+// single mapping of the container's root to hostID, which is the only
+// mapping an unprivileged user can write without newuidmap/newgidmap.
+func needsIDMapTools(idMap []specs.LinuxIDMapping, hostID int) bool {
+	if len(idMap) != 1 {
+		return true
+	}
+	return int(idMap[0].HostID) != hostID || idMap[0].Size != 1
+}
+
+// idMapToolPaths returns the paths of the newuidmap and newgidmap binaries
+func idMapToolPaths() (string, string, error) {
+	uidmapPath, err := exec.LookPath("newuidmap")
+	if err != nil {
+		return "", "", fmt.Errorf("rootless mode with multiple uid mappings requires newuidmap: %w", err)
+	}
+	gidmapPath, err := exec.LookPath("newgidmap")
+	if err != nil {
+		return "", "", fmt.Errorf("rootless mode with multiple gid mappings requires newgidmap: %w", err)
+	}
+	return uidmapPath, gidmapPath, nil
+}
+
+// Feature describes the availability of a feature of urunc in the host
+type Feature struct {
+	Name      string
+	Available bool
+	Details   string
+}
+
+// CheckFeatures checks which features of urunc are available in the
+// current host and for the current user. If rootless is set, it
+// reports the features that are unavailable in rootless mode.
+func CheckFeatures(rootless bool) []Feature {
+	features := []Feature{
+		checkDevAccess("KVM", "/dev/kvm"),
+		checkDevAccess("TUN/TAP devices", "/dev/net/tun"),
+	}
+	if !rootless {
+		features = append(features, Feature{
+			Name:      "block based rootfs (devmapper)",
+			Available: true,
+			Details:   "running as root",
+		})
+		return features
+	}
+
+	features = append(features,
+		checkUserNS(),
+		checkIDMapTools(),
+		checkCgroupDelegation(),
+		checkXDGRuntimeDir(),
+		Feature{
+			Name:      "block based rootfs (devmapper)",
+			Available: false,
+			Details:   "not supported in rootless mode, initrd or shared-fs (virtiofs, 9p) rootfs will be used instead",
+		},
+		Feature{
+			Name:      "block volumes",
+			Available: false,
+			Details:   "not supported in rootless mode, since unmounting block devices requires root",
+		},
+	)
+	return features
+}
+
+func checkDevAccess(name string, path string) Feature {
+	err := unix.Access(path, unix.R_OK|unix.W_OK)
+	if err != nil {
+		return Feature{Name: name, Details: fmt.Sprintf("no read/write access to %s: %v", path, err)}
+	}
+	return Feature{Name: name, Available: true, Details: path + " is accessible"}
+}
+
+func checkUserNS() Feature {
+	f := Feature{Name: "user namespaces"}
+	if userns.RunningInUserNS() {
+		f.Available = true
+		f.Details = "already running inside a user namespace"
+		return f
+	}
+	maxNS, err := readIntFile("/proc/sys/user/max_user_namespaces")
+	if err == nil && maxNS == 0 {
+		f.Details = "disabled by /proc/sys/user/max_user_namespaces"
+		return f
+	}
+	// Debian-based kernels have an extra knob for unprivileged user namespaces
+	unprivClone, err := readIntFile("/proc/sys/kernel/unprivileged_userns_clone")
+	if err == nil && unprivClone == 0 {
+		f.Details = "disabled by /proc/sys/kernel/unprivileged_userns_clone"
+		return f
+	}
+	f.Available = true
+	f.Details = "unprivileged user namespaces are enabled"
+	return f
+}
+
+func checkIDMapTools() Feature {
+	f := Feature{Name: "multiple uid/gid mappings"}
+	_, _, err := idMapToolPaths()
+	if err != nil {
+		f.Details = "only the user that invokes urunc can be mapped: " + err.Error()
+		return f
+	}
+	f.Available = true
+	f.Details = "newuidmap and newgidmap were found"
+	return f
+}
+
+func checkCgroupDelegation() Feature {
+	f := Feature{Name: "cgroups"}
+	if cgroups.Mode() != cgroups.Unified {
+		f.Details = "resource limits require cgroup v2 in rootless mode"
+		return f
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		f.Details = fmt.Sprintf("failed to read current cgroup: %v", err)
+		return f
+	}
+	// In cgroup v2, the entry has the form 0::/path
+	cgPath := strings.TrimPrefix(strings.TrimSpace(string(data)), "0::")
+	cgDir := filepath.Join(cgroupMountpoint, cgPath)
+	err = unix.Access(cgDir, unix.W_OK)
+	if err != nil {
+		f.Details = fmt.Sprintf("cgroup %s is not delegated to the current user, resource limits will not be applied", cgPath)
+		return f
+	}
+	f.Available = true
+	f.Details = fmt.Sprintf("cgroup %s is delegated to the current user", cgPath)
+	return f
+}
+
+func checkXDGRuntimeDir() Feature {
+	f := Feature{Name: "XDG_RUNTIME_DIR"}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		f.Details = "not set, --root needs to point to a directory owned by the current user"
+		return f
+	}
+	f.Available = true
+	f.Details = "state will be stored under " + filepath.Join(dir, "urunc")
+	return f
+}
+
+func readIntFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
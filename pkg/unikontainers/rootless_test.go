@@ -0,0 +1,117 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/hypervisors"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/unikernels"
+)
+
+func TestSetupRootlessSpec(t *testing.T) {
+	t.Run("adds namespaces and mappings", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{
+			Spec: &specs.Spec{
+				Linux: &specs.Linux{
+					Namespaces: []specs.LinuxNamespace{
+						{Type: specs.PIDNamespace},
+						{Type: specs.MountNamespace},
+					},
+				},
+			},
+			State: &specs.State{
+				Annotations: map[string]string{
+					annotRootless:    "true",
+					annotRootlessUID: "1000",
+					annotRootlessGID: "1001",
+				},
+			},
+		}
+		u.setupRootlessSpec()
+
+		assert.True(t, u.IsRootless())
+		assert.Len(t, u.Spec.Linux.Namespaces, 4, "Expected user and network namespaces to be added once")
+		assert.True(t, hasNS(u.Spec.Linux.Namespaces, specs.UserNamespace))
+		assert.True(t, hasNS(u.Spec.Linux.Namespaces, specs.NetworkNamespace))
+		assert.Equal(t, []specs.LinuxIDMapping{{ContainerID: 0, HostID: 1000, Size: 1}}, u.Spec.Linux.UIDMappings)
+		assert.Equal(t, []specs.LinuxIDMapping{{ContainerID: 0, HostID: 1001, Size: 1}}, u.Spec.Linux.GIDMappings)
+	})
+
+	t.Run("user namespace provided by the spec", func(t *testing.T) {
+		t.Parallel()
+		idMap := []specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 65536}}
+		u := newUserNSUnikontainer(idMap, idMap)
+		u.State = &specs.State{Annotations: map[string]string{annotRootless: "true"}}
+		u.setupRootlessSpec()
+
+		assert.Len(t, u.Spec.Linux.Namespaces, 2)
+		assert.Equal(t, idMap, u.Spec.Linux.UIDMappings)
+	})
+}
+
+func TestNeedsIDMapTools(t *testing.T) {
+	t.Parallel()
+	assert.False(t, needsIDMapTools([]specs.LinuxIDMapping{{ContainerID: 0, HostID: 1000, Size: 1}}, 1000))
+	assert.True(t, needsIDMapTools([]specs.LinuxIDMapping{{ContainerID: 0, HostID: 1000, Size: 1}}, 1001))
+	assert.True(t, needsIDMapTools([]specs.LinuxIDMapping{{ContainerID: 0, HostID: 1000, Size: 65536}}, 1000))
+	assert.True(t, needsIDMapTools([]specs.LinuxIDMapping{
+		{ContainerID: 0, HostID: 1000, Size: 1},
+		{ContainerID: 1, HostID: 100000, Size: 65535},
+	}, 1000))
+}
+
+func TestRootfsSelectorRootless(t *testing.T) {
+	t.Parallel()
+	unikernel, err := unikernels.New("rumprun")
+	assert.NoError(t, err)
+	rs := &rootfsSelector{
+		annot: map[string]string{
+			annotRootless:    "true",
+			annotMountRootfs: "true",
+		},
+		cntrRootfs: "/container/rootfs",
+		unikernel:  unikernel,
+		vmm:        &hypervisors.HVT{},
+	}
+
+	_, found := rs.tryContainerBlockRootfs()
+	assert.False(t, found, "Expected block based rootfs to be skipped in rootless mode")
+}
+
+func TestCheckFeatures(t *testing.T) {
+	t.Run("root mode", func(t *testing.T) {
+		t.Parallel()
+		for _, f := range CheckFeatures(false) {
+			assert.NotEmpty(t, f.Name)
+			assert.NotEmpty(t, f.Details)
+		}
+	})
+
+	t.Run("rootless mode", func(t *testing.T) {
+		t.Parallel()
+		features := CheckFeatures(true)
+		for _, f := range features {
+			if f.Name == "block based rootfs (devmapper)" {
+				assert.False(t, f.Available)
+				return
+			}
+		}
+		t.Fatal("Expected devmapper to be reported in rootless mode")
+	})
+}
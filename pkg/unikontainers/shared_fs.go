@@ -52,7 +52,7 @@ func setupSharedfsBasedRootfs(rfs types.RootfsParams, vfsdBin string, mounts []s
 		return err
 	}
 
-	if rfs.Type == "virtiofs" {
+	if rfs.Type == "virtiofs" && vfsdBin != "" {
 		// Get the virtiofsd binary from host in monRootfs
 		err = fileFromHost(rfs.MonRootfs, vfsdBin, "", unix.MS_BIND|unix.MS_PRIVATE, false)
 		if err != nil {
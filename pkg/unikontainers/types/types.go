@@ -15,11 +15,16 @@
 //revive:disable:var-naming
 package types
 
+import (
+	"slices"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
 type Unikernel interface {
 	Init(UnikernelParams) error
 	CommandString() (string, error)
-	SupportsBlock() bool
-	SupportsFS(string) bool
+	Capabilities() UnikernelCapabilities
 	MonitorNetCli(string, string) string
 	MonitorBlockCli() []MonitorBlockArgs
 	MonitorCli() MonitorCliArgs
@@ -36,11 +41,115 @@ type VMM interface {
 	PreExec(args ExecArgs) error
 	Stop(int) error
 	Path() string
-	UsesKVM() bool
-	SupportsSharedfs(string) bool
+	Capabilities() VMMCapabilities
 	Ok() error
 }
 
+// VsockMode describes how a monitor exposes the vsock device of the guest
+// to the host
+type VsockMode string
+
+const (
+	VsockNone  VsockMode = ""      // The monitor does not support vsock
+	VsockVhost VsockMode = "vhost" // The host reaches the guest through vhost-vsock (host CID 2)
+	VsockUnix  VsockMode = "unix"  // The host reaches the guest through a unix socket (hybrid vsock)
+)
+
+// VMMCapabilities describes the features that a monitor supports
+type VMMCapabilities struct {
+	KVM             bool      // The monitor requires access to /dev/kvm
+	Block           bool      // The monitor can attach block devices to the guest
+	NinePfs         bool      // The monitor can share a directory with the guest through 9p
+	Virtiofs        bool      // The monitor can share a directory with the guest through virtiofs
+	BuiltinVirtiofs bool      // The monitor implements virtiofs itself, without virtiofsd
+	Vsock           VsockMode // The way the monitor exposes vsock, if any
+	MultiNIC        bool      // The monitor can attach more than one network device
+	Hotplug         bool      // The monitor can attach devices to a running guest
+	Snapshot        bool      // The monitor can snapshot the guest
+	Pause           bool      // The monitor can pause and resume the guest
+	Hugepages       bool      // The monitor can back the memory of the guest with hugepages
+	PanicEvents     bool      // The monitor reports a panic of the guest (pvpanic) to an event file descriptor
+	Archs           []string  // The architectures (GOARCH) that the monitor supports. Empty means any
+}
+
+// SupportsArch returns true if the monitor supports the given architecture
+func (c VMMCapabilities) SupportsArch(arch string) bool {
+	return supportsArch(c.Archs, arch)
+}
+
+// UnikernelCapabilities describes the features that a unikernel (or guest
+// in general) supports
+type UnikernelCapabilities struct {
+	Block    bool     // The guest can use block devices
+	BlockFS  []string // The filesystems that the guest can mount from block devices
+	NinePfs  bool     // The guest can mount a shared directory through 9p
+	Virtiofs bool     // The guest can mount a shared directory through virtiofs
+	Vsock    bool     // The guest can communicate with the host through vsock (e.g. vAccel)
+	MultiNIC bool     // The guest can use more than one network device
+	Microvm  bool     // The guest can find the virtio-mmio devices of Qemu's microvm machine
+	Archs    []string // The architectures (GOARCH) that the guest supports. Empty means any
+}
+
+// SupportsBlockFS returns true if the guest can mount the given filesystem
+// from a block device
+func (c UnikernelCapabilities) SupportsBlockFS(fsType string) bool {
+	return c.Block && slices.Contains(c.BlockFS, fsType)
+}
+
+// SupportsArch returns true if the guest supports the given architecture
+func (c UnikernelCapabilities) SupportsArch(arch string) bool {
+	return supportsArch(c.Archs, arch)
+}
+
+func supportsArch(archs []string, arch string) bool {
+	return len(archs) == 0 || slices.Contains(archs, arch)
+}
+
+// VMMRunner is implemented by monitors that do not execute as a process in
+// the host (e.g. Hedge). Instead of execve'ing the monitor, urunc calls Run,
+// which launches the VM and blocks until the VM exits.
+type VMMRunner interface {
+	Run(args ExecArgs, ukernel Unikernel) error
+}
+
+// GuestPanicExitCode is the exit code of a container, whose guest crashed
+// (e.g. a kernel panic or an abort of Solo5)
+const GuestPanicExitCode = 255
+
+// GuestExit describes how the guest of a monitor exited
+type GuestExit struct {
+	Code  int  // The exit code of the application in the guest
+	Panic bool // The guest crashed, instead of exiting
+}
+
+// ExitMapper maps the exit status of a monitor to the exit of its guest
+type ExitMapper func(status int) GuestExit
+
+// GuestExitReporter is implemented by guests that report their exit
+// through a device of the monitor (e.g. isa-debug-exit of Qemu), which then
+// exits with a status other than the one of the guest. For these guests,
+// urunc executes the monitor as its child and the container exits with
+// the mapped exit code.
+type GuestExitReporter interface {
+	// ExitMapper returns nil if the exit status of the monitor is
+	// already the exit code of the guest
+	ExitMapper() ExitMapper
+}
+
+// GuestCrashReporter is implemented by guests that print a known message
+// on their console, when they crash
+type GuestCrashReporter interface {
+	// CrashPatterns returns the regular expressions that match a line of
+	// the console, which reports a crash of the guest
+	CrashPatterns() []string
+}
+
+// VMMSocketStopper is implemented by monitors that can stop the VM through
+// their control socket, instead of killing the monitor process.
+type VMMSocketStopper interface {
+	StopWithSocket(socketPath string, pid int) error
+}
+
 type NetDevParams struct {
 	IP      string // The veth device IP
 	Mask    string // The veth device mask
@@ -81,11 +190,22 @@ type UnikernelParams struct {
 	EnvVars    []string // The environment variables provided by the image
 	Monitor    string   // The monitor where guest will execute
 	Version    string   // The version of the unikernel
+	BinaryPath string   // The path of the unikernel binary on the host
 	InitrdPath string   // The path to the initrd of the unikernel
-	Net        NetDevParams
-	Block      []BlockDevParams
-	Rootfs     RootfsParams  // Information about rootfs
-	ProcConf   ProcessConfig // Information for the process execution inside the guest
+	Machine    string   // The machine type of the VM (e.g. microvm)
+	GuestExit  bool     // The guest reports its exit through a device of the monitor
+	// The network devices of the guest, starting with the one of the
+	// default route
+	Nets     []NetDevParams
+	Block    []BlockDevParams
+	Rootfs   RootfsParams  // Information about rootfs
+	ProcConf ProcessConfig // Information for the process execution inside the guest
+}
+
+// Net returns the network device of the default route of the guest, or an
+// empty one if the guest has no network.
+func (p UnikernelParams) Net() NetDevParams {
+	return firstNet(p.Nets)
 }
 
 // ExecArgs holds the data required by Execve to start the VMM
@@ -96,25 +216,51 @@ type ExecArgs struct {
 	Command       string   // The unikernel's command line
 	Seccomp       bool     // Enable or disable seccomp filters for the VMM
 	MemSizeB      uint64   // The size of the memory provided to the VM in bytes
+	HugepageSize  uint64   // The size of the hugepages that back the memory of the VM in bytes. When zero, hugepages are not used
 	VCPUs         uint     // The number of vCPUs to allocate
 	UnikernelPath string   // The path of the unikernel inside rootfs
 	InitrdPath    string   // The path to the initrd of the unikernel
 	VAccelType    string   // Specifies the vAccel acceleration type(e.g. vsock). When empty, vAccel is disabled
 	VSockDevPath  string   // The host directory where the fc unix socket is created
 	VSockDevID    int      // The guest-cid
-	Net           NetDevParams
-	Sharedfs      SharedfsParams
+	MonitorSocket string   // The path of the monitor's control socket inside the monitor's rootfs
+	Machine       string   // The machine type of the VM. When empty, the monitor's default is used
+	Accel         string   // The accelerator of the VM (e.g. tcg). When empty, KVM is used
+	// The network devices of the guest, in the order that the monitor
+	// attaches them, starting with the one of the default route
+	Nets     []NetDevParams
+	Sharedfs SharedfsParams
+	// The seccomp profile of the container, which restricts the monitor
+	SeccompProfile *specs.LinuxSeccomp
+	SeccompMode    string // How the seccomp filter gets applied (enforce, log or learn)
+	Supervised     bool   // The monitor executes as a child of urunc, instead of replacing it
+	// The file descriptor where the monitor reports the events of the
+	// guest (e.g. a panic). When zero, the events are not reported
+	EventFd int
+}
+
+// Net returns the network device of the default route of the guest, or an
+// empty one if the guest has no network.
+func (a ExecArgs) Net() NetDevParams {
+	return firstNet(a.Nets)
+}
+
+func firstNet(nets []NetDevParams) NetDevParams {
+	if len(nets) == 0 {
+		return NetDevParams{}
+	}
+	return nets[0]
 }
 
 type MonitorCliArgs struct {
 	ExtraInitrd string
-	OtherArgs   string
+	OtherArgs   string // Whitespace separated arguments, which can not contain paths
 }
 
 type MonitorBlockArgs struct {
 	ID        string
 	Path      string
-	ExactArgs string
+	ExactArgs string // Whitespace separated arguments, which can not contain paths
 }
 
 // ExtraBinConfig struct is used to hold specific configuration for extra binaries
@@ -129,7 +275,18 @@ type ExtraBinConfig struct {
 type MonitorConfig struct {
 	DefaultMemoryMB uint   `toml:"default_memory_mb"`
 	DefaultVCPUs    uint   `toml:"default_vcpus"`
-	BinaryPath      string `toml:"path,omitempty"`      // Optional path to the hypervisor binary
-	DataPath        string `toml:"data_path,omitempty"` // Optional path to the hypervisor data files (e.g. qemu bios stuff)
-	Vhost           bool   `toml:"vhost,omitempty"`     // Optional: enable vhost for network performance optimization
+	BinaryPath      string `toml:"path,omitempty"`       // Optional path to the hypervisor binary
+	DataPath        string `toml:"data_path,omitempty"`  // Optional path to the hypervisor data files (e.g. qemu bios stuff)
+	Vhost           bool   `toml:"vhost,omitempty"`      // Optional: enable vhost for network performance optimization
+	APISocket       bool   `toml:"api_socket,omitempty"` // Optional: configure the monitor through its API socket (Firecracker)
+	Machine         string `toml:"machine,omitempty"`    // Optional: the machine type of the VM (e.g. microvm for Qemu)
+	Accel           string `toml:"accel,omitempty"`      // Optional: the accelerator of Qemu (kvm, tcg or auto)
+	GuestExit       bool   `toml:"guest_exit,omitempty"` // Optional: urunit reports the exit of the guest through isa-debug-exit (Qemu)
+	// Options of the seccomp filter of the monitor
+	SeccompMode     string `toml:"seccomp_mode,omitempty"`      // Optional: enforce, log or learn
+	SeccompLearnDir string `toml:"seccomp_learn_dir,omitempty"` // Optional: the directory of the profiles that learn mode records
+	// Options of generic monitors, which are declared only in the config
+	Template string   `toml:"template,omitempty"` // The text/template of the monitor's command line
+	UsesKVM  bool     `toml:"uses_kvm,omitempty"` // The monitor requires access to /dev/kvm
+	Sharedfs []string `toml:"sharedfs,omitempty"` // The shared-fs types that the monitor supports (9p, virtio)
 }
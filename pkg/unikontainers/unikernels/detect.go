@@ -0,0 +1,301 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikernels
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// The Solo5 ABI targets of include/elf_abi.h
+	solo5TargetHvt    = 1
+	solo5TargetSpt    = 2
+	solo5TargetVirtio = 3
+	// The header of the x86 Linux boot protocol, which bzImages start with
+	bzImageMagicOffset = 0x202
+	bzImageMagic       = "HdrS"
+	// The header of arm64 Linux Images
+	arm64ImageMagicOffset = 0x38
+	arm64ImageMagic       = "ARM\x64"
+	// Multiboot headers must be within the first bytes of the binary
+	multibootMagic      = 0x1badb002
+	multibootSearchLen  = 8192
+	multiboot2Magic     = 0xe85250d6
+	multiboot2SearchLen = 32768
+	detectHeaderLen     = multiboot2SearchLen
+	// Unikraft places its tables in sections of this prefix
+	unikraftSectionPrefix = ".uk_"
+	// The names of the ELF notes of Linux kernels
+	linuxNoteName          = "Linux"
+	xenNoteName            = "Xen"
+	elfNoteHeaderSize      = 12
+	elfNoteMinAlignment    = 4
+	solo5ABINoteTargetSize = 4
+)
+
+var (
+	ErrUnknownBinary = errors.New("unknown unikernel binary format")
+	errNoteNotFound  = errors.New("ELF note not found")
+)
+
+// Markers in the content of Solo5 binaries that reveal the framework that
+// built them, since the Solo5 notes do not. They come from the runtime
+// libraries of each framework and survive stripping.
+var solo5FrameworkMarkers = []struct {
+	unikernel string
+	markers   []string
+}{
+	{RumprunUnikernel, []string{"rumpuser", "rumprun"}},
+	{MirageUnikernel, []string{"Mirage_runtime", "caml_startup"}},
+}
+
+// Detection describes what a unikernel binary was recognised as.
+type Detection struct {
+	Format    string   // The format of the binary (e.g. Linux bzImage)
+	Unikernel string   // The unikernel type. It is empty if it can not be inferred
+	Monitors  []string // The compatible monitors, in order of preference
+}
+
+// Detect inspects a unikernel binary and infers its type and the monitors
+// that can execute it. It recognises the ELF notes of Solo5 and Linux, the
+// sections of Unikraft, the headers of Linux bzImages and arm64 Images and
+// multiboot headers.
+func Detect(path string) (Detection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Detection{}, err
+	}
+	defer f.Close()
+
+	header := make([]byte, detectHeaderLen)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Detection{}, err
+	}
+	header = header[:n]
+
+	if bytes.HasPrefix(header, []byte(elf.ELFMAG)) {
+		elfFile, err := elf.NewFile(f)
+		if err != nil {
+			return Detection{}, err
+		}
+		defer elfFile.Close()
+		return detectELF(f, elfFile, header)
+	}
+	if hasMagic(header, bzImageMagicOffset, bzImageMagic) {
+		return Detection{
+			Format:    "Linux bzImage",
+			Unikernel: LinuxUnikernel,
+			Monitors:  []string{"qemu", "cloud-hypervisor", "crosvm", "kvmtool"},
+		}, nil
+	}
+	if hasMagic(header, arm64ImageMagicOffset, arm64ImageMagic) {
+		return Detection{
+			Format:    "Linux arm64 Image",
+			Unikernel: LinuxUnikernel,
+			Monitors:  []string{"qemu", "firecracker", "cloud-hypervisor", "crosvm", "kvmtool"},
+		}, nil
+	}
+	if hasMultibootHeader(header) {
+		return Detection{Format: "multiboot", Monitors: []string{"qemu"}}, nil
+	}
+	return Detection{}, ErrUnknownBinary
+}
+
+func detectELF(content io.ReaderAt, f *elf.File, header []byte) (Detection, error) {
+	abi, err := findELFNote(f, solo5NoteName, solo5NoteABI)
+	if err == nil && len(abi) >= solo5ABINoteTargetSize {
+		return detectSolo5(content, f.ByteOrder.Uint32(abi))
+	}
+
+	for _, sect := range f.Sections {
+		if strings.HasPrefix(sect.Name, unikraftSectionPrefix) {
+			return Detection{
+				Format:    "Unikraft ELF",
+				Unikernel: UnikraftUnikernel,
+				Monitors:  []string{"qemu", "firecracker", "cloud-hypervisor"},
+			}, nil
+		}
+	}
+
+	notes, err := elfNotes(f)
+	if err != nil {
+		return Detection{}, err
+	}
+	for _, note := range notes {
+		if note.name == linuxNoteName || note.name == xenNoteName {
+			return Detection{
+				Format:    "Linux ELF",
+				Unikernel: LinuxUnikernel,
+				Monitors:  []string{"firecracker", "cloud-hypervisor", "qemu"},
+			}, nil
+		}
+	}
+
+	if hasMultibootHeader(header) {
+		return Detection{Format: "multiboot ELF", Monitors: []string{"qemu"}}, nil
+	}
+	return Detection{}, ErrUnknownBinary
+}
+
+// detectSolo5 infers the monitor from the ABI target of a Solo5 binary
+// and the unikernel type from the markers in its content.
+func detectSolo5(content io.ReaderAt, target uint32) (Detection, error) {
+	d := Detection{}
+	switch target {
+	case solo5TargetHvt:
+		d.Format = "Solo5 hvt"
+		d.Monitors = []string{"hvt"}
+	case solo5TargetSpt:
+		d.Format = "Solo5 spt"
+		d.Monitors = []string{"spt"}
+	case solo5TargetVirtio:
+		d.Format = "Solo5 virtio"
+		d.Monitors = []string{"qemu"}
+	default:
+		return Detection{}, fmt.Errorf("%w: unsupported Solo5 target %d", ErrUnknownBinary, target)
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(content, 0, 1<<62))
+	if err != nil {
+		return Detection{}, err
+	}
+	for _, framework := range solo5FrameworkMarkers {
+		for _, marker := range framework.markers {
+			if bytes.Contains(data, []byte(marker)) {
+				d.Unikernel = framework.unikernel
+				return d, nil
+			}
+		}
+	}
+	return d, nil
+}
+
+func hasMagic(header []byte, offset int, magic string) bool {
+	return len(header) >= offset+len(magic) && string(header[offset:offset+len(magic)]) == magic
+}
+
+// hasMultibootHeader searches a multiboot or multiboot2 header, including
+// its checksum, in the beginning of a binary.
+func hasMultibootHeader(header []byte) bool {
+	for off := 0; off+12 <= len(header) && off < multibootSearchLen; off += 4 {
+		magic := binary.LittleEndian.Uint32(header[off:])
+		flags := binary.LittleEndian.Uint32(header[off+4:])
+		checksum := binary.LittleEndian.Uint32(header[off+8:])
+		if magic == multibootMagic && magic+flags+checksum == 0 {
+			return true
+		}
+	}
+	for off := 0; off+16 <= len(header) && off < multiboot2SearchLen; off += 8 {
+		magic := binary.LittleEndian.Uint32(header[off:])
+		arch := binary.LittleEndian.Uint32(header[off+4:])
+		length := binary.LittleEndian.Uint32(header[off+8:])
+		checksum := binary.LittleEndian.Uint32(header[off+12:])
+		if magic == multiboot2Magic && magic+arch+length+checksum == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type elfNote struct {
+	name     string
+	noteType uint32
+	desc     []byte
+}
+
+// elfNotes returns the notes of the note sections of an ELF binary or
+// of its note segments, if the section headers have been stripped. The
+// alignment of the section or segment is respected, since some notes
+// (e.g. the ones of Solo5) align their descriptors to 8 bytes.
+func elfNotes(f *elf.File) ([]elfNote, error) {
+	var notes []elfNote
+	for _, sect := range f.Sections {
+		if sect.Type != elf.SHT_NOTE {
+			continue
+		}
+		data, err := sect.Data()
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, parseELFNotes(data, sect.Addralign, f.ByteOrder)...)
+	}
+	if len(f.Sections) > 0 {
+		return notes, nil
+	}
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		data := make([]byte, prog.Filesz)
+		_, err := prog.ReadAt(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, parseELFNotes(data, prog.Align, f.ByteOrder)...)
+	}
+	return notes, nil
+}
+
+// parseELFNotes parses the notes in data, ignoring a truncated note at
+// the end.
+func parseELFNotes(data []byte, align uint64, order binary.ByteOrder) []elfNote {
+	if align < elfNoteMinAlignment {
+		align = elfNoteMinAlignment
+	}
+	alignUp := func(v uint64) uint64 {
+		return (v + align - 1) &^ (align - 1)
+	}
+	var notes []elfNote
+	for off := uint64(0); off+elfNoteHeaderSize <= uint64(len(data)); {
+		nameSize := uint64(order.Uint32(data[off:]))
+		descSize := uint64(order.Uint32(data[off+4:]))
+		noteType := order.Uint32(data[off+8:])
+		nameOff := off + elfNoteHeaderSize
+		descOff := alignUp(nameOff + nameSize)
+		if descOff+descSize > uint64(len(data)) {
+			break
+		}
+		notes = append(notes, elfNote{
+			name:     string(bytes.TrimRight(data[nameOff:nameOff+nameSize], "\x00")),
+			noteType: noteType,
+			desc:     data[descOff : descOff+descSize],
+		})
+		off = alignUp(descOff + descSize)
+	}
+	return notes
+}
+
+// findELFNote returns the descriptor of the ELF note with the given name
+// and type.
+func findELFNote(f *elf.File, name string, noteType uint32) ([]byte, error) {
+	notes, err := elfNotes(f)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range notes {
+		if note.name == name && note.noteType == noteType {
+			return note.desc, nil
+		}
+	}
+	return nil, errNoteNotFound
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikernels
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// multibootHeader returns a multiboot header with a valid checksum
+func multibootHeader() []byte {
+	header := make([]byte, 12)
+	flags := uint32(0x3)
+	binary.LittleEndian.PutUint32(header, multibootMagic)
+	binary.LittleEndian.PutUint32(header[4:], flags)
+	binary.LittleEndian.PutUint32(header[8:], -(uint32(multibootMagic) + flags))
+	return header
+}
+
+// multiboot2Header returns a multiboot2 header with a valid checksum
+func multiboot2Header() []byte {
+	header := make([]byte, 16)
+	length := uint32(24)
+	binary.LittleEndian.PutUint32(header, multiboot2Magic)
+	binary.LittleEndian.PutUint32(header[8:], length)
+	binary.LittleEndian.PutUint32(header[12:], -(uint32(multiboot2Magic) + length))
+	return header
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    Detection
+	}{
+		{
+			name: "Solo5 hvt MirageOS",
+			content: testELF{
+				notes:     []testNote{solo5ABINote(solo5TargetHvt)},
+				noteAlign: 8,
+				content:   []byte("caml_startup"),
+			}.build(),
+			want: Detection{Format: "Solo5 hvt", Unikernel: MirageUnikernel, Monitors: []string{"hvt"}},
+		},
+		{
+			name: "Solo5 spt Rumprun",
+			content: testELF{
+				notes:     []testNote{solo5ABINote(solo5TargetSpt)},
+				noteAlign: 8,
+				content:   []byte("rumpuser_init"),
+			}.build(),
+			want: Detection{Format: "Solo5 spt", Unikernel: RumprunUnikernel, Monitors: []string{"spt"}},
+		},
+		{
+			name: "Solo5 virtio without framework markers",
+			content: testELF{
+				notes:     []testNote{solo5ABINote(solo5TargetVirtio)},
+				noteAlign: 8,
+			}.build(),
+			want: Detection{Format: "Solo5 virtio", Monitors: []string{"qemu"}},
+		},
+		{
+			name: "stripped Solo5 binary",
+			content: testELF{
+				notes:     []testNote{solo5ABINote(solo5TargetHvt)},
+				noteAlign: 8,
+				stripped:  true,
+			}.build(),
+			want: Detection{Format: "Solo5 hvt", Monitors: []string{"hvt"}},
+		},
+		{
+			name:    "Unikraft",
+			content: testELF{sections: []string{".text", ".uk_inittab"}}.build(),
+			want: Detection{
+				Format:    "Unikraft ELF",
+				Unikernel: UnikraftUnikernel,
+				Monitors:  []string{"qemu", "firecracker", "cloud-hypervisor"},
+			},
+		},
+		{
+			name: "Linux ELF",
+			content: testELF{
+				notes: []testNote{{name: xenNoteName, noteType: 18, desc: make([]byte, 8)}},
+			}.build(),
+			want: Detection{
+				Format:    "Linux ELF",
+				Unikernel: LinuxUnikernel,
+				Monitors:  []string{"firecracker", "cloud-hypervisor", "qemu"},
+			},
+		},
+		{
+			name: "stripped Linux ELF",
+			content: testELF{
+				notes:    []testNote{{name: linuxNoteName, noteType: 1, desc: []byte("6.1\x00")}},
+				stripped: true,
+			}.build(),
+			want: Detection{
+				Format:    "Linux ELF",
+				Unikernel: LinuxUnikernel,
+				Monitors:  []string{"firecracker", "cloud-hypervisor", "qemu"},
+			},
+		},
+		{
+			name:    "multiboot ELF",
+			content: testELF{content: multibootHeader()}.build(),
+			want:    Detection{Format: "multiboot ELF", Monitors: []string{"qemu"}},
+		},
+		{
+			name: "Linux bzImage",
+			content: func() []byte {
+				image := make([]byte, 0x400)
+				copy(image[bzImageMagicOffset:], bzImageMagic)
+				return image
+			}(),
+			want: Detection{
+				Format:    "Linux bzImage",
+				Unikernel: LinuxUnikernel,
+				Monitors:  []string{"qemu", "cloud-hypervisor", "crosvm", "kvmtool"},
+			},
+		},
+		{
+			name: "Linux arm64 Image",
+			content: func() []byte {
+				image := make([]byte, 0x40)
+				copy(image[arm64ImageMagicOffset:], arm64ImageMagic)
+				return image
+			}(),
+			want: Detection{
+				Format:    "Linux arm64 Image",
+				Unikernel: LinuxUnikernel,
+				Monitors:  []string{"qemu", "firecracker", "cloud-hypervisor", "crosvm", "kvmtool"},
+			},
+		},
+		{
+			name:    "multiboot",
+			content: append(make([]byte, 16), multibootHeader()...),
+			want:    Detection{Format: "multiboot", Monitors: []string{"qemu"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			d, err := Detect(writeTestBinary(t, tc.content))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, d)
+		})
+	}
+
+	t.Run("unsupported Solo5 target", func(t *testing.T) {
+		t.Parallel()
+		content := testELF{notes: []testNote{solo5ABINote(42)}, noteAlign: 8}.build()
+		_, err := Detect(writeTestBinary(t, content))
+		assert.ErrorIs(t, err, ErrUnknownBinary)
+	})
+
+	t.Run("truncated Solo5 ABI note", func(t *testing.T) {
+		t.Parallel()
+		note := testNote{name: solo5NoteName, noteType: solo5NoteABI, desc: []byte{1, 0}}
+		content := testELF{notes: []testNote{note}}.build()
+		_, err := Detect(writeTestBinary(t, content))
+		assert.ErrorIs(t, err, ErrUnknownBinary)
+	})
+
+	t.Run("unknown ELF", func(t *testing.T) {
+		t.Parallel()
+		_, err := Detect(writeTestBinary(t, testELF{sections: []string{".text"}}.build()))
+		assert.ErrorIs(t, err, ErrUnknownBinary)
+	})
+
+	t.Run("unknown binary", func(t *testing.T) {
+		t.Parallel()
+		_, err := Detect(writeTestBinary(t, []byte("#!/bin/sh\necho hello\n")))
+		assert.ErrorIs(t, err, ErrUnknownBinary)
+	})
+
+	t.Run("missing binary", func(t *testing.T) {
+		t.Parallel()
+		_, err := Detect(filepath.Join(t.TempDir(), "missing"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestHasMultibootHeader(t *testing.T) {
+	t.Run("multiboot", func(t *testing.T) {
+		t.Parallel()
+		header := append(make([]byte, 4096), multibootHeader()...)
+		assert.True(t, hasMultibootHeader(header))
+	})
+
+	t.Run("multiboot2", func(t *testing.T) {
+		t.Parallel()
+		header := append(make([]byte, 16384), multiboot2Header()...)
+		assert.True(t, hasMultibootHeader(header))
+	})
+
+	t.Run("invalid checksum", func(t *testing.T) {
+		t.Parallel()
+		header := multibootHeader()
+		header[8]++
+		assert.False(t, hasMultibootHeader(header))
+	})
+
+	t.Run("unaligned header", func(t *testing.T) {
+		t.Parallel()
+		header := append(make([]byte, 2), multibootHeader()...)
+		assert.False(t, hasMultibootHeader(header))
+	})
+
+	t.Run("multiboot header beyond the search limit", func(t *testing.T) {
+		t.Parallel()
+		header := append(make([]byte, multibootSearchLen), multibootHeader()...)
+		assert.False(t, hasMultibootHeader(header))
+	})
+
+	t.Run("truncated header", func(t *testing.T) {
+		t.Parallel()
+		assert.False(t, hasMultibootHeader(multibootHeader()[:8]))
+	})
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikernels
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testNote is an ELF note of a test binary
+type testNote struct {
+	name     string
+	noteType uint32
+	desc     []byte
+}
+
+// testELF describes a minimal little-endian ELF64 binary for the tests
+type testELF struct {
+	notes     []testNote
+	noteAlign uint64   // The alignment of the note section and segment
+	sections  []string // Empty sections besides the note section
+	stripped  bool     // Without section headers, as in stripped binaries
+	content   []byte   // Appended after the notes
+}
+
+// encodeNotes encodes notes, aligning names and descriptors to align
+func encodeNotes(notes []testNote, align uint64) []byte {
+	var buf bytes.Buffer
+	pad := func() {
+		for uint64(buf.Len())%align != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	for _, note := range notes {
+		name := append([]byte(note.name), 0)
+		_ = binary.Write(&buf, binary.LittleEndian, []uint32{
+			uint32(len(name)), uint32(len(note.desc)), note.noteType,
+		})
+		buf.Write(name)
+		pad()
+		buf.Write(note.desc)
+		pad()
+	}
+	return buf.Bytes()
+}
+
+// build lays out the ELF header, a PT_NOTE program header, the notes,
+// the content, the section names and the section headers.
+func (e testELF) build() []byte {
+	const (
+		ehdrSize = 64
+		phdrSize = 56
+		shdrSize = 64
+	)
+	align := e.noteAlign
+	if align == 0 {
+		align = 4
+	}
+	notes := encodeNotes(e.notes, align)
+	notesOff := uint64(ehdrSize + phdrSize)
+	contentOff := notesOff + uint64(len(notes))
+	contentOff = (contentOff + 7) &^ 7
+
+	var shstrtab bytes.Buffer
+	shstrtab.WriteByte(0)
+	addName := func(name string) uint32 {
+		off := uint32(shstrtab.Len())
+		shstrtab.WriteString(name)
+		shstrtab.WriteByte(0)
+		return off
+	}
+	shdrs := []elf.Section64{{}}
+	if len(e.notes) > 0 {
+		shdrs = append(shdrs, elf.Section64{
+			Name:      addName(".note.test"),
+			Type:      uint32(elf.SHT_NOTE),
+			Off:       notesOff,
+			Size:      uint64(len(notes)),
+			Addralign: align,
+		})
+	}
+	for _, name := range e.sections {
+		shdrs = append(shdrs, elf.Section64{
+			Name:      addName(name),
+			Type:      uint32(elf.SHT_PROGBITS),
+			Off:       contentOff,
+			Addralign: 1,
+		})
+	}
+	shstrndx := len(shdrs)
+	shdrs = append(shdrs, elf.Section64{
+		Name:      addName(".shstrtab"),
+		Type:      uint32(elf.SHT_STRTAB),
+		Addralign: 1,
+	})
+	shstrtabOff := contentOff + uint64(len(e.content))
+	shdrs[shstrndx].Off = shstrtabOff
+	shdrs[shstrndx].Size = uint64(shstrtab.Len())
+	shOff := (shstrtabOff + uint64(shstrtab.Len()) + 7) &^ 7
+
+	ehdr := elf.Header64{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     ehdrSize,
+		Ehsize:    ehdrSize,
+		Phentsize: phdrSize,
+		Phnum:     1,
+		Shentsize: shdrSize,
+	}
+	copy(ehdr.Ident[:], elf.ELFMAG)
+	ehdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	ehdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	ehdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	if !e.stripped {
+		ehdr.Shoff = shOff
+		ehdr.Shnum = uint16(len(shdrs))
+		ehdr.Shstrndx = uint16(shstrndx)
+	}
+	phdr := elf.Prog64{
+		Type:   uint32(elf.PT_NOTE),
+		Off:    notesOff,
+		Filesz: uint64(len(notes)),
+		Memsz:  uint64(len(notes)),
+		Align:  align,
+	}
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, ehdr)
+	_ = binary.Write(&buf, binary.LittleEndian, phdr)
+	buf.Write(notes)
+	buf.Write(make([]byte, contentOff-uint64(buf.Len())))
+	buf.Write(e.content)
+	if e.stripped {
+		return buf.Bytes()
+	}
+	buf.Write(shstrtab.Bytes())
+	buf.Write(make([]byte, shOff-uint64(buf.Len())))
+	_ = binary.Write(&buf, binary.LittleEndian, shdrs)
+	return buf.Bytes()
+}
+
+func writeTestBinary(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "unikernel")
+	assert.NoError(t, os.WriteFile(path, content, 0o644))
+	return path
+}
+
+func solo5ABINote(target uint32) testNote {
+	desc := make([]byte, 8)
+	binary.LittleEndian.PutUint32(desc, target)
+	return testNote{name: solo5NoteName, noteType: solo5NoteABI, desc: desc}
+}
+
+func TestParseELFNotes(t *testing.T) {
+	notes := []testNote{
+		{name: "Solo5", noteType: solo5NoteABI, desc: []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "Linux", noteType: 1, desc: []byte("6.1\x00")},
+	}
+
+	t.Run("4-byte alignment", func(t *testing.T) {
+		t.Parallel()
+		parsed := parseELFNotes(encodeNotes(notes, 4), 4, binary.LittleEndian)
+		assert.Equal(t, []elfNote{
+			{name: "Solo5", noteType: solo5NoteABI, desc: notes[0].desc},
+			{name: "Linux", noteType: 1, desc: notes[1].desc},
+		}, parsed)
+	})
+
+	t.Run("8-byte alignment", func(t *testing.T) {
+		t.Parallel()
+		// The name of the first note ends at 18 bytes, so its descriptor
+		// starts at 24 and not at 20
+		parsed := parseELFNotes(encodeNotes(notes, 8), 8, binary.LittleEndian)
+		assert.Equal(t, []elfNote{
+			{name: "Solo5", noteType: solo5NoteABI, desc: notes[0].desc},
+			{name: "Linux", noteType: 1, desc: notes[1].desc},
+		}, parsed)
+	})
+
+	t.Run("alignment below 4 bytes", func(t *testing.T) {
+		t.Parallel()
+		parsed := parseELFNotes(encodeNotes(notes, 4), 1, binary.LittleEndian)
+		assert.Len(t, parsed, 2)
+	})
+
+	t.Run("truncated note", func(t *testing.T) {
+		t.Parallel()
+		data := encodeNotes(notes, 4)
+		parsed := parseELFNotes(data[:len(data)-2], 4, binary.LittleEndian)
+		assert.Equal(t, []elfNote{
+			{name: "Solo5", noteType: solo5NoteABI, desc: notes[0].desc},
+		}, parsed)
+	})
+
+	t.Run("truncated header", func(t *testing.T) {
+		t.Parallel()
+		assert.Empty(t, parseELFNotes(encodeNotes(notes, 4)[:8], 4, binary.LittleEndian))
+	})
+
+	t.Run("oversized descriptor", func(t *testing.T) {
+		t.Parallel()
+		data := encodeNotes(notes[:1], 4)
+		binary.LittleEndian.PutUint32(data[4:], 0xffffffff)
+		assert.Empty(t, parseELFNotes(data, 4, binary.LittleEndian))
+	})
+}
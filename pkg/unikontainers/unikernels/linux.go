@@ -36,24 +36,31 @@ const (
 	lpcEndMarker     string = "UCE" // Linux process config end marker
 	blkStartMarker   string = "UBS" // Block-based mounts start marker
 	blkEndMarker     string = "UBE" // Block-based mounts end marker
+	netStartMarker   string = "UNS" // Secondary network interfaces start marker
+	netEndMarker     string = "UNE" // Secondary network interfaces end marker
 )
 
 type Linux struct {
 	App        string
 	Command    string
 	Monitor    string
+	Machine    string
 	Env        []string
 	Net        LinuxNet
+	Secondary  []LinuxNet // The rest of the network interfaces, which urunit configures
 	Blk        []types.BlockDevParams
 	RootFsType string
 	InitrdConf bool
+	GuestExit  bool // urunit reports the exit of the guest through isa-debug-exit
 	ProcConfig types.ProcessConfig
 }
 
 type LinuxNet struct {
-	Address string
-	Gateway string
-	Mask    string
+	Address   string
+	Gateway   string
+	Mask      string
+	Interface string // The name of the interface in the guest
+	MAC       string
 }
 
 func IsIPInSubnet(ln LinuxNet) bool {
@@ -82,6 +89,11 @@ func (l *Linux) CommandString() (string, error) {
 	}
 	bootParams += " " + consoleStr
 
+	if l.Machine == microvmMachine {
+		// There is neither PCI nor a keyboard controller to reset the VM
+		bootParams += " reboot=t pci=off " + microvmDeviceParams()
+	}
+
 	switch l.RootFsType {
 	case "block":
 		rootParams := "root=/dev/vda rw"
@@ -129,25 +141,22 @@ func (l *Linux) CommandString() (string, error) {
 	return bootParams, nil
 }
 
-func (l *Linux) SupportsBlock() bool {
-	return true
+func (l *Linux) Capabilities() types.UnikernelCapabilities {
+	return types.UnikernelCapabilities{
+		Block:    true,
+		BlockFS:  []string{"ext2", "ext3", "ext4"},
+		NinePfs:  true,
+		Virtiofs: true,
+		Vsock:    true,
+		MultiNIC: true,
+		Microvm:  true,
+		Archs:    commonArchs,
+	}
 }
 
-func (l *Linux) SupportsFS(fsType string) bool {
-	switch fsType {
-	case "ext2":
-		return true
-	case "ext3":
-		return true
-	case "ext4":
-		return true
-	case "9pfs":
-		return true
-	case "virtiofs":
-		return true
-	default:
-		return false
-	}
+// CrashPatterns returns the pattern of a kernel panic.
+func (l *Linux) CrashPatterns() []string {
+	return []string{`Kernel panic - not syncing`}
 }
 
 func (l *Linux) MonitorNetCli(_ string, _ string) string {
@@ -160,12 +169,11 @@ func (l *Linux) MonitorBlockCli() []types.MonitorBlockArgs {
 	}
 	blkArgs := make([]types.MonitorBlockArgs, 0, len(l.Blk))
 	switch l.Monitor {
-	case "qemu":
+	case "qemu", "crosvm", "kvmtool":
 		for _, aBlock := range l.Blk {
-			bcli1 := fmt.Sprintf(" -device virtio-blk-pci,serial=%s,drive=%s", aBlock.ID, aBlock.ID)
-			bcli2 := fmt.Sprintf(" -drive format=raw,if=none,id=%s,file=%s", aBlock.ID, aBlock.Source)
 			blkArgs = append(blkArgs, types.MonitorBlockArgs{
-				ExactArgs: bcli1 + bcli2,
+				ID:   aBlock.ID,
+				Path: aBlock.Source,
 			})
 		}
 	case "firecracker":
@@ -192,11 +200,14 @@ func (l *Linux) MonitorCli() types.MonitorCliArgs {
 		extraCliArgs := types.MonitorCliArgs{
 			OtherArgs: " -no-reboot -nodefaults",
 		}
+		if l.reportsExit() {
+			extraCliArgs.OtherArgs += qemuExitDevices
+		}
 		if l.InitrdConf && l.RootFsType != "initrd" {
 			extraCliArgs.ExtraInitrd = urunitConfPath
 		}
 		return extraCliArgs
-	case "firecracker":
+	case "firecracker", "crosvm", "kvmtool":
 		if l.InitrdConf && l.RootFsType != "initrd" {
 			return types.MonitorCliArgs{
 				ExtraInitrd: urunitConfPath,
@@ -208,17 +219,39 @@ func (l *Linux) MonitorCli() types.MonitorCliArgs {
 	}
 }
 
+// ExitMapper maps the exit status of Qemu on amd64, where urunit reports
+// the exit code of the application through isa-debug-exit and the kernel
+// reports panics through pvpanic. The exit status of the other monitors
+// does not reveal the exit of the guest and it is kept as is.
+func (l *Linux) ExitMapper() types.ExitMapper {
+	if l.reportsExit() {
+		return qemuGuestExit
+	}
+	return nil
+}
+
+// reportsExit returns true if the guest reports its exit through the
+// devices of qemuExitDevices. Only urunit writes the exit code of the
+// application to isa-debug-exit. urunit is not part of urunc and since
+// urunc can not tell the version of urunit in the image, the guest_exit
+// option of the monitor must enable it.
+func (l *Linux) reportsExit() bool {
+	return l.GuestExit && l.InitrdConf && l.Monitor == "qemu" && runtime.GOARCH == "amd64"
+}
+
 func (l *Linux) Init(data types.UnikernelParams) error {
 	err := l.parseCmdLine(data.CmdLine)
 	if err != nil {
 		return err
 	}
 
-	l.configureNetwork(data.Net)
+	l.configureNetwork(data.Nets)
 	l.Blk = data.Block
 	l.RootFsType = data.Rootfs.Type
 	l.Env = data.EnvVars
 	l.Monitor = data.Monitor
+	l.Machine = data.Machine
+	l.GuestExit = data.GuestExit
 	l.ProcConfig = data.ProcConf
 
 	// if the application contains urunit, then we assume
@@ -264,11 +297,25 @@ func (l *Linux) parseCmdLine(cmdLine []string) error {
 	return nil
 }
 
-// configureNetwork sets up network parameters.
-func (l *Linux) configureNetwork(net types.NetDevParams) {
-	l.Net.Address = net.IP
-	l.Net.Gateway = net.Gateway
-	l.Net.Mask = net.Mask
+// configureNetwork sets up network parameters. The guest names its
+// interfaces in the order that the monitor attaches them.
+func (l *Linux) configureNetwork(nets []types.NetDevParams) {
+	l.Net = LinuxNet{}
+	l.Secondary = nil
+	for i, nic := range nets {
+		linuxNet := LinuxNet{
+			Address:   nic.IP,
+			Gateway:   nic.Gateway,
+			Mask:      nic.Mask,
+			Interface: fmt.Sprintf("eth%d", i),
+			MAC:       nic.MAC,
+		}
+		if i == 0 {
+			l.Net = linuxNet
+		} else {
+			l.Secondary = append(l.Secondary, linuxNet)
+		}
+	}
 }
 
 // setupUrunitConfig creates the urunit configuration file with environment variables.
@@ -334,6 +381,28 @@ func (l *Linux) buildUrunitConfig() string {
 	}
 	sb.WriteString(blkEndMarker)
 	sb.WriteString("\n")
+	// Older versions of urunit do not know about the section of the
+	// network interfaces, hence it is present only when it is needed
+	if len(l.Secondary) > 0 {
+		sb.WriteString(netStartMarker)
+		sb.WriteString("\n")
+		for _, n := range l.Secondary {
+			sb.WriteString("IF:")
+			sb.WriteString(n.Interface)
+			sb.WriteString("\n")
+			sb.WriteString("MAC:")
+			sb.WriteString(n.MAC)
+			sb.WriteString("\n")
+			sb.WriteString("IP:")
+			sb.WriteString(n.Address)
+			sb.WriteString("\n")
+			sb.WriteString("MASK:")
+			sb.WriteString(n.Mask)
+			sb.WriteString("\n")
+		}
+		sb.WriteString(netEndMarker)
+		sb.WriteString("\n")
+	}
 	return sb.String()
 }
 
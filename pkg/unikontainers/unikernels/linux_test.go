@@ -0,0 +1,80 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikernels
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+func TestQemuGuestExit(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   types.GuestExit
+	}{
+		{"clean shutdown", 0, types.GuestExit{Code: 0}},
+		{"panic", 1, types.GuestExit{Code: types.GuestPanicExitCode, Panic: true}},
+		{"other error of Qemu", 2, types.GuestExit{Code: 2}},
+		{"zero exit code", 3, types.GuestExit{Code: 0}},
+		{"exit code one", 5, types.GuestExit{Code: 1}},
+		{"non-zero exit code", (42+1)<<1 | 1, types.GuestExit{Code: 42}},
+		{"largest exit code", (126+1)<<1 | 1, types.GuestExit{Code: 126}},
+		{"even status", 4, types.GuestExit{Code: 4}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, qemuGuestExit(tc.status))
+		})
+	}
+}
+
+func TestLinuxExitMapper(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("Qemu reports the exit of the guest only on amd64")
+	}
+
+	t.Run("enabled for urunit on Qemu", func(t *testing.T) {
+		t.Parallel()
+		l := &Linux{Monitor: "qemu", InitrdConf: true, GuestExit: true}
+		assert.NotNil(t, l.ExitMapper())
+		assert.True(t, strings.HasSuffix(l.MonitorCli().OtherArgs, qemuExitDevices))
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		t.Parallel()
+		l := &Linux{Monitor: "qemu", InitrdConf: true}
+		assert.Nil(t, l.ExitMapper())
+		assert.NotContains(t, l.MonitorCli().OtherArgs, "isa-debug-exit")
+	})
+
+	t.Run("disabled without urunit", func(t *testing.T) {
+		t.Parallel()
+		l := &Linux{Monitor: "qemu", GuestExit: true}
+		assert.Nil(t, l.ExitMapper())
+		assert.NotContains(t, l.MonitorCli().OtherArgs, "isa-debug-exit")
+	})
+
+	t.Run("other monitor", func(t *testing.T) {
+		t.Parallel()
+		l := &Linux{Monitor: "firecracker", InitrdConf: true, GuestExit: true}
+		assert.Nil(t, l.ExitMapper())
+	})
+}
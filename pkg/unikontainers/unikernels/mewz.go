@@ -40,12 +40,11 @@ func (m *Mewz) CommandString() (string, error) {
 		m.Net.Gateway), nil
 }
 
-func (m *Mewz) SupportsBlock() bool {
-	return false
-}
-
-func (m *Mewz) SupportsFS(_ string) bool {
-	return false
+// Mewz supports only networking and only on x86_64
+func (m *Mewz) Capabilities() types.UnikernelCapabilities {
+	return types.UnikernelCapabilities{
+		Archs: []string{"amd64"},
+	}
 }
 
 func (m *Mewz) MonitorNetCli(ifName string, mac string) string {
@@ -78,9 +77,9 @@ func (m *Mewz) MonitorCli() types.MonitorCliArgs {
 
 func (m *Mewz) Init(data types.UnikernelParams) error {
 	var mask int
-	if data.Net.Mask != "" {
+	if data.Net().Mask != "" {
 		var err error
-		mask, err = subnetMaskToCIDR(data.Net.Mask)
+		mask, err = subnetMaskToCIDR(data.Net().Mask)
 		if err != nil {
 			return err
 		}
@@ -89,8 +88,8 @@ func (m *Mewz) Init(data types.UnikernelParams) error {
 	}
 	m.Command = strings.Join(data.CmdLine, " ")
 	m.Monitor = data.Monitor
-	m.Net.Address = data.Net.IP
-	m.Net.Gateway = data.Net.Gateway
+	m.Net.Address = data.Net().IP
+	m.Net.Gateway = data.Net().Gateway
 	m.Net.Mask = mask
 
 	return nil
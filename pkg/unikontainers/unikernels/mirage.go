@@ -16,6 +16,7 @@ package unikernels
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
@@ -26,39 +27,62 @@ const MirageUnikernel string = "mirage"
 type Mirage struct {
 	Command string
 	Monitor string
-	Net     MirageNet
+	Nets    []MirageNet
 	Block   []MirageBlock
 }
 
 type MirageNet struct {
 	Address string
 	Gateway string
+	Name    string // The name of the device in the Solo5 manifest
+	TapDev  string // The tap device of the host
 }
 
 type MirageBlock struct {
 	ID       string
 	HostPath string
+	Name     string // The name of the device in the Solo5 manifest
 }
 
 func (m *Mirage) CommandString() (string, error) {
-	return fmt.Sprintf("%s %s %s", m.Net.Address,
-		m.Net.Gateway,
-		m.Command), nil
+	args := make([]string, 0, 2*len(m.Nets)+1)
+	for _, n := range m.Nets {
+		args = append(args, n.Address)
+		if n.Gateway != "" {
+			args = append(args, n.Gateway)
+		}
+	}
+	args = append(args, m.Command)
+	return strings.Join(args, " "), nil
 }
 
-func (m *Mirage) SupportsBlock() bool {
-	return true
+// Mirage can access block devices, but it does not mount any filesystem
+func (m *Mirage) Capabilities() types.UnikernelCapabilities {
+	return types.UnikernelCapabilities{
+		Block:    true,
+		MultiNIC: true,
+		Archs:    commonArchs,
+	}
 }
 
-func (m *Mirage) SupportsFS(_ string) bool {
-	return false
+// CrashPatterns returns the patterns of an abort of Solo5, which MirageOS
+// uses as its platform.
+func (m *Mirage) CrashPatterns() []string {
+	return solo5CrashPatterns
 }
 
+// MonitorNetCli attaches the tap device to the device of the Solo5 manifest
+// that it got assigned to. The tap devices without a device of the
+// manifest do not get attached.
 func (m *Mirage) MonitorNetCli(ifName string, mac string) string {
 	switch m.Monitor {
 	case "hvt", "spt":
-		netOption := "--net:service=" + ifName
-		netOption += " --net-mac:service=" + mac
+		i := slices.IndexFunc(m.Nets, func(n MirageNet) bool { return n.TapDev == ifName })
+		if i < 0 {
+			return ""
+		}
+		netOption := "--net:" + m.Nets[i].Name + "=" + ifName
+		netOption += " --net-mac:" + m.Nets[i].Name + "=" + mac
 		return netOption
 	default:
 		return ""
@@ -71,22 +95,16 @@ func (m *Mirage) MonitorBlockCli() []types.MonitorBlockArgs {
 	}
 	switch m.Monitor {
 	case "hvt", "spt":
-		// TODO: Explore options for multiple block devices in MirageOS
-		// over Solo5-spt and Solo5-hvt. Solo5 expects to use as an ID
-		// a specific name which the guest is also aware of in order to
-		// attach the respective block. As a result, urunc needs to know
-		// the correct ID to set, which is not straightforward. Therefore,
-		// there are two options. Either we read the Solo5 manifest or,
-		// we require specific IDs. Till we decide about that, we will
-		// use a single block device. We also need to find some use cases
-		// where multiple block devices are configured in MirageOS and check
-		// how MirageOS handles/configures them.
-		return []types.MonitorBlockArgs{
-			{
-				ID:   "storage",
-				Path: m.Block[0].HostPath,
-			},
+		// Solo5 attaches each block device using the name that the
+		// guest declares in its manifest
+		blkArgs := make([]types.MonitorBlockArgs, 0, len(m.Block))
+		for _, blk := range m.Block {
+			blkArgs = append(blkArgs, types.MonitorBlockArgs{
+				ID:   blk.Name,
+				Path: blk.HostPath,
+			})
 		}
+		return blkArgs
 	default:
 		return nil
 	}
@@ -98,15 +116,57 @@ func (m *Mirage) MonitorCli() types.MonitorCliArgs {
 
 func (m *Mirage) Init(data types.UnikernelParams) error {
 	// if Mask is empty, there is no network support
-	if data.Net.Mask != "" {
-		m.Net.Address = "--ipv4=" + data.Net.IP + "/24"
-		m.Net.Gateway = "--ipv4-gateway=" + data.Net.Gateway
+	var nets []types.NetDevParams
+	if data.Net().Mask != "" {
+		nets = data.Nets
 	}
-	m.Block = make([]MirageBlock, 0, len(data.Block))
+	blockIDs := make([]string, 0, len(data.Block))
 	for _, blk := range data.Block {
+		blockIDs = append(blockIDs, blk.ID)
+	}
+	names, err := solo5DeviceNames(data, len(nets), blockIDs)
+	if err != nil {
+		return err
+	}
+	// Without the Solo5 manifest, only the default devices are known
+	netNames := []string{"service"}
+	blockNames := []string{"storage"}
+	if names != nil {
+		netNames = names.nets
+		blockNames = names.blocks
+	}
+	m.Nets = make([]MirageNet, 0, len(nets))
+	for i, nic := range nets {
+		if i == len(netNames) {
+			break
+		}
+		newNet := MirageNet{
+			Name:   netNames[i],
+			TapDev: nic.TapDev,
+		}
+		mask, err := subnetMaskToCIDR(nic.Mask)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			newNet.Address = fmt.Sprintf("--ipv4=%s/%d", nic.IP, mask)
+			newNet.Gateway = "--ipv4-gateway=" + nic.Gateway
+		} else {
+			// The stack of any other device gets configured by the
+			// arguments with the name of the device as a prefix
+			newNet.Address = fmt.Sprintf("--%s-ipv4=%s/%d", netNames[i], nic.IP, mask)
+		}
+		m.Nets = append(m.Nets, newNet)
+	}
+	m.Block = make([]MirageBlock, 0, len(blockNames))
+	for i, blk := range data.Block {
+		if i == len(blockNames) {
+			break
+		}
 		newBlk := MirageBlock{
 			ID:       blk.ID,
 			HostPath: blk.Source,
+			Name:     blockNames[i],
 		}
 		m.Block = append(m.Block, newBlk)
 	}
@@ -49,6 +49,7 @@ type RumprunNet struct {
 	Address   string `json:"addr"`
 	Mask      string `json:"mask"`
 	Gateway   string `json:"gw"`
+	Name      string `json:"-"` // The name of the device in the Solo5 manifest
 }
 
 type RumprunBlk struct {
@@ -57,6 +58,7 @@ type RumprunBlk struct {
 	Path       string `json:"path"`
 	FsType     string `json:"fstype"`
 	Mountpoint string `json:"mountpoint"`
+	Name       string `json:"-"` // The name of the device in the Solo5 manifest
 }
 
 func (r *Rumprun) CommandString() (string, error) {
@@ -124,24 +126,25 @@ func (r *Rumprun) CommandString() (string, error) {
 	return finalJSONString, nil
 }
 
-func (r *Rumprun) SupportsBlock() bool {
-	return true
+func (r *Rumprun) Capabilities() types.UnikernelCapabilities {
+	return types.UnikernelCapabilities{
+		Block:   true,
+		BlockFS: []string{"ext2"},
+		Archs:   commonArchs,
+	}
 }
 
-func (r *Rumprun) SupportsFS(fsType string) bool {
-	switch fsType {
-	case "ext2":
-		return true
-	default:
-		return false
-	}
+// CrashPatterns returns the patterns of an abort of Solo5, which Rumprun
+// uses as its platform.
+func (r *Rumprun) CrashPatterns() []string {
+	return solo5CrashPatterns
 }
 
 func (r *Rumprun) MonitorNetCli(ifName string, mac string) string {
 	switch r.Monitor {
 	case "hvt", "spt":
-		netOption := "--net:tap=" + ifName
-		netOption += " --net-mac:tap=" + mac
+		netOption := "--net:" + r.Net.Name + "=" + ifName
+		netOption += " --net-mac:" + r.Net.Name + "=" + mac
 		return netOption
 	default:
 		return ""
@@ -151,17 +154,10 @@ func (r *Rumprun) MonitorNetCli(ifName string, mac string) string {
 func (r *Rumprun) MonitorBlockCli() []types.MonitorBlockArgs {
 	switch r.Monitor {
 	case "hvt", "spt":
-		// TODO: Explore options for multiple block devices in Rumprun
-		// over Solo5-spt and Solo5-hvt. Solo5 expects to use as an ID
-		// a specific name which the guest is also aware of in order to
-		// attach the respective block. As a result, urunc needs to know
-		// the correct ID to set, which is not straightforward. Therefore,
-		// there are two options. Either we read the Solo5 manifest or,
-		// we require specific IDs. Till we decide about that, we will
-		// use a single block device only for the rootfs of Rumprun.
+		// Rumprun uses a single block device for its rootfs
 		return []types.MonitorBlockArgs{
 			{
-				ID:   "rootfs",
+				ID:   r.Blk.Name,
 				Path: r.Blk.HostPath,
 			},
 		}
@@ -178,7 +174,7 @@ func (r *Rumprun) MonitorCli() types.MonitorCliArgs {
 
 func (r *Rumprun) Init(data types.UnikernelParams) error {
 	// if Net.Mask is empty, there is no network support
-	if data.Net.Mask != "" {
+	if data.Net().Mask != "" {
 		// FIXME: in the case of rumprun & k8s, we need to identify
 		// the reason that networking is not working properly.
 		// One reason could be that the gw is in different subnet
@@ -194,9 +190,9 @@ func (r *Rumprun) Init(data types.UnikernelParams) error {
 		r.Net.Cloner = "True"
 		r.Net.Type = "inet"
 		r.Net.Method = "static"
-		r.Net.Address = data.Net.IP
+		r.Net.Address = data.Net().IP
 		r.Net.Mask = fmt.Sprintf("%d", mask)
-		r.Net.Gateway = data.Net.Gateway
+		r.Net.Gateway = data.Net().Gateway
 	} else {
 		// Set address to empty string so we can know that no network
 		// was specified.
@@ -215,6 +211,30 @@ func (r *Rumprun) Init(data types.UnikernelParams) error {
 		r.Blk.Source = ""
 	}
 
+	var blockIDs []string
+	if r.Blk.Source != "" {
+		blockIDs = []string{data.Block[0].ID}
+	}
+	nets := 0
+	if r.Net.Address != "" {
+		nets = 1
+	}
+	names, err := solo5DeviceNames(data, nets, blockIDs)
+	if err != nil {
+		return err
+	}
+	// Without the Solo5 manifest, only the default devices are known
+	r.Net.Name = "tap"
+	r.Blk.Name = "rootfs"
+	if names != nil {
+		if len(names.nets) > 0 {
+			r.Net.Name = names.nets[0]
+		}
+		if len(names.blocks) > 0 {
+			r.Blk.Name = names.blocks[0]
+		}
+	}
+
 	r.Command = strings.Join(data.CmdLine, " ")
 	r.Monitor = data.Monitor
 	r.Envs = data.EnvVars
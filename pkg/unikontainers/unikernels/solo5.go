@@ -0,0 +1,244 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikernels
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+const (
+	// The ELF notes of Solo5 binaries, as defined in Solo5's
+	// include/elf_abi.h and include/mft_abi.h. The manifest declares the
+	// devices of the guest and the ABI note the target monitor.
+	solo5NoteName        = "Solo5"
+	solo5NoteManifest    = 0x3154464d // "MFT1"
+	solo5NoteABI         = 0x31494241 // "ABI1"
+	solo5ManifestVersion = 1
+	solo5NameSize        = 68
+	solo5MaxEntries      = 64
+	// Entries of this type and above are reserved for Solo5 itself
+	solo5ReservedFirst = 1 << 30
+)
+
+// Solo5DeviceType is the type of a device in a Solo5 manifest
+type Solo5DeviceType uint32
+
+const (
+	Solo5BlockDevice Solo5DeviceType = 1
+	Solo5NetDevice   Solo5DeviceType = 2
+)
+
+func (t Solo5DeviceType) String() string {
+	switch t {
+	case Solo5BlockDevice:
+		return "block"
+	case Solo5NetDevice:
+		return "net"
+	default:
+		return fmt.Sprintf("unknown (%d)", uint32(t))
+	}
+}
+
+// solo5CrashPatterns match the messages of the Solo5 bindings, when the
+// guest aborts (e.g. a failed assertion or a fatal trap)
+var solo5CrashPatterns = []string{
+	`Solo5: ABORT: `,
+	`Solo5: solo5_abort\(\) called`,
+}
+
+var (
+	ErrNoSolo5Manifest      = errors.New("no Solo5 manifest found")
+	ErrInvalidSolo5Manifest = errors.New("invalid Solo5 manifest")
+	ErrSolo5Devices         = errors.New("devices do not match the Solo5 manifest")
+)
+
+// Solo5Device is a device that a Solo5 guest declares in its manifest.
+// The monitor must attach it using its name.
+type Solo5Device struct {
+	Name string
+	Type Solo5DeviceType
+}
+
+// Solo5Manifest holds the devices that a Solo5 guest declares. Solo5
+// monitors refuse to boot the guest, unless all the devices of the
+// manifest get attached.
+type Solo5Manifest struct {
+	Devices []Solo5Device
+}
+
+// ReadSolo5Manifest reads the manifest from the ELF note of a Solo5
+// binary.
+func ReadSolo5Manifest(path string) (*Solo5Manifest, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	desc, err := findELFNote(f, solo5NoteName, solo5NoteManifest)
+	if errors.Is(err, errNoteNotFound) {
+		return nil, ErrNoSolo5Manifest
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSolo5Manifest, err)
+	}
+	return parseSolo5Manifest(desc, f.ByteOrder)
+}
+
+// parseSolo5Manifest parses the descriptor of the manifest note, which
+// is a struct mft. Only the name and the type of each entry are used,
+// while the size of the entries is derived from the size of the note.
+func parseSolo5Manifest(desc []byte, order binary.ByteOrder) (*Solo5Manifest, error) {
+	const headerSize = 8
+	if len(desc) < headerSize {
+		return nil, fmt.Errorf("%w: truncated manifest", ErrInvalidSolo5Manifest)
+	}
+	version := order.Uint32(desc)
+	if version != solo5ManifestVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSolo5Manifest, version)
+	}
+	entries := uint64(order.Uint32(desc[4:]))
+	if entries == 0 || entries > solo5MaxEntries {
+		return nil, fmt.Errorf("%w: %d entries", ErrInvalidSolo5Manifest, entries)
+	}
+	entrySize := (uint64(len(desc)) - headerSize) / entries
+	if entrySize < solo5NameSize+4 {
+		return nil, fmt.Errorf("%w: truncated entries", ErrInvalidSolo5Manifest)
+	}
+
+	manifest := &Solo5Manifest{}
+	for i := uint64(0); i < entries; i++ {
+		entry := desc[headerSize+i*entrySize:]
+		name, _, _ := bytes.Cut(entry[:solo5NameSize], []byte{0})
+		devType := order.Uint32(entry[solo5NameSize:])
+		if devType >= solo5ReservedFirst {
+			continue
+		}
+		manifest.Devices = append(manifest.Devices, Solo5Device{
+			Name: string(name),
+			Type: Solo5DeviceType(devType),
+		})
+	}
+	return manifest, nil
+}
+
+// DeviceNames returns the names of the devices of the given type, in the
+// order of the manifest.
+func (m *Solo5Manifest) DeviceNames(devType Solo5DeviceType) []string {
+	var names []string
+	for _, dev := range m.Devices {
+		if dev.Type == devType {
+			names = append(names, dev.Name)
+		}
+	}
+	return names
+}
+
+// CheckDevices checks that the given number of net devices and block
+// devices matches the devices that the manifest declares, since Solo5
+// monitors require all of them to get attached.
+func (m *Solo5Manifest) CheckDevices(nets int, blocks int) error {
+	for _, dev := range []struct {
+		devType Solo5DeviceType
+		count   int
+	}{
+		{Solo5NetDevice, nets},
+		{Solo5BlockDevice, blocks},
+	} {
+		names := m.DeviceNames(dev.devType)
+		if len(names) == dev.count {
+			continue
+		}
+		declared := "no " + dev.devType.String() + " devices"
+		if len(names) > 0 {
+			declared = dev.devType.String() + " devices " + strings.Join(names, ", ")
+		}
+		return fmt.Errorf("%w: the unikernel declares %s, but %d %s devices would be attached",
+			ErrSolo5Devices, declared, dev.count, dev.devType)
+	}
+	return nil
+}
+
+// BlockNames assigns a block device of the manifest to each of the given
+// block IDs. An ID that matches the name of a device gets that device,
+// while the rest of the IDs get the remaining devices in the order of the
+// manifest. It returns an error if the IDs are more than the devices.
+func (m *Solo5Manifest) BlockNames(ids []string) ([]string, error) {
+	available := m.DeviceNames(Solo5BlockDevice)
+	if len(ids) > len(available) {
+		return nil, m.CheckDevices(len(m.DeviceNames(Solo5NetDevice)), len(ids))
+	}
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		if j := slices.Index(available, id); j >= 0 {
+			names[i] = id
+			available = slices.Delete(available, j, j+1)
+		}
+	}
+	for i := range names {
+		if names[i] == "" {
+			names[i] = available[0]
+			available = available[1:]
+		}
+	}
+	return names, nil
+}
+
+// solo5Names holds the names of the devices of a Solo5 guest that urunc
+// attaches
+type solo5Names struct {
+	nets   []string
+	blocks []string
+}
+
+// solo5DeviceNames reads the manifest of a Solo5 guest, checks that it
+// declares the given number of net devices and the block devices with
+// the given IDs and returns their names. If the manifest declares fewer
+// net devices, only the first ones get attached. It returns nil, if the
+// guest does not execute on top of a Solo5 monitor or its binary is
+// unknown.
+func solo5DeviceNames(data types.UnikernelParams, nets int, blockIDs []string) (*solo5Names, error) {
+	if (data.Monitor != "hvt" && data.Monitor != "spt") || data.BinaryPath == "" {
+		return nil, nil
+	}
+	manifest, err := ReadSolo5Manifest(data.BinaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Solo5 manifest of %s: %w", data.BinaryPath, err)
+	}
+	netNames := manifest.DeviceNames(Solo5NetDevice)
+	if len(netNames) > 0 && nets > len(netNames) {
+		nets = len(netNames)
+	}
+	err = manifest.CheckDevices(nets, len(blockIDs))
+	if err != nil {
+		return nil, err
+	}
+	names := &solo5Names{
+		nets: netNames[:nets],
+	}
+	names.blocks, err = manifest.BlockNames(blockIDs)
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikernels
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+// The size of a struct mft_entry of Solo5 on 64-bit targets
+const testMftEntrySize = 104
+
+// encodeManifest encodes a struct mft with the given entries. As in the
+// manifests that Solo5 generates, the first entry is reserved.
+func encodeManifest(devices ...Solo5Device) []byte {
+	entries := append([]Solo5Device{{Name: "", Type: solo5ReservedFirst}}, devices...)
+	desc := make([]byte, 8+len(entries)*testMftEntrySize)
+	binary.LittleEndian.PutUint32(desc, solo5ManifestVersion)
+	binary.LittleEndian.PutUint32(desc[4:], uint32(len(entries)))
+	for i, dev := range entries {
+		entry := desc[8+i*testMftEntrySize:]
+		copy(entry, dev.Name)
+		binary.LittleEndian.PutUint32(entry[solo5NameSize:], uint32(dev.Type))
+	}
+	return desc
+}
+
+func manifestNote(desc []byte) testNote {
+	return testNote{name: solo5NoteName, noteType: solo5NoteManifest, desc: desc}
+}
+
+func TestParseSolo5Manifest(t *testing.T) {
+	t.Run("devices without the reserved entries", func(t *testing.T) {
+		t.Parallel()
+		desc := encodeManifest(
+			Solo5Device{Name: "service", Type: Solo5NetDevice},
+			Solo5Device{Name: "storage", Type: Solo5BlockDevice},
+		)
+		manifest, err := parseSolo5Manifest(desc, binary.LittleEndian)
+		assert.NoError(t, err)
+		assert.Equal(t, []Solo5Device{
+			{Name: "service", Type: Solo5NetDevice},
+			{Name: "storage", Type: Solo5BlockDevice},
+		}, manifest.Devices)
+	})
+
+	t.Run("name of the maximum length", func(t *testing.T) {
+		t.Parallel()
+		// The name is NUL-terminated within its 68 bytes
+		name := strings.Repeat("a", solo5NameSize-1)
+		manifest, err := parseSolo5Manifest(encodeManifest(Solo5Device{Name: name, Type: Solo5NetDevice}), binary.LittleEndian)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{name}, manifest.DeviceNames(Solo5NetDevice))
+	})
+
+	t.Run("truncated header", func(t *testing.T) {
+		t.Parallel()
+		_, err := parseSolo5Manifest(encodeManifest()[:6], binary.LittleEndian)
+		assert.ErrorIs(t, err, ErrInvalidSolo5Manifest)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		t.Parallel()
+		desc := encodeManifest()
+		binary.LittleEndian.PutUint32(desc, 2)
+		_, err := parseSolo5Manifest(desc, binary.LittleEndian)
+		assert.ErrorIs(t, err, ErrInvalidSolo5Manifest)
+	})
+
+	t.Run("no entries", func(t *testing.T) {
+		t.Parallel()
+		desc := encodeManifest()
+		binary.LittleEndian.PutUint32(desc[4:], 0)
+		_, err := parseSolo5Manifest(desc, binary.LittleEndian)
+		assert.ErrorIs(t, err, ErrInvalidSolo5Manifest)
+	})
+
+	t.Run("too many entries", func(t *testing.T) {
+		t.Parallel()
+		desc := encodeManifest()
+		binary.LittleEndian.PutUint32(desc[4:], solo5MaxEntries+1)
+		_, err := parseSolo5Manifest(desc, binary.LittleEndian)
+		assert.ErrorIs(t, err, ErrInvalidSolo5Manifest)
+	})
+
+	t.Run("truncated entries", func(t *testing.T) {
+		t.Parallel()
+		desc := encodeManifest(Solo5Device{Name: "service", Type: Solo5NetDevice})
+		// Only the header and the name of the two entries fit
+		_, err := parseSolo5Manifest(desc[:8+2*solo5NameSize], binary.LittleEndian)
+		assert.ErrorIs(t, err, ErrInvalidSolo5Manifest)
+	})
+}
+
+func TestReadSolo5Manifest(t *testing.T) {
+	desc := encodeManifest(Solo5Device{Name: "service", Type: Solo5NetDevice})
+	want := &Solo5Manifest{Devices: []Solo5Device{{Name: "service", Type: Solo5NetDevice}}}
+
+	t.Run("8-byte aligned notes", func(t *testing.T) {
+		t.Parallel()
+		content := testELF{
+			notes:     []testNote{solo5ABINote(solo5TargetHvt), manifestNote(desc)},
+			noteAlign: 8,
+		}.build()
+		manifest, err := ReadSolo5Manifest(writeTestBinary(t, content))
+		assert.NoError(t, err)
+		assert.Equal(t, want, manifest)
+	})
+
+	t.Run("stripped section headers", func(t *testing.T) {
+		t.Parallel()
+		content := testELF{
+			notes:     []testNote{manifestNote(desc)},
+			noteAlign: 8,
+			stripped:  true,
+		}.build()
+		manifest, err := ReadSolo5Manifest(writeTestBinary(t, content))
+		assert.NoError(t, err)
+		assert.Equal(t, want, manifest)
+	})
+
+	t.Run("no manifest", func(t *testing.T) {
+		t.Parallel()
+		content := testELF{notes: []testNote{solo5ABINote(solo5TargetHvt)}, noteAlign: 8}.build()
+		_, err := ReadSolo5Manifest(writeTestBinary(t, content))
+		assert.ErrorIs(t, err, ErrNoSolo5Manifest)
+	})
+
+	t.Run("truncated manifest note", func(t *testing.T) {
+		t.Parallel()
+		content := testELF{notes: []testNote{manifestNote(desc)}, noteAlign: 8}.build()
+		// The descriptor size of the note, which follows the ELF header
+		// and the program header, exceeds the note section
+		binary.LittleEndian.PutUint32(content[64+56+4:], uint32(len(desc)+64))
+		_, err := ReadSolo5Manifest(writeTestBinary(t, content))
+		assert.ErrorIs(t, err, ErrNoSolo5Manifest)
+	})
+
+	t.Run("not an ELF binary", func(t *testing.T) {
+		t.Parallel()
+		_, err := ReadSolo5Manifest(writeTestBinary(t, []byte("not an ELF binary")))
+		assert.Error(t, err)
+	})
+}
+
+func TestSolo5Manifest(t *testing.T) {
+	manifest := &Solo5Manifest{Devices: []Solo5Device{
+		{Name: "service", Type: Solo5NetDevice},
+		{Name: "rootfs", Type: Solo5BlockDevice},
+		{Name: "data", Type: Solo5BlockDevice},
+	}}
+
+	t.Run("matching devices", func(t *testing.T) {
+		t.Parallel()
+		assert.NoError(t, manifest.CheckDevices(1, 2))
+	})
+
+	t.Run("missing devices", func(t *testing.T) {
+		t.Parallel()
+		err := manifest.CheckDevices(1, 1)
+		assert.ErrorIs(t, err, ErrSolo5Devices)
+		assert.ErrorContains(t, err, "block devices rootfs, data")
+	})
+
+	t.Run("block names by ID", func(t *testing.T) {
+		t.Parallel()
+		names, err := manifest.BlockNames([]string{"vol0", "rootfs"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"data", "rootfs"}, names)
+	})
+
+	t.Run("more block IDs than devices", func(t *testing.T) {
+		t.Parallel()
+		_, err := manifest.BlockNames([]string{"a", "b", "c"})
+		assert.ErrorIs(t, err, ErrSolo5Devices)
+	})
+}
+
+func TestSolo5DeviceNames(t *testing.T) {
+	desc := encodeManifest(
+		Solo5Device{Name: "service", Type: Solo5NetDevice},
+		Solo5Device{Name: "storage", Type: Solo5BlockDevice},
+	)
+
+	t.Run("Solo5 guest", func(t *testing.T) {
+		t.Parallel()
+		path := writeTestBinary(t, testELF{notes: []testNote{manifestNote(desc)}, noteAlign: 8}.build())
+		names, err := solo5DeviceNames(types.UnikernelParams{Monitor: "hvt", BinaryPath: path}, 2, []string{"vol"})
+		assert.NoError(t, err)
+		assert.Equal(t, &solo5Names{nets: []string{"service"}, blocks: []string{"storage"}}, names)
+	})
+
+	t.Run("other monitor", func(t *testing.T) {
+		t.Parallel()
+		names, err := solo5DeviceNames(types.UnikernelParams{Monitor: "qemu", BinaryPath: "/unikernel"}, 1, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, names)
+	})
+}
@@ -33,6 +33,7 @@ var ErrVersionParsing = errors.New("failed to parse provided version, using defa
 type Unikraft struct {
 	AppName string
 	Monitor string
+	Machine string
 	Command string
 	Env     []string
 	Net     UnikraftNet
@@ -41,9 +42,10 @@ type Unikraft struct {
 }
 
 type UnikraftNet struct {
-	Address string
-	Mask    string
-	Gateway string
+	Address   string
+	Mask      string
+	Gateway   string
+	Secondary []string // The IPv4 configuration (ip/cidr) of the rest of the network devices
 }
 
 type UnikraftVFS struct {
@@ -58,12 +60,20 @@ func (u *Unikraft) CommandString() (string, error) {
 		consoleStr = "console=ttyS0"
 	}
 
+	devicesStr := ""
+	if u.Machine == microvmMachine {
+		// The library parameters of Unikraft precede the application's
+		// arguments and hence Qemu can not append the devices by itself
+		devicesStr = microvmDeviceParams()
+	}
+
 	if len(u.Env) > 0 {
 		envVarString = "env.vars=[ " + strings.Join(u.Env, " ") + " ]"
 	}
 
-	return fmt.Sprintf("%s %s %s %s %s %s %s -- %s", u.AppName,
+	return fmt.Sprintf("%s %s %s %s %s %s %s %s -- %s", u.AppName,
 		consoleStr,
+		devicesStr,
 		envVarString,
 		u.Net.Address,
 		u.Net.Gateway,
@@ -72,17 +82,21 @@ func (u *Unikraft) CommandString() (string, error) {
 		u.Command), nil
 }
 
-func (u *Unikraft) SupportsBlock() bool {
-	return false
+func (u *Unikraft) Capabilities() types.UnikernelCapabilities {
+	return types.UnikernelCapabilities{
+		NinePfs:  true,
+		Vsock:    true,
+		MultiNIC: true,
+		Microvm:  true,
+		Archs:    commonArchs,
+	}
 }
 
-func (u *Unikraft) SupportsFS(fsType string) bool {
-	switch fsType {
-	case "9pfs":
-		return true
-	default:
-		return false
-	}
+// CrashPatterns returns the pattern of the critical messages of Unikraft,
+// which it prints right before it crashes (e.g. a failed assertion or an
+// unhandled trap).
+func (u *Unikraft) CrashPatterns() []string {
+	return []string{`\bCRIT: \[`}
 }
 
 // There is no need for any changes here yet.
@@ -105,12 +119,32 @@ func (u *Unikraft) Init(data types.UnikernelParams) error {
 	u.Version = data.Version
 	u.AppName = "Unikraft"
 	u.Monitor = data.Monitor
+	u.Machine = data.Machine
 	u.Command = strings.Join(data.CmdLine, " ")
 
-	return u.configureUnikraftArgs(data.Rootfs.Type, data.Net.IP, data.Net.Gateway, data.Net.Mask)
+	u.Net.Secondary = nil
+	for i := 1; i < len(data.Nets); i++ {
+		mask, err := subnetMaskToCIDR(data.Nets[i].Mask)
+		if err != nil {
+			return err
+		}
+		u.Net.Secondary = append(u.Net.Secondary, fmt.Sprintf("%s/%d", data.Nets[i].IP, mask))
+	}
+
+	net := data.Net()
+	return u.configureUnikraftArgs(data.Rootfs.Type, net.IP, net.Gateway, net.Mask)
 }
 
 func (u *Unikraft) configureUnikraftArgs(rootFsType, ethDeviceIP, ethDeviceGateway, ethDeviceMask string) error {
+	// Without a network, the mask is empty
+	prefix := 24
+	if ethDeviceMask != "" {
+		var err error
+		prefix, err = subnetMaskToCIDR(ethDeviceMask)
+		if err != nil {
+			return err
+		}
+	}
 	setCompatArgs := func() {
 		u.Net.Address = "netdev.ipv4_addr=" + ethDeviceIP
 		u.Net.Gateway = "netdev.ipv4_gw_addr=" + ethDeviceGateway
@@ -125,7 +159,14 @@ func (u *Unikraft) configureUnikraftArgs(rootFsType, ethDeviceIP, ethDeviceGatew
 	}
 
 	setCurrentArgs := func() {
-		u.Net.Address = "netdev.ip=" + ethDeviceIP + "/24:" + ethDeviceGateway + ":8.8.8.8"
+		ipConfig := fmt.Sprintf("%s/%d:%s:8.8.8.8", ethDeviceIP, prefix, ethDeviceGateway)
+		u.Net.Address = "netdev.ip=" + ipConfig
+		if len(u.Net.Secondary) > 0 {
+			// Each element of the array configures the network device
+			// with the same index
+			ipConfigs := append([]string{ipConfig}, u.Net.Secondary...)
+			u.Net.Address = "netdev.ip=[ " + strings.Join(ipConfigs, " ") + " ]"
+		}
 		switch rootFsType {
 		case "initrd":
 			// TODO: This needs better handling. We need to revisit this
@@ -19,8 +19,60 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+const (
+	// microvmMachine is the machine type of Qemu without PCI, where the
+	// guest finds the virtio devices through its cmdline
+	microvmMachine = "microvm"
+	// The virtio-mmio transports of Qemu's microvm machine, when ACPI and
+	// the second IOAPIC are disabled
+	microvmMMIOBase       = 0xfeb00000
+	microvmMMIOSize       = 512
+	microvmMMIOIRQBase    = 5
+	microvmMMIOTransports = 8
+	// qemuExitDevices are the devices of Qemu on amd64, through which the
+	// guest reports how it exited. The guest writes the exit code of its
+	// application plus one to the port of isa-debug-exit and the kernel
+	// reports a panic through pvpanic, which makes Qemu exit with 1.
+	qemuExitDevices = " -device isa-debug-exit,iobase=0x501,iosize=1 -device pvpanic -action panic=exit-failure"
 )
 
+// commonArchs are the architectures that most unikernels support
+var commonArchs = []string{"amd64", "arm64"}
+
+// microvmDeviceParams returns the kernel parameters that describe the
+// virtio-mmio transports of Qemu's microvm machine. Transports without a
+// device attached are ignored by the guest.
+func microvmDeviceParams() string {
+	params := make([]string, 0, microvmMMIOTransports)
+	for i := 0; i < microvmMMIOTransports; i++ {
+		params = append(params, fmt.Sprintf("virtio_mmio.device=%d@0x%x:%d",
+			microvmMMIOSize, microvmMMIOBase+i*microvmMMIOSize, microvmMMIOIRQBase+i))
+	}
+	return strings.Join(params, " ")
+}
+
+// qemuGuestExit maps the exit status of Qemu with qemuExitDevices to the
+// exit of the guest. When the guest writes code + 1 to isa-debug-exit,
+// Qemu exits with ((code + 1) << 1) | 1, which is always 3 or above. A
+// zero status means that the VM shut down (or rebooted, due to -no-reboot)
+// cleanly and a status of 1 means that the guest panicked. Qemu also exits
+// with 1 when it fails to start, which is reported as a panic too. Any
+// other status is an error of Qemu and is kept as is.
+func qemuGuestExit(status int) types.GuestExit {
+	switch {
+	case status == 1:
+		return types.GuestExit{Code: types.GuestPanicExitCode, Panic: true}
+	case status&1 == 1 && status >= 3:
+		return types.GuestExit{Code: status>>1 - 1}
+	default:
+		return types.GuestExit{Code: status}
+	}
+}
+
 func subnetMaskToCIDR(subnetMask string) (int, error) {
 	maskParts := strings.Split(subnetMask, ".")
 	if len(maskParts) != 4 {
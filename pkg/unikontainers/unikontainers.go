@@ -23,6 +23,7 @@ import (
 	"maps"
 	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -52,6 +53,7 @@ var uniklog = logrus.WithField("subsystem", "unikontainers")
 var ErrQueueProxy = errors.New("this a queue proxy container")
 var ErrNotUnikernel = errors.New("this is not a unikernel container")
 var ErrNotExistingNS = errors.New("the namespace does not exist")
+var ErrNoIDMappings = errors.New("user namespace requested without uid/gid mappings")
 
 // Unikontainer holds the data necessary to create, manage and delete unikernel containers
 type Unikontainer struct {
@@ -62,6 +64,8 @@ type Unikontainer struct {
 	UruncCfg *UruncConfig
 	Listener *net.UnixListener
 	Conn     *net.UnixConn
+	// The console logger, which reexec waits for, if it stays alive
+	consoleLogger *exec.Cmd
 }
 
 // New parses the bundle and creates a new Unikontainer object
@@ -83,6 +87,12 @@ func New(bundlePath string, containerID string, rootDir string, cfg *UruncConfig
 	}
 
 	config, err := GetUnikernelConfig(bundlePath, spec)
+	if err != nil && cfg.Detection.Enabled {
+		config, err = detectUnikernelConfig(bundlePath, spec, cfg.Detection, cfg.Monitors)
+		if err != nil && !errors.Is(err, ErrNoUnikernelBinary) {
+			uniklog.WithError(err).Warn("Failed to detect the unikernel configuration")
+		}
+	}
 	if err != nil {
 		return nil, ErrNotUnikernel
 	}
@@ -130,16 +140,36 @@ func Get(containerID string, rootDir string) (*Unikontainer, error) {
 	u.RootDir = rootDir
 	u.Spec = spec
 	u.UruncCfg = UruncConfigFromMap(state.Annotations)
+	u.setupRootlessSpec()
+	if u.recordTermination() {
+		err = u.saveContainerState()
+		if err != nil {
+			uniklog.WithError(err).Warn("failed to record the termination of the guest in the state")
+		}
+	}
 	return u, nil
 }
 
-// InitialSetup sets the Unikernel status as creating,
+// InitialSetup checks that the monitor and the guest support the requested
+// features, sets the Unikernel status as creating,
 // creates the Unikernel base directory and
 // saves the state.json file with the current Unikernel state
 func (u *Unikontainer) InitialSetup() error {
+	err := u.checkCapabilities()
+	if err != nil {
+		return err
+	}
 	u.State.Status = specs.StateCreating
 	// FIXME: should we really create this base dir
-	err := os.MkdirAll(u.BaseDir, 0o755)
+	err = os.MkdirAll(u.BaseDir, 0o755)
+	if err != nil {
+		return err
+	}
+	err = u.setupUserNSOwnership()
+	if err != nil {
+		return err
+	}
+	err = u.recordAccel()
 	if err != nil {
 		return err
 	}
@@ -164,21 +194,27 @@ func (u *Unikontainer) Create(pid int, pidFilePath string) error {
 }
 
 // SetRunningState sets the Unikernel status as running,
+// recording the monitor's control socket, if any, and its command.
 func (u *Unikontainer) SetRunningState() error {
 	u.State.Status = specs.StateRunning
+	u.recordMonitorSocket()
+	u.recordMonitorCmd()
+	u.recordTermination()
 	return u.saveContainerState()
 }
 
-func (u *Unikontainer) SetupNet() (types.NetDevParams, error) {
+// SetupNet creates the tap devices of the guest and returns their network
+// devices, starting with the one of the default route. The rest of the
+// interfaces of the container get mirrored only if multiNIC is set.
+func (u *Unikontainer) SetupNet(multiNIC bool) ([]types.NetDevParams, error) {
 	networkType := u.getNetworkType()
 	uniklog.WithField("network type", networkType).Debug("Retrieved network type")
-	netArgs := types.NetDevParams{}
 	netManager, err := network.NewNetworkManager(networkType)
 	if err != nil {
-		return netArgs, fmt.Errorf("failed to create network manager for %s type: %v", networkType, err)
+		return nil, fmt.Errorf("failed to create network manager for %s type: %v", networkType, err)
 	}
 
-	networkInfo, err := netManager.NetworkSetup(u.Spec.Process.User.UID, u.Spec.Process.User.GID)
+	networkInfo, err := netManager.NetworkSetup(u.State.ID, multiNIC, u.Spec.Process.User.UID, u.Spec.Process.User.GID)
 	if err != nil {
 		// TODO: Handle this case better. We do not need to show an error
 		// since there was no network in the container. Therefore, we
@@ -186,15 +222,19 @@ func (u *Unikontainer) SetupNet() (types.NetDevParams, error) {
 		// di not have any network.
 		uniklog.Errorf("Failed to setup network :%v. Possibly due to ctr", err)
 	}
-	// if network info is nil, we didn't find eth0, so we are running with ctr
-	if networkInfo != nil {
-		netArgs.TapDev = networkInfo.TapDevice
-		netArgs.IP = networkInfo.EthDevice.IP
-		netArgs.Mask = networkInfo.EthDevice.Mask
-		netArgs.Gateway = networkInfo.EthDevice.DefaultGateway
-		// The MAC address for the guest network device is the same as the
-		// virtual ethernet interface inside the namespace
-		netArgs.MAC = networkInfo.EthDevice.MAC
+	// if network info is empty, we didn't find eth0, so we are running with ctr
+	netArgs := make([]types.NetDevParams, 0, len(networkInfo))
+	for _, info := range networkInfo {
+		netArgs = append(netArgs, types.NetDevParams{
+			TapDev:  info.TapDevice,
+			IP:      info.EthDevice.IP,
+			Mask:    info.EthDevice.Mask,
+			Gateway: info.EthDevice.DefaultGateway,
+			// The MAC address for the guest network device is the same as the
+			// virtual ethernet interface inside the namespace, unless the
+			// guest shares the namespace through the bridge network
+			MAC: info.EthDevice.MAC,
+		})
 	}
 
 	return netArgs, nil
@@ -264,6 +304,16 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 		Environment:   os.Environ(),
 	}
 
+	// ExecArgs
+	// The control socket gets created in the root of the monitor's rootfs
+	if hypervisors.HasMonitorSocket(hypervisors.VmmType(vmmType), u.UruncCfg.Monitors[vmmType]) {
+		vmmArgs.MonitorSocket = "/" + hypervisors.MonitorSocketName
+	}
+
+	// ExecArgs
+	vmmArgs.Machine = u.machine(vmmType)
+	vmmArgs.Accel = u.Accel()
+
 	// ExecArgs
 	// If memory limit is set in spec, use it instead of the config default value
 	if u.Spec.Linux.Resources.Memory != nil {
@@ -274,12 +324,34 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 		}
 	}
 
+	// ExecArgs
+	// If hugepages are requested, the memory of the guest resides in them
+	// and hence, it gets the size of the hugepage limit
+	hugepages, err := getGuestHugepages(u.Spec.Linux.Resources)
+	if err != nil {
+		return err
+	}
+	if hugepages != nil {
+		uniklog.WithFields(logrus.Fields{
+			"page size": hugepages.pageSize,
+			"limit":     hugepages.limit,
+		}).Info("Backing the guest memory with hugepages")
+		vmmArgs.MemSizeB = hugepages.limit
+		vmmArgs.HugepageSize = hugepages.pageSize
+	}
+
 	// ExecArgs
 	// Check if container is set to unconfined -- disable seccomp
 	if u.Spec.Linux.Seccomp == nil {
 		uniklog.Warn("Seccomp is disabled")
 		vmmArgs.Seccomp = false
 	}
+	vmmArgs.SeccompProfile = u.Spec.Linux.Seccomp
+	vmmArgs.SeccompMode = u.UruncCfg.Monitors[vmmType].SeccompMode
+	err = hypervisors.ValidateSeccompMode(vmmArgs.SeccompMode)
+	if err != nil {
+		return err
+	}
 
 	procAttrs := types.ProcessConfig{
 		UID:     u.Spec.Process.User.UID,
@@ -289,33 +361,39 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 	// UnikernelParams
 	// populate unikernel params
 	unikernelParams := types.UnikernelParams{
-		CmdLine:  u.Spec.Process.Args,
-		EnvVars:  u.Spec.Process.Env,
-		Monitor:  vmmType,
-		Version:  unikernelVersion,
-		ProcConf: procAttrs,
+		CmdLine:   u.Spec.Process.Args,
+		EnvVars:   u.Spec.Process.Env,
+		Monitor:   vmmType,
+		Version:   unikernelVersion,
+		Machine:   vmmArgs.Machine,
+		GuestExit: u.UruncCfg.Monitors[vmmType].GuestExit,
+		ProcConf:  procAttrs,
 	}
 	if len(unikernelParams.CmdLine) == 0 {
 		unikernelParams.CmdLine = strings.Fields(u.State.Annotations[annotCmdLine])
 	}
 
 	// handle network
-	netArgs, err := u.SetupNet()
+	// The secondary interfaces of the container get mirrored only if both
+	// the monitor and the guest can use more than one network device
+	multiNIC := vmm.Capabilities().MultiNIC && unikernel.Capabilities().MultiNIC
+	netArgs, err := u.SetupNet(multiNIC)
 	if err != nil {
 		uniklog.Errorf("failed to setup network: %v", err)
 		return err
 	}
 	metrics.Capture(m.TS16)
-	withTUNTAP := netArgs.IP != ""
+	withTUNTAP := len(netArgs) > 0
 
 	// UnikernelParams
-	unikernelParams.Net = netArgs
+	unikernelParams.Nets = netArgs
 
 	// ExecArgs
-	vmmArgs.Net = netArgs
+	vmmArgs.Nets = netArgs
 
 	// virtiofsd config
 	virtiofsdConfig := u.UruncCfg.ExtraBins["virtiofsd"]
+	needsVirtiofsd := !vmm.Capabilities().BuiltinVirtiofs
 
 	// guest rootfs
 	// block
@@ -344,7 +422,7 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 
 	// Setup the rootfs for the monitor execution, creating necessary
 	// devices and the monitor's binary.
-	err = prepareMonRootfs(rootfsParams.MonRootfs, vmm.Path(), u.UruncCfg.Monitors[vmmType].DataPath, vmm.UsesKVM(), withTUNTAP)
+	err = prepareMonRootfs(rootfsParams.MonRootfs, vmm.Path(), u.UruncCfg.Monitors[vmmType].DataPath, vmm.Capabilities().KVM && vmmArgs.Accel != hypervisors.QemuAccelTCG, withTUNTAP)
 	if err != nil {
 		return err
 	}
@@ -374,7 +452,11 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 		tmpfsSize = chooseTmpfsSize(vmmArgs.MemSizeB)
 		fallthrough
 	case "9pfs":
-		err = setupSharedfsBasedRootfs(rootfsParams, virtiofsdConfig.Path, u.Spec.Mounts)
+		vfsdBin := ""
+		if needsVirtiofsd {
+			vfsdBin = virtiofsdConfig.Path
+		}
+		err = setupSharedfsBasedRootfs(rootfsParams, vfsdBin, u.Spec.Mounts)
 		if err != nil {
 			return err
 		}
@@ -387,6 +469,8 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 		uniklog.Debug("No rootfs for guest")
 	}
 	unikernelParams.Rootfs = rootfsParams
+	// The monitor finds the unikernel binary in its rootfs
+	unikernelParams.BinaryPath = filepath.Join(rootfsParams.MonRootfs, vmmArgs.UnikernelPath)
 
 	err = createTmpfs(rootfsParams.MonRootfs, "/tmp",
 		unix.MS_NOSUID|unix.MS_NOEXEC|unix.MS_STRICTATIME,
@@ -394,6 +478,12 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 	if err != nil {
 		return err
 	}
+	if vmmArgs.HugepageSize != 0 {
+		err = createHugetlbfs(rootfsParams.MonRootfs, hypervisors.HugepagesMountPath, vmmArgs.HugepageSize)
+		if err != nil {
+			return err
+		}
+	}
 	metrics.Capture(m.TS17)
 
 	blockFromAnnot, err := handleExplicitBlockImage(u.State.Annotations[annotBlock],
@@ -415,7 +505,7 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 	vmmArgs.Sharedfs = sharedfsArgs
 
 	// vAccel setup
-	vAccelType, vsockSocketPath, rpcAddress, err := resolveVAccelConfig(u.State.Annotations[annotHypervisor], u.Spec.Annotations)
+	vAccelType, vsockSocketPath, rpcAddress, err := resolveVAccelConfig(vmm.Capabilities().Vsock, u.Spec.Annotations)
 	if err != nil {
 		uniklog.Debugf("vAccel config: %v", err)
 	}
@@ -431,7 +521,7 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 		unikernelParams.EnvVars = append(unikernelParams.EnvVars, "VACCEL_RPC_ADDRESS="+rpcAddress)
 
 		// Prepare the guest environment for vAccel vsock communication
-		err = prepareVSockEnvironment(rootfsParams.MonRootfs, u.State.Annotations[annotHypervisor], vsockSocketPath)
+		err = prepareVSockEnvironment(rootfsParams.MonRootfs, vmm.Capabilities().Vsock, vsockSocketPath)
 		if err != nil {
 			uniklog.Debugf("failed to prepare all required vsock mounts: %v", err)
 		}
@@ -466,6 +556,34 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 	// Therefore, if there was no error and the mount namespace was found
 	// we can pivot.
 	withPivot := err != nil
+	// The state directory is not accessible after changing the root
+	monCmdFile, err := u.openMonitorCmdFile()
+	if err != nil {
+		uniklog.WithError(err).Warn("failed to create the file for the command of the monitor")
+	}
+	var learnFile *os.File
+	if vmmArgs.SeccompMode == hypervisors.SeccompModeLearn {
+		learnFile, err = u.openSeccompLearnFile(vmmType)
+		if err != nil {
+			return err
+		}
+	}
+	var stateDir *os.File
+	if u.UruncCfg.Crash.Enabled {
+		stateDir, err = u.openStateDir()
+		if err != nil {
+			return fmt.Errorf("failed to open the state directory: %w", err)
+		}
+		defer stateDir.Close()
+	}
+	events, err := u.startConsoleLog(unikernel, vmm.Capabilities(), stateDir)
+	if err != nil {
+		return err
+	}
+	if events != nil {
+		defer events.Close()
+		vmmArgs.EventFd = int(events.Fd())
+	}
 	err = changeRoot(rootfsParams.MonRootfs, withPivot)
 	if err != nil {
 		return err
@@ -490,7 +608,7 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 	}
 
 	// virtiofs
-	if rootfsParams.Type == "virtiofs" {
+	if rootfsParams.Type == "virtiofs" && needsVirtiofsd {
 		// Start the virtiofsd process
 		err = spawnVirtiofsd(virtiofsdConfig, containerRootfsMountPath)
 		if err != nil {
@@ -508,6 +626,12 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 		uniklog.WithError(err).Error("failed to build VMM command")
 		return err
 	}
+	if monCmdFile != nil {
+		err = writeMonitorCmd(monCmdFile, execCmd)
+		if err != nil {
+			uniklog.WithError(err).Warn("failed to write the command of the monitor")
+		}
+	}
 
 	// Notify urunc start that the monitor is ready to execute.
 	// We send this after BuildExecCmd succeeds to avoid reporting a container
@@ -520,6 +644,16 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 		return err
 	}
 
+	// Guests that report their exit through a device of the monitor need
+	// the current process to stay alive and map the exit of the monitor.
+	// The same holds for the console logger, see supervisesMonitor.
+	var exitMapper types.ExitMapper
+	_, isRunner := vmm.(types.VMMRunner)
+	if reporter, ok := unikernel.(types.GuestExitReporter); ok && !isRunner {
+		exitMapper = reporter.ExitMapper()
+	}
+	vmmArgs.Supervised = !isRunner && u.supervisesMonitor(exitMapper)
+
 	// Perform any monitor-specific pre-exec setup (e.g., seccomp filters for HVT).
 	err = vmm.PreExec(vmmArgs)
 	if err != nil {
@@ -527,11 +661,56 @@ func (u *Unikontainer) Exec(metrics m.Writer) error {
 		return err
 	}
 
+	// Monitors without a host process (e.g. Hedge) launch the VM through
+	// their API and the current process stays alive until the VM exits.
+	if runner, ok := vmm.(types.VMMRunner); ok {
+		uniklog.WithField("command", execCmd).Debug("Ready to run VM through the monitor's API")
+		if learnFile != nil {
+			uniklog.Warn("Learning the system calls of the monitor is not supported")
+			_ = learnFile.Close()
+		}
+		err = runner.Run(vmmArgs, unikernel)
+		u.stopConsoleLog()
+		return err
+	}
+
+	// In learn mode, the current process stays alive and records the
+	// system calls of the monitor.
+	if learnFile != nil {
+		uniklog.WithField("command", execCmd).Debug("Ready to run VMM and record its system calls")
+		return u.superviseMonitor(func() (unix.WaitStatus, error) {
+			return hypervisors.RunLearning(vmm.Path(), execCmd, vmmArgs.Environment, learnFile)
+		}, exitMapper, stateDir)
+	}
+
+	if vmmArgs.Supervised {
+		uniklog.WithField("command", execCmd).Debug("Ready to run VMM as a child")
+		return u.superviseMonitor(func() (unix.WaitStatus, error) {
+			return hypervisors.RunSupervised(vmm.Path(), execCmd, vmmArgs.Environment)
+		}, exitMapper, stateDir)
+	}
+
 	// Execute the VMM using the command we built earlier.
 	uniklog.WithField("command", execCmd).Debug("Ready to execve VMM")
 	return syscall.Exec(vmm.Path(), execCmd, vmmArgs.Environment) //nolint: gosec
 }
 
+// openSeccompLearnFile creates the file where learn mode records the
+// seccomp profile of the monitor. It must be called before reexec changes
+// its root, since the directory of the profiles resides in the host.
+func (u *Unikontainer) openSeccompLearnFile(vmmType string) (*os.File, error) {
+	dir := u.UruncCfg.Monitors[vmmType].SeccompLearnDir
+	if dir == "" {
+		dir = hypervisors.DefaultSeccompLearnDir
+	}
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the directory of seccomp profiles: %w", err)
+	}
+	path := filepath.Join(dir, vmmType+"-"+u.State.ID+".json")
+	return os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0o644) //nolint: gosec
+}
+
 func setupUser(user specs.User) error {
 	runtime.LockOSThread()
 	// Set the user for the current go routine to exec the Monitor
@@ -562,6 +741,7 @@ func (u *Unikontainer) Kill() error {
 	// Try to join the Network namespace of the monitor before killing it.
 	// If we kill it there might be no process inside the namespace and hence
 	// the namespace gets destroyed.
+	joinedNetNs := true
 	err := u.joinSandboxNetNs()
 	if err != nil {
 		if errors.Is(err, ErrNotExistingNS) {
@@ -571,7 +751,14 @@ func (u *Unikontainer) Kill() error {
 			uniklog.Infof("could not find sandbox's network namespace: %v", err)
 			return nil
 		}
-		return fmt.Errorf("failed to join sandbox netns: %v", err)
+		// An unprivileged user can not join the network namespace,
+		// but the tap device will get removed along with the namespace,
+		// since it belongs to the user namespace of the sandbox.
+		if !u.rootlessEUID() || !errors.Is(err, unix.EPERM) {
+			return fmt.Errorf("failed to join sandbox netns: %v", err)
+		}
+		uniklog.Debugf("could not join sandbox's network namespace in rootless mode: %v", err)
+		joinedNetNs = false
 	}
 
 	// get a new vmm
@@ -580,15 +767,25 @@ func (u *Unikontainer) Kill() error {
 	if err != nil {
 		return err
 	}
-	err = vmm.Stop(u.State.Pid)
+	stopper, ok := vmm.(types.VMMSocketStopper)
+	if ok && u.MonitorSocket() != "" {
+		err = stopper.StopWithSocket(u.MonitorSocket(), u.State.Pid)
+	} else {
+		err = vmm.Stop(u.State.Pid)
+	}
 	if err != nil {
 		return err
 	}
 
-	// TODO: tap0_urunc should not be hardcoded
-	err = network.Cleanup("tap0_urunc")
-	if err != nil {
-		uniklog.Errorf("failed to delete tap0_urunc: %v", err)
+	if !joinedNetNs {
+		return nil
+	}
+
+	for _, tapDevice := range network.ContainerTaps(u.State.ID) {
+		err = network.Cleanup(tapDevice)
+		if err != nil {
+			uniklog.Errorf("failed to delete %s: %v", tapDevice, err)
+		}
 	}
 
 	return nil
@@ -624,16 +821,23 @@ func (u *Unikontainer) Delete() error {
 	// created directories from urunc. In order to check if we used the
 	// rootfs under the bundle directory or we create anew one, we can check
 	// if the monitorRootfsDirName directory exists under the bundle.
-	_, err = os.Stat(monRootfs)
-	if !os.IsNotExist(err) {
+	// In the case of user namespaces, urunc create always creates the
+	// monitorRootfsDirName directory and hence we also need to check
+	// if it is empty.
+	if !isEmptyDir(monRootfs) {
 		// Since there was no block defined for the unikernel
 		// and we created a new rootfs for the monitor, we need to
 		// clean it up.
 		dirs = append(dirs, monitorRootfsDirName)
 		prefPath = bundleDir
 	} else {
-		// Otherwise remove the enw directories we created inside the
-		// container's rootfs.
+		// Otherwise remove the new directories we created inside the
+		// container's rootfs, along with the unused monitor rootfs
+		// directory, if any.
+		err = os.Remove(monRootfs)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove %s: %w", monRootfs, err)
+		}
 		// We do not need to unmount anything here, since we rely on Linux
 		// to do the cleanup for us. This will happen automatically,
 		// when the mount namespace gets destroyed
@@ -645,7 +849,13 @@ func (u *Unikontainer) Delete() error {
 			"/dev",
 			"/tmp",
 		}
-		dirs = append(dirs, vmm.Path())
+		if hypervisors.HasMonitorSocket(hypervisors.VmmType(vmmType), u.UruncCfg.Monitors[vmmType]) {
+			dirs = append(dirs, hypervisors.MonitorSocketName)
+		}
+		// Some monitors (e.g. Hedge) do not have a binary
+		if vmm.Path() != "" {
+			dirs = append(dirs, vmm.Path())
+		}
 		prefPath = rootfsDir
 	}
 
@@ -654,6 +864,14 @@ func (u *Unikontainer) Delete() error {
 		return err
 	}
 
+	// NOTE: We do not fail in case we could not remove the cgroup,
+	// since the container is already dead and we need to clean up the
+	// rest of its state.
+	err = u.RemoveCgroup()
+	if err != nil {
+		uniklog.WithError(err).Error("failed to remove container's cgroup")
+	}
+
 	return os.RemoveAll(u.BaseDir)
 }
 
@@ -881,21 +1099,17 @@ func (u *Unikontainer) FormatNsenterInfo() (rdr io.Reader, retErr error) {
 		// Otherwise, we store the path to the respective element
 		// of the array.
 		switch ns.Type {
-		// Comment out User namespace for the time being and just ignore them
-		// They require better handling for cleaning up and we will address
-		// it in another iteration.
-		// TODO User namespace
-		// case specs.UserNamespace:
-		// 	if ns.Path == "" {
-		// 		cloneFlags |= unix.CLONE_NEWUSER
-		// 	} else {
-		// 		err := checkValidNsPath(ns.Path)
-		// 		if err == nil {
-		// 			nsPaths[0] = "user:" + ns.Path
-		// 		} else {
-		// 			return nil, err
-		// 		}
-		// 	}
+		case specs.UserNamespace:
+			if ns.Path == "" {
+				cloneFlags |= unix.CLONE_NEWUSER
+			} else {
+				err := checkValidNsPath(ns.Path)
+				if err == nil {
+					nsPaths[0] = "user:" + ns.Path
+				} else {
+					return nil, err
+				}
+			}
 		case specs.IPCNamespace:
 			if ns.Path == "" {
 				cloneFlags |= unix.CLONE_NEWIPC
@@ -1018,33 +1232,62 @@ func (u *Unikontainer) FormatNsenterInfo() (rdr io.Reader, retErr error) {
 	// inherit the ones that are already set. Check:
 	// https://github.com/opencontainers/runc/blob/e0e22d33eabc4dc280b7ca0810ed23049afdd370/libcontainer/specconv/spec_linux.go#L1036
 
-	// TODO: Add it when we add user namespaces
-	// if nsPaths[0] == "" {
-	// 	// write uid mappings
-	// 	if len(u.Spec.Linux.UIDMappings) > 0 {
-	// 		// TODO: Rootless
-	// 		b, err := encodeIDMapping(u.Spec.Linux.UIDMappings)
-	// 		if err != nil {
-	// 			return nil, err
-	// 		}
-	// 		r.AddData(&bytemsg{
-	// 			Type:  uidmapAttr,
-	// 			Value: b,
-	// 		})
-	// 	}
-	// 	// write gid mappings
-	// 	if len(u.Spec.Linux.GIDMappings) > 0 {
-	// 		b, err := encodeIDMapping(u.Spec.Linux.GIDMappings)
-	// 		if err != nil {
-	// 			return nil, err
-	// 		}
-	// 		r.AddData(&bytemsg{
-	// 			Type:  gidmapAttr,
-	// 			Value: b,
-	// 		})
-	// 		// TODO: Rootless
-	// 	}
-	// }
+	if cloneFlags&unix.CLONE_NEWUSER != 0 {
+		if len(u.Spec.Linux.UIDMappings) == 0 || len(u.Spec.Linux.GIDMappings) == 0 {
+			return nil, ErrNoIDMappings
+		}
+		// write uid mappings
+		b, err := encodeIDMapping(u.Spec.Linux.UIDMappings)
+		if err != nil {
+			return nil, err
+		}
+		r.AddData(&bytemsg{
+			Type:  uidmapAttr,
+			Value: b,
+		})
+		// write gid mappings
+		b, err = encodeIDMapping(u.Spec.Linux.GIDMappings)
+		if err != nil {
+			return nil, err
+		}
+		r.AddData(&bytemsg{
+			Type:  gidmapAttr,
+			Value: b,
+		})
+		// Allow setgroups(2) in the new user namespace, since the
+		// monitor process might need to set additional groups.
+		// However, an unprivileged user can write only a single mapping
+		// for its own uid/gid and only after setgroups gets denied.
+		// Otherwise, nsenter will use newuidmap/newgidmap.
+		setgroup := true
+		if u.rootlessEUID() {
+			r.AddData(&boolmsg{
+				Type:  rootlessEUIDAttr,
+				Value: true,
+			})
+			if needsIDMapTools(u.Spec.Linux.UIDMappings, os.Geteuid()) ||
+				needsIDMapTools(u.Spec.Linux.GIDMappings, os.Getegid()) {
+				uidmapPath, gidmapPath, err := idMapToolPaths()
+				if err != nil {
+					return nil, err
+				}
+				r.AddData(&bytemsg{
+					Type:  uidmapPathAttr,
+					Value: []byte(uidmapPath),
+				})
+				r.AddData(&bytemsg{
+					Type:  gidmapPathAttr,
+					Value: []byte(gidmapPath),
+				})
+			} else {
+				setgroup = false
+			}
+		}
+		r.AddData(&boolmsg{
+			Type:  setgroupAttr,
+			Value: setgroup,
+		})
+	}
 
 	return bytes.NewReader(r.Serialize()), nil
 }
@@ -1068,6 +1311,15 @@ func (u *Unikontainer) CreateListener(isReexec bool) error {
 
 	u.Listener = listener
 
+	// Reexec runs as the root user of the container's user namespace and
+	// it needs write access to the socket in order to connect.
+	if !isReexec {
+		err = u.chownToUserNSRoot(sockAddr)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -1100,7 +1352,8 @@ func (u *Unikontainer) CreateConn(isReexec bool) error {
 		sockAddr = getUruncSockAddr(u.BaseDir)
 	}
 
-	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: sockAddr, Net: "unix"})
+	// The other side might not have created its listener yet
+	conn, err := dialWithRetry(sockAddr, u.UruncCfg.Timeouts.Dial)
 	if err != nil {
 		uniklog.WithError(err).Errorf("failed to create connection to unix socket %s", sockAddr)
 		return fmt.Errorf("failed to create connection to unix socket %s: %w", sockAddr, err)
@@ -1128,9 +1381,16 @@ func (u *Unikontainer) DestroyConn(isReexec bool) error {
 	return nil
 }
 
-// AwaitMessage waits for a specific message in the listener of unikontainer instance
-func (u *Unikontainer) AwaitMsg(msg IPCMessage) error {
-	return AwaitMessage(u.Listener, msg)
+// AwaitMsg waits for a specific message in the listener of unikontainer instance.
+// If the caller is reexec, then it waits for urunc start, which is not
+// running yet and hence there is no process to watch.
+// Otherwise, it waits for the reexec process and the wait stops as soon as
+// the reexec process exits.
+func (u *Unikontainer) AwaitMsg(msg IPCMessage, isReexec bool) error {
+	if isReexec {
+		return AwaitMessage(u.Listener, msg, u.UruncCfg.Timeouts.ReexecStart, 0)
+	}
+	return AwaitMessage(u.Listener, msg, u.UruncCfg.Timeouts.Start, u.State.Pid)
 }
 
 // SendMessage sends message over the active connection
@@ -1151,15 +1411,23 @@ func (u *Unikontainer) isRunning() bool {
 	if vmmType != hypervisors.HedgeVmm {
 		return syscall.Kill(u.State.Pid, syscall.Signal(0)) == nil
 	}
-	hedge := hypervisors.Hedge{}
+	hedge := hypervisors.NewHedge()
 	state := hedge.VMState(u.State.ID)
 	return state == "running"
 }
 
 // getNetworkType checks if current container is a knative user-container
+// and otherwise returns the network of the configured network mode
 func (u Unikontainer) getNetworkType() string {
 	if u.Spec.Annotations["io.kubernetes.cri.container-name"] == "user-container" {
 		return "static"
 	}
+	switch u.UruncCfg.Network.Mode {
+	case NetworkModeBridge:
+		return "bridge"
+	case "", NetworkModeRedirect:
+	default:
+		uniklog.Warnf("Unknown network mode '%s'. Using the %s mode.", u.UruncCfg.Network.Mode, NetworkModeRedirect)
+	}
 	return "dynamic"
 }
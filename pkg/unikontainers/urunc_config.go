@@ -17,6 +17,7 @@ package unikontainers
 import (
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
@@ -34,21 +35,75 @@ type UruncTimestamps struct {
 	Destination string `toml:"destination"` // Used to specify a file for timestamps
 }
 
+// UruncTimeouts holds the deadlines for the IPC handshake between
+// urunc create (reexec) and urunc start. A zero value disables the deadline.
+type UruncTimeouts struct {
+	Dial        time.Duration `toml:"dial"`         // Connecting to the socket of the other side
+	Start       time.Duration `toml:"start"`        // urunc start waiting for reexec to start the monitor
+	ReexecStart time.Duration `toml:"reexec_start"` // reexec waiting for the start request from urunc start
+}
+
+// UruncDetection controls the detection of the unikernel type and the
+// monitor from the unikernel binary, for containers without the urunc
+// annotations.
+type UruncDetection struct {
+	Enabled bool     `toml:"enabled"`
+	Paths   []string `toml:"paths"` // Where to look for the binary in the rootfs, if it is not annotated
+}
+
+// UruncConsole controls the capture of the serial console of the guest in
+// a log file with size based rotation
+type UruncConsole struct {
+	Enabled   bool   `toml:"enabled"`
+	Directory string `toml:"directory"`   // The log is stored in <directory>/<container ID>. When empty, in the state directory of the container
+	MaxSizeMB uint   `toml:"max_size_mb"` // The size of the log that triggers a rotation. Zero disables the rotation
+	MaxFiles  uint   `toml:"max_files"`   // The number of rotated logs to keep
+}
+
+// UruncCrash controls the detection of crashes of the guest, through the
+// console of the guest or the pvpanic device of the monitor
+type UruncCrash struct {
+	Enabled      bool `toml:"enabled"`
+	ConsoleLines uint `toml:"console_lines"` // The number of the last lines of the console to report on a crash
+}
+
+const (
+	// NetworkModeRedirect redirects all the traffic of the container
+	// interface to a single guest, which takes its IP and MAC address
+	NetworkModeRedirect = "redirect"
+	// NetworkModeBridge connects the guests of a network namespace to a
+	// bridge, each with its own private IP and MAC address
+	NetworkModeBridge = "bridge"
+)
+
+// UruncNetwork controls how the guests get connected to the network of
+// their container
+type UruncNetwork struct {
+	Mode string `toml:"mode"` // NetworkModeRedirect or NetworkModeBridge
+}
+
 type UruncConfig struct {
 	Log        UruncLog                        `toml:"log"`
 	Timestamps UruncTimestamps                 `toml:"timestamps"`
+	Timeouts   UruncTimeouts                   `toml:"timeouts"`
+	Detection  UruncDetection                  `toml:"detection"`
+	Console    UruncConsole                    `toml:"console"`
+	Crash      UruncCrash                      `toml:"crash"`
+	Network    UruncNetwork                    `toml:"network"`
 	Monitors   map[string]types.MonitorConfig  `toml:"monitors"`
 	ExtraBins  map[string]types.ExtraBinConfig `toml:"extra_binaries"`
 }
 
-// this struct is used to parse only the log and timestamp section of the urunc config file
+// this struct is used to parse only the sections of the urunc config file
+// that are loaded in every urunc invocation (log, timestamps and timeouts)
 type LogMetricsUruncConfig struct {
 	Log        UruncLog        `toml:"log"`
 	Timestamps UruncTimestamps `toml:"timestamps"`
+	Timeouts   UruncTimeouts   `toml:"timeouts"`
 }
 
 func ParseLogMetricsConfig(path string) (LogMetricsUruncConfig, error) {
-	var initialConf LogMetricsUruncConfig
+	initialConf := LogMetricsUruncConfig{Timeouts: defaultTimeoutsConfig()}
 	_, err := toml.DecodeFile(path, &initialConf)
 	if err == nil {
 		return initialConf, nil
@@ -61,6 +116,7 @@ func defaultLogMetricsConfig() LogMetricsUruncConfig {
 	return LogMetricsUruncConfig{
 		Log:        defaultLogConfig(),
 		Timestamps: defaultTimestampsConfig(),
+		Timeouts:   defaultTimeoutsConfig(),
 	}
 }
 
@@ -78,6 +134,43 @@ func defaultTimestampsConfig() UruncTimestamps {
 	}
 }
 
+func defaultTimeoutsConfig() UruncTimeouts {
+	return UruncTimeouts{
+		Dial:        5 * time.Second,
+		Start:       5 * time.Minute,
+		ReexecStart: 0, // The time between create and start is not bounded
+	}
+}
+
+func defaultDetectionConfig() UruncDetection {
+	return UruncDetection{
+		Enabled: false,
+		Paths:   []string{"/kernel", "/unikernel/kernel"},
+	}
+}
+
+func defaultConsoleConfig() UruncConsole {
+	return UruncConsole{
+		Enabled:   false,
+		Directory: "",
+		MaxSizeMB: 10,
+		MaxFiles:  3,
+	}
+}
+
+func defaultCrashConfig() UruncCrash {
+	return UruncCrash{
+		Enabled:      false,
+		ConsoleLines: 20,
+	}
+}
+
+func defaultNetworkConfig() UruncNetwork {
+	return UruncNetwork{
+		Mode: NetworkModeRedirect,
+	}
+}
+
 func defaultMonitorsConfig() map[string]types.MonitorConfig {
 	return map[string]types.MonitorConfig{
 		"qemu":             {DefaultMemoryMB: 256, DefaultVCPUs: 1},
@@ -85,6 +178,8 @@ func defaultMonitorsConfig() map[string]types.MonitorConfig {
 		"spt":              {DefaultMemoryMB: 256, DefaultVCPUs: 1},
 		"firecracker":      {DefaultMemoryMB: 256, DefaultVCPUs: 1},
 		"cloud-hypervisor": {DefaultMemoryMB: 256, DefaultVCPUs: 1},
+		"crosvm":           {DefaultMemoryMB: 256, DefaultVCPUs: 1},
+		"kvmtool":          {DefaultMemoryMB: 256, DefaultVCPUs: 1},
 	}
 }
 
@@ -98,6 +193,11 @@ func defaultUruncConfig() *UruncConfig {
 	return &UruncConfig{
 		Log:        defaultLogConfig(),
 		Timestamps: defaultTimestampsConfig(),
+		Timeouts:   defaultTimeoutsConfig(),
+		Detection:  defaultDetectionConfig(),
+		Console:    defaultConsoleConfig(),
+		Crash:      defaultCrashConfig(),
+		Network:    defaultNetworkConfig(),
 		Monitors:   defaultMonitorsConfig(),
 		ExtraBins:  defaultExtraBinConfig(),
 	}
@@ -106,7 +206,13 @@ func defaultUruncConfig() *UruncConfig {
 // LoadUruncConfig loads the urunc configuration from the specified path.
 // If the file does not exist or is malformed, it returns the default configuration.
 func LoadUruncConfig(path string) (*UruncConfig, error) {
-	cfg := &UruncConfig{}
+	cfg := &UruncConfig{
+		Timeouts:  defaultTimeoutsConfig(),
+		Detection: defaultDetectionConfig(),
+		Console:   defaultConsoleConfig(),
+		Crash:     defaultCrashConfig(),
+		Network:   defaultNetworkConfig(),
+	}
 	_, err := toml.DecodeFile(path, cfg)
 	if err == nil {
 		return cfg, nil
@@ -116,7 +222,7 @@ func LoadUruncConfig(path string) (*UruncConfig, error) {
 }
 
 func (p *UruncConfig) Map() map[string]string {
-	// since log and timestamps are loaded at the start of urunc, we will not be adding
+	// since log, timestamps and timeouts are loaded at the start of urunc, we will not be adding
 	// them to this map. this map will be used to save the rest of the urunc config to state.json
 	cfgMap := make(map[string]string)
 
@@ -127,6 +233,32 @@ func (p *UruncConfig) Map() map[string]string {
 		cfgMap[prefix+"binary_path"] = hvCfg.BinaryPath
 		cfgMap[prefix+"data_path"] = hvCfg.DataPath
 		cfgMap[prefix+"vhost"] = strconv.FormatBool(hvCfg.Vhost)
+		cfgMap[prefix+"api_socket"] = strconv.FormatBool(hvCfg.APISocket)
+		cfgMap[prefix+"machine"] = hvCfg.Machine
+		cfgMap[prefix+"accel"] = hvCfg.Accel
+		cfgMap[prefix+"guest_exit"] = strconv.FormatBool(hvCfg.GuestExit)
+		cfgMap[prefix+"seccomp_mode"] = hvCfg.SeccompMode
+		cfgMap[prefix+"seccomp_learn_dir"] = hvCfg.SeccompLearnDir
+		if hvCfg.Template != "" {
+			cfgMap[prefix+"template"] = hvCfg.Template
+			cfgMap[prefix+"uses_kvm"] = strconv.FormatBool(hvCfg.UsesKVM)
+			cfgMap[prefix+"sharedfs"] = strings.Join(hvCfg.Sharedfs, ",")
+		}
+	}
+	if p.Console.Enabled {
+		prefix := "urunc_config.console."
+		cfgMap[prefix+"enabled"] = strconv.FormatBool(p.Console.Enabled)
+		cfgMap[prefix+"directory"] = p.Console.Directory
+		cfgMap[prefix+"max_size_mb"] = strconv.FormatUint(uint64(p.Console.MaxSizeMB), 10)
+		cfgMap[prefix+"max_files"] = strconv.FormatUint(uint64(p.Console.MaxFiles), 10)
+	}
+	if p.Crash.Enabled {
+		prefix := "urunc_config.crash."
+		cfgMap[prefix+"enabled"] = strconv.FormatBool(p.Crash.Enabled)
+		cfgMap[prefix+"console_lines"] = strconv.FormatUint(uint64(p.Crash.ConsoleLines), 10)
+	}
+	if p.Network.Mode != "" && p.Network.Mode != NetworkModeRedirect {
+		cfgMap["urunc_config.network.mode"] = p.Network.Mode
 	}
 	for eb, ebCfg := range p.ExtraBins {
 		prefix := "urunc_config.extra_binaries." + eb + "."
@@ -137,12 +269,65 @@ func (p *UruncConfig) Map() map[string]string {
 }
 
 func UruncConfigFromMap(cfgMap map[string]string) *UruncConfig {
-	// since log and timestamps are loaded at the start of urunc, we will not be reading
+	// since log, timestamps and timeouts are loaded at the start of urunc, we will not be reading
 	// them from this map. this map will be used to parse the rest of the urunc config from state.json
 	cfg := &UruncConfig{
+		Timeouts:  defaultTimeoutsConfig(),
+		Console:   defaultConsoleConfig(),
+		Crash:     defaultCrashConfig(),
+		Network:   defaultNetworkConfig(),
 		Monitors:  defaultMonitorsConfig(),
 		ExtraBins: defaultExtraBinConfig(),
 	}
+	if mode, ok := cfgMap["urunc_config.network.mode"]; ok {
+		cfg.Network.Mode = mode
+	}
+
+	for key, val := range cfgMap {
+		option, found := strings.CutPrefix(key, "urunc_config.console.")
+		if !found {
+			continue
+		}
+		switch option {
+		case "enabled":
+			boolVal, err := strconv.ParseBool(val)
+			if err != nil {
+				uniklog.Warnf("Invalid console enabled value '%s': %v. Using default (false).", val, err)
+			} else {
+				cfg.Console.Enabled = boolVal
+			}
+		case "directory":
+			cfg.Console.Directory = val
+		case "max_size_mb":
+			if intVal, err := strconv.Atoi(val); err == nil && intVal >= 0 {
+				cfg.Console.MaxSizeMB = uint(intVal)
+			}
+		case "max_files":
+			if intVal, err := strconv.Atoi(val); err == nil && intVal >= 0 {
+				cfg.Console.MaxFiles = uint(intVal)
+			}
+		}
+	}
+
+	for key, val := range cfgMap {
+		option, found := strings.CutPrefix(key, "urunc_config.crash.")
+		if !found {
+			continue
+		}
+		switch option {
+		case "enabled":
+			boolVal, err := strconv.ParseBool(val)
+			if err != nil {
+				uniklog.Warnf("Invalid crash enabled value '%s': %v. Using default (false).", val, err)
+			} else {
+				cfg.Crash.Enabled = boolVal
+			}
+		case "console_lines":
+			if intVal, err := strconv.Atoi(val); err == nil && intVal >= 0 {
+				cfg.Crash.ConsoleLines = uint(intVal)
+			}
+		}
+	}
 
 	for key, val := range cfgMap {
 		if !strings.HasPrefix(key, "urunc_config.monitors.") {
@@ -180,6 +365,41 @@ func UruncConfigFromMap(cfgMap map[string]string) *UruncConfig {
 			} else {
 				hvCfg.Vhost = boolVal
 			}
+		case "api_socket":
+			boolVal, err := strconv.ParseBool(val)
+			if err != nil {
+				uniklog.Warnf("Invalid api_socket value '%s' for monitor '%s': %v. Using default (false).", val, hv, err)
+			} else {
+				hvCfg.APISocket = boolVal
+			}
+		case "machine":
+			hvCfg.Machine = val
+		case "accel":
+			hvCfg.Accel = val
+		case "guest_exit":
+			boolVal, err := strconv.ParseBool(val)
+			if err != nil {
+				uniklog.Warnf("Invalid guest_exit value '%s' for monitor '%s': %v. Using default (false).", val, hv, err)
+			} else {
+				hvCfg.GuestExit = boolVal
+			}
+		case "seccomp_mode":
+			hvCfg.SeccompMode = val
+		case "seccomp_learn_dir":
+			hvCfg.SeccompLearnDir = val
+		case "template":
+			hvCfg.Template = val
+		case "uses_kvm":
+			boolVal, err := strconv.ParseBool(val)
+			if err != nil {
+				uniklog.Warnf("Invalid uses_kvm value '%s' for monitor '%s': %v. Using default (false).", val, hv, err)
+			} else {
+				hvCfg.UsesKVM = boolVal
+			}
+		case "sharedfs":
+			if val != "" {
+				hvCfg.Sharedfs = strings.Split(val, ",")
+			}
 		}
 		cfg.Monitors[hv] = hvCfg
 	}
@@ -15,7 +15,10 @@
 package unikontainers
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
@@ -28,6 +31,11 @@ const (
 	testQemuBinaryKey    = "urunc_config.monitors.qemu.binary_path"
 	testQemuDataKey      = "urunc_config.monitors.qemu.data_path"
 	testQemuVhostKey     = "urunc_config.monitors.qemu.vhost"
+	testFCAPISocketKey   = "urunc_config.monitors.firecracker.api_socket"
+	testQemuMachineKey   = "urunc_config.monitors.qemu.machine"
+	testQemuAccelKey     = "urunc_config.monitors.qemu.accel"
+	testQemuGuestExitKey = "urunc_config.monitors.qemu.guest_exit"
+	testHvtSeccompKey    = "urunc_config.monitors.hvt.seccomp_mode"
 	testHvtMemoryKey     = "urunc_config.monitors.hvt.default_memory_mb"
 	testVirtiofsdPathKey = "urunc_config.extra_binaries.virtiofsd.path"
 	testVirtiofsdOptsKey = "urunc_config.extra_binaries.virtiofsd.options"
@@ -354,6 +362,93 @@ func TestUruncConfigFromMap(t *testing.T) {
 		assert.False(t, qemuConfig.Vhost, "invalid vhost value should default to false")
 	})
 
+	t.Run("api socket is parsed correctly", func(t *testing.T) {
+		t.Parallel()
+		cfgMap := map[string]string{
+			testFCAPISocketKey: "true",
+		}
+
+		config := UruncConfigFromMap(cfgMap)
+
+		assert.NotNil(t, config)
+		assert.True(t, config.Monitors["firecracker"].APISocket)
+		assert.False(t, config.Monitors["qemu"].APISocket)
+	})
+
+	t.Run("machine is parsed correctly", func(t *testing.T) {
+		t.Parallel()
+		cfgMap := map[string]string{
+			testQemuMachineKey: "microvm",
+		}
+
+		config := UruncConfigFromMap(cfgMap)
+
+		assert.NotNil(t, config)
+		assert.Equal(t, "microvm", config.Monitors["qemu"].Machine)
+		assert.Equal(t, "", config.Monitors["firecracker"].Machine)
+	})
+
+	t.Run("accel is parsed correctly", func(t *testing.T) {
+		t.Parallel()
+		cfgMap := map[string]string{
+			testQemuAccelKey: "auto",
+		}
+
+		config := UruncConfigFromMap(cfgMap)
+
+		assert.NotNil(t, config)
+		assert.Equal(t, "auto", config.Monitors["qemu"].Accel)
+	})
+
+	t.Run("console is parsed correctly", func(t *testing.T) {
+		t.Parallel()
+		config := UruncConfigFromMap(map[string]string{
+			"urunc_config.console.enabled":     "true",
+			"urunc_config.console.directory":   "/var/log/urunc",
+			"urunc_config.console.max_size_mb": "0",
+			"urunc_config.console.max_files":   "invalid",
+		})
+
+		assert.Equal(t, UruncConsole{Enabled: true, Directory: "/var/log/urunc", MaxSizeMB: 0, MaxFiles: 3}, config.Console)
+		assert.Equal(t, defaultConsoleConfig(), UruncConfigFromMap(map[string]string{}).Console)
+	})
+
+	t.Run("crash is parsed correctly", func(t *testing.T) {
+		t.Parallel()
+		config := UruncConfigFromMap(map[string]string{
+			"urunc_config.crash.enabled":       "true",
+			"urunc_config.crash.console_lines": "50",
+		})
+
+		assert.Equal(t, UruncCrash{Enabled: true, ConsoleLines: 50}, config.Crash)
+		assert.Equal(t, defaultCrashConfig(), UruncConfigFromMap(map[string]string{}).Crash)
+	})
+
+	t.Run("network mode is parsed correctly", func(t *testing.T) {
+		t.Parallel()
+		config := UruncConfigFromMap(map[string]string{
+			"urunc_config.network.mode": NetworkModeBridge,
+		})
+
+		assert.Equal(t, UruncNetwork{Mode: NetworkModeBridge}, config.Network)
+		assert.Equal(t, defaultNetworkConfig(), UruncConfigFromMap(map[string]string{}).Network)
+	})
+
+	t.Run("seccomp mode is parsed correctly", func(t *testing.T) {
+		t.Parallel()
+		cfgMap := map[string]string{
+			testHvtSeccompKey: "learn",
+			"urunc_config.monitors.hvt.seccomp_learn_dir": "/tmp/profiles",
+		}
+
+		config := UruncConfigFromMap(cfgMap)
+
+		assert.NotNil(t, config)
+		assert.Equal(t, "learn", config.Monitors["hvt"].SeccompMode)
+		assert.Equal(t, "/tmp/profiles", config.Monitors["hvt"].SeccompLearnDir)
+		assert.Equal(t, "", config.Monitors["qemu"].SeccompMode)
+	})
+
 }
 
 func TestUruncConfigMap(t *testing.T) {
@@ -464,6 +559,124 @@ func TestUruncConfigMap(t *testing.T) {
 
 		assert.Equal(t, "true", cfgMap[testQemuVhostKey])
 	})
+
+	t.Run("machine is serialized correctly", func(t *testing.T) {
+		t.Parallel()
+		config := &UruncConfig{
+			Monitors: map[string]types.MonitorConfig{
+				"qemu": {Machine: "microvm"},
+			},
+		}
+
+		cfgMap := config.Map()
+
+		assert.Equal(t, "microvm", cfgMap[testQemuMachineKey])
+		assert.Equal(t, "microvm", UruncConfigFromMap(cfgMap).Monitors["qemu"].Machine)
+	})
+
+	t.Run("accel is serialized correctly", func(t *testing.T) {
+		t.Parallel()
+		config := &UruncConfig{
+			Monitors: map[string]types.MonitorConfig{
+				"qemu": {Accel: "tcg"},
+			},
+		}
+
+		cfgMap := config.Map()
+
+		assert.Equal(t, "tcg", cfgMap[testQemuAccelKey])
+		assert.Equal(t, "tcg", UruncConfigFromMap(cfgMap).Monitors["qemu"].Accel)
+	})
+
+	t.Run("guest exit is serialized correctly", func(t *testing.T) {
+		t.Parallel()
+		config := &UruncConfig{
+			Monitors: map[string]types.MonitorConfig{
+				"qemu": {GuestExit: true},
+			},
+		}
+
+		cfgMap := config.Map()
+
+		assert.Equal(t, "true", cfgMap[testQemuGuestExitKey])
+		assert.True(t, UruncConfigFromMap(cfgMap).Monitors["qemu"].GuestExit)
+	})
+
+	t.Run("console is serialized only when enabled", func(t *testing.T) {
+		t.Parallel()
+		config := &UruncConfig{Console: defaultConsoleConfig()}
+		assert.Empty(t, config.Map())
+
+		config.Console.Enabled = true
+		assert.Equal(t, map[string]string{
+			"urunc_config.console.enabled":     "true",
+			"urunc_config.console.directory":   "",
+			"urunc_config.console.max_size_mb": "10",
+			"urunc_config.console.max_files":   "3",
+		}, config.Map())
+	})
+
+	t.Run("crash is serialized only when enabled", func(t *testing.T) {
+		t.Parallel()
+		config := &UruncConfig{Crash: defaultCrashConfig()}
+		assert.Empty(t, config.Map())
+
+		config.Crash.Enabled = true
+		assert.Equal(t, map[string]string{
+			"urunc_config.crash.enabled":       "true",
+			"urunc_config.crash.console_lines": "20",
+		}, config.Map())
+	})
+
+	t.Run("network mode is serialized only when not the default", func(t *testing.T) {
+		t.Parallel()
+		config := &UruncConfig{Network: defaultNetworkConfig()}
+		assert.Empty(t, config.Map())
+
+		config.Network.Mode = NetworkModeBridge
+		assert.Equal(t, map[string]string{
+			"urunc_config.network.mode": "bridge",
+		}, config.Map())
+	})
+
+	t.Run("seccomp mode is serialized correctly", func(t *testing.T) {
+		t.Parallel()
+		config := &UruncConfig{
+			Monitors: map[string]types.MonitorConfig{
+				"hvt": {SeccompMode: "log"},
+			},
+		}
+
+		cfgMap := config.Map()
+
+		assert.Equal(t, "log", cfgMap[testHvtSeccompKey])
+		assert.Equal(t, "log", UruncConfigFromMap(cfgMap).Monitors["hvt"].SeccompMode)
+	})
+
+	t.Run("generic monitor is serialized correctly", func(t *testing.T) {
+		t.Parallel()
+		vendorCfg := types.MonitorConfig{
+			DefaultMemoryMB: 128,
+			DefaultVCPUs:    1,
+			BinaryPath:      "/opt/vendor/bin/qemu",
+			Template:        "{{.Path}}\n-kernel\n{{.Args.UnikernelPath}}\n",
+			UsesKVM:         true,
+			Sharedfs:        []string{"9p", "virtio"},
+		}
+		config := &UruncConfig{
+			Monitors: map[string]types.MonitorConfig{
+				"qemu":        {DefaultMemoryMB: 256, DefaultVCPUs: 1},
+				"vendor-qemu": vendorCfg,
+			},
+		}
+
+		cfgMap := config.Map()
+
+		assert.Equal(t, "true", cfgMap["urunc_config.monitors.vendor-qemu.uses_kvm"])
+		assert.Equal(t, "9p,virtio", cfgMap["urunc_config.monitors.vendor-qemu.sharedfs"])
+		assert.NotContains(t, cfgMap, "urunc_config.monitors.qemu.template")
+		assert.Equal(t, vendorCfg, UruncConfigFromMap(cfgMap).Monitors["vendor-qemu"])
+	})
 }
 
 func TestDefaultConfigs(t *testing.T) {
@@ -487,12 +700,14 @@ func TestDefaultConfigs(t *testing.T) {
 		t.Parallel()
 		config := defaultMonitorsConfig()
 
-		assert.Len(t, config, 5)
+		assert.Len(t, config, 7)
 		assert.Contains(t, config, "qemu")
 		assert.Contains(t, config, "hvt")
 		assert.Contains(t, config, "spt")
 		assert.Contains(t, config, "firecracker")
 		assert.Contains(t, config, "cloud-hypervisor")
+		assert.Contains(t, config, "crosvm")
+		assert.Contains(t, config, "kvmtool")
 
 		// Check default values for each monitor
 		for _, hvConfig := range config {
@@ -523,10 +738,19 @@ func TestDefaultConfigs(t *testing.T) {
 		assert.False(t, config.Log.Syslog)
 		assert.False(t, config.Timestamps.Enabled)
 		assert.Equal(t, testTimestampsPath, config.Timestamps.Destination)
-		assert.Len(t, config.Monitors, 5)
+		assert.Len(t, config.Monitors, 7)
 		assert.Len(t, config.ExtraBins, 1)
 	})
 
+	t.Run("defaultTimeoutsConfig", func(t *testing.T) {
+		t.Parallel()
+		config := defaultTimeoutsConfig()
+
+		assert.Equal(t, 5*time.Second, config.Dial)
+		assert.Equal(t, 5*time.Minute, config.Start)
+		assert.Zero(t, config.ReexecStart, "reexec should wait for start without a deadline by default")
+	})
+
 	t.Run("defaultLogMetricsConfig", func(t *testing.T) {
 		t.Parallel()
 		config := defaultLogMetricsConfig()
@@ -537,3 +761,43 @@ func TestDefaultConfigs(t *testing.T) {
 		assert.Equal(t, testTimestampsPath, config.Timestamps.Destination)
 	})
 }
+
+func TestParseTimeoutsConfig(t *testing.T) {
+	t.Run("timeouts are parsed as durations", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "config.toml")
+		content := "[timeouts]\ndial = \"500ms\"\nstart = \"30s\"\n"
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		config, err := ParseLogMetricsConfig(path)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 500*time.Millisecond, config.Timeouts.Dial)
+		assert.Equal(t, 30*time.Second, config.Timeouts.Start)
+		assert.Zero(t, config.Timeouts.ReexecStart)
+	})
+
+	t.Run("missing timeouts section keeps defaults", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "config.toml")
+		content := "[log]\nlevel = \"debug\"\n"
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		config, err := LoadUruncConfig(path)
+
+		assert.NoError(t, err)
+		assert.Equal(t, defaultTimeoutsConfig(), config.Timeouts)
+	})
+
+	t.Run("invalid duration falls back to defaults", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "config.toml")
+		content := "[timeouts]\nstart = \"forever\"\n"
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		config, err := ParseLogMetricsConfig(path)
+
+		assert.Error(t, err)
+		assert.Equal(t, defaultTimeoutsConfig(), config.Timeouts)
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// usesUserNS returns true if the container runs inside a user namespace,
+// either a new one or an existing one.
+func (u *Unikontainer) usesUserNS() bool {
+	if u.Spec.Linux == nil {
+		return false
+	}
+	return hasNS(u.Spec.Linux.Namespaces, specs.UserNamespace)
+}
+
+// rootHostIDs returns the uid and gid in the host that the root user of
+// the container's user namespace maps to.
+func (u *Unikontainer) rootHostIDs() (uint32, uint32, error) {
+	uid, err := hostIDFromMapping(0, u.Spec.Linux.UIDMappings)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find host uid of container's root: %w", err)
+	}
+	gid, err := hostIDFromMapping(0, u.Spec.Linux.GIDMappings)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find host gid of container's root: %w", err)
+	}
+	return uid, gid, nil
+}
+
+// setupUserNSOwnership prepares the directories that reexec needs to write
+// in, after it enters the container's user namespace. Reexec runs as the
+// root user of the user namespace and therefore it can not create files
+// in directories owned by the root of the host. As a result, we need to:
+// - change the owner of the container's base directory, where reexec
+// creates the reexec socket.
+// - create the monitor's rootfs directory inside the bundle, since
+// the bundle is owned by the root of the host.
+//
+// It should be called by urunc create, before spawning reexec.
+func (u *Unikontainer) setupUserNSOwnership() error {
+	if !u.usesUserNS() {
+		return nil
+	}
+
+	err := u.chownToUserNSRoot(u.BaseDir)
+	if err != nil {
+		return err
+	}
+
+	monRootfs := filepath.Join(filepath.Clean(u.State.Bundle), monitorRootfsDirName)
+	err = os.MkdirAll(monRootfs, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create monitor rootfs directory %s: %w", monRootfs, err)
+	}
+
+	return u.chownToUserNSRoot(monRootfs)
+}
+
+// chownToUserNSRoot changes the owner of path to the root user of the
+// container's user namespace. It does nothing if the container does not
+// use a user namespace.
+func (u *Unikontainer) chownToUserNSRoot(path string) error {
+	if !u.usesUserNS() {
+		return nil
+	}
+	// According to runc, the uid/gid mappings of an existing user namespace
+	// can be omitted. In that case we do not know the owner and the files
+	// need to be already accessible from the user namespace.
+	if len(u.Spec.Linux.UIDMappings) == 0 || len(u.Spec.Linux.GIDMappings) == 0 {
+		uniklog.Warnf("no uid/gid mappings for the user namespace, not changing the owner of %s", path)
+		return nil
+	}
+	uid, gid, err := u.rootHostIDs()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chown(path, int(uid), int(gid))
+	if err != nil {
+		return fmt.Errorf("failed to chown %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2023-2026, Nubificus LTD
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unikontainers
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newUserNSUnikontainer(uidMap, gidMap []specs.LinuxIDMapping) *Unikontainer {
+	return &Unikontainer{
+		Spec: &specs.Spec{
+			Linux: &specs.Linux{
+				Namespaces: []specs.LinuxNamespace{
+					{Type: specs.UserNamespace},
+					{Type: specs.MountNamespace},
+				},
+				UIDMappings: uidMap,
+				GIDMappings: gidMap,
+			},
+		},
+		State: &specs.State{Annotations: map[string]string{}},
+	}
+}
+
+func TestFormatNsenterInfoUserNS(t *testing.T) {
+	idMap := []specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 65536}}
+
+	t.Run("new user namespace with mappings", func(t *testing.T) {
+		t.Parallel()
+		u := newUserNSUnikontainer(idMap, idMap)
+		rdr, err := u.FormatNsenterInfo()
+		assert.NoError(t, err)
+		data, err := io.ReadAll(rdr)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, bytes.Count(data, []byte("0 100000 65536\n")),
+			"Expected both uid and gid mappings to be sent to nsenter")
+	})
+
+	t.Run("new user namespace without mappings", func(t *testing.T) {
+		t.Parallel()
+		u := newUserNSUnikontainer(nil, nil)
+		_, err := u.FormatNsenterInfo()
+		assert.ErrorIs(t, err, ErrNoIDMappings)
+	})
+}
+
+func TestRootHostIDs(t *testing.T) {
+	t.Run("root is mapped", func(t *testing.T) {
+		t.Parallel()
+		u := newUserNSUnikontainer(
+			[]specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 65536}},
+			[]specs.LinuxIDMapping{{ContainerID: 0, HostID: 200000, Size: 65536}},
+		)
+		assert.True(t, u.usesUserNS())
+		uid, gid, err := u.rootHostIDs()
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(100000), uid)
+		assert.Equal(t, uint32(200000), gid)
+	})
+
+	t.Run("root is not mapped", func(t *testing.T) {
+		t.Parallel()
+		idMap := []specs.LinuxIDMapping{{ContainerID: 1, HostID: 100000, Size: 65536}}
+		u := newUserNSUnikontainer(idMap, idMap)
+		_, _, err := u.rootHostIDs()
+		assert.Error(t, err)
+	})
+
+	t.Run("no user namespace", func(t *testing.T) {
+		t.Parallel()
+		u := &Unikontainer{Spec: &specs.Spec{Linux: &specs.Linux{}}}
+		assert.False(t, u.usesUserNS())
+		assert.NoError(t, u.chownToUserNSRoot("/non/existing/path"))
+	})
+}
@@ -216,17 +216,29 @@ func convertUint32ToIntSlice(valSlice []uint32, size int) []int {
 	return retSlice
 }
 
-// TODO: Use it when we enable user namespaces
-// func encodeIDMapping(idMap []specs.LinuxIDMapping) ([]byte, error) {
-// 	data := bytes.NewBuffer(nil)
-// 	for _, im := range idMap {
-// 		line := fmt.Sprintf("%d %d %d\n", im.ContainerID, im.HostID, im.Size)
-// 		if _, err := data.WriteString(line); err != nil {
-// 			return nil, err
-// 		}
-// 	}
-// 	return data.Bytes(), nil
-// }
+// encodeIDMapping encodes a list of uid/gid mappings in the format
+// of /proc/<pid>/{uid,gid}_map
+func encodeIDMapping(idMap []specs.LinuxIDMapping) ([]byte, error) {
+	data := bytes.NewBuffer(nil)
+	for _, im := range idMap {
+		line := fmt.Sprintf("%d %d %d\n", im.ContainerID, im.HostID, im.Size)
+		if _, err := data.WriteString(line); err != nil {
+			return nil, err
+		}
+	}
+	return data.Bytes(), nil
+}
+
+// hostIDFromMapping returns the id in the host that the given
+// id inside the user namespace maps to.
+func hostIDFromMapping(id uint32, idMap []specs.LinuxIDMapping) (uint32, error) {
+	for _, im := range idMap {
+		if id >= im.ContainerID && id-im.ContainerID < im.Size {
+			return im.HostID + (id - im.ContainerID), nil
+		}
+	}
+	return 0, fmt.Errorf("id %d is not mapped in the user namespace", id)
+}
 
 func spawnVirtiofsd(vfsdConf types.ExtraBinConfig, sharedPath string) error {
 	args := []string{
@@ -340,6 +352,15 @@ func rmMultipleDirs(prefixPath string, dirs []string) error {
 	return nil
 }
 
+// isEmptyDir returns true if path does not exist or it is an empty directory
+func isEmptyDir(path string) bool {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return len(entries) == 0
+}
+
 func executeHook(hook specs.Hook, state []byte) error {
 	var stdout, stderr bytes.Buffer
 	var cancel context.CancelFunc
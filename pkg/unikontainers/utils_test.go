@@ -364,3 +364,71 @@ func TestLoadSpec(t *testing.T) {
 		assert.Contains(t, err.Error(), "failed to parse specification json", "Expected specific error message")
 	})
 }
+
+func TestEncodeIDMapping(t *testing.T) {
+	t.Run("multiple mappings", func(t *testing.T) {
+		t.Parallel()
+		idMap := []specs.LinuxIDMapping{
+			{ContainerID: 0, HostID: 100000, Size: 1},
+			{ContainerID: 1, HostID: 200000, Size: 65535},
+		}
+		b, err := encodeIDMapping(idMap)
+		assert.NoError(t, err)
+		assert.Equal(t, "0 100000 1\n1 200000 65535\n", string(b))
+	})
+
+	t.Run("no mappings", func(t *testing.T) {
+		t.Parallel()
+		b, err := encodeIDMapping(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, b)
+	})
+}
+
+func TestHostIDFromMapping(t *testing.T) {
+	idMap := []specs.LinuxIDMapping{
+		{ContainerID: 0, HostID: 100000, Size: 1000},
+		{ContainerID: 1000, HostID: 300000, Size: 10},
+	}
+
+	t.Run("mapped ids", func(t *testing.T) {
+		t.Parallel()
+		id, err := hostIDFromMapping(0, idMap)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(100000), id)
+
+		id, err = hostIDFromMapping(999, idMap)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(100999), id)
+
+		id, err = hostIDFromMapping(1005, idMap)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(300005), id)
+	})
+
+	t.Run("unmapped id", func(t *testing.T) {
+		t.Parallel()
+		_, err := hostIDFromMapping(1010, idMap)
+		assert.Error(t, err)
+	})
+}
+
+func TestIsEmptyDir(t *testing.T) {
+	t.Run("non existing directory", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, isEmptyDir(filepath.Join(t.TempDir(), "missing")))
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, isEmptyDir(t.TempDir()))
+	})
+
+	t.Run("non empty directory", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		err := os.Mkdir(filepath.Join(dir, "dev"), 0o755)
+		assert.NoError(t, err)
+		assert.False(t, isEmptyDir(dir))
+	})
+}
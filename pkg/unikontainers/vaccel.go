@@ -19,6 +19,14 @@ import (
 	"regexp"
 
 	"golang.org/x/sys/unix"
+
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
+)
+
+// The spec annotations that enable vAccel for a container
+const (
+	annotVAccel     = "com.urunc.unikernel.vAccel"
+	annotRPCAddress = "com.urunc.unikernel.RPCAddress"
 )
 
 // idToGuestCID generates a deterministic guest CID (Context Identifier)
@@ -37,27 +45,28 @@ func idToGuestCID(id string) int {
 }
 
 // isValidVSockAddress validates a vsock address string and ensures
-// it matches the expected format for the selected hypervisor.
-// For firecracker, it also replaces the RPC address with the
-// corresponding vsock address, and returns the directory path of the
-// unix socket, which must later be bind-mounted into the guest rootfs.
-func isValidVSockAddress(rpcAddress *string, hypervisor string) (bool, string, error) {
+// it matches the expected format for the vsock mode of the monitor.
+// For monitors with hybrid vsock (e.g. firecracker), it also replaces
+// the RPC address with the corresponding vsock address, and returns the
+// directory path of the unix socket, which must later be bind-mounted
+// into the guest rootfs.
+func isValidVSockAddress(rpcAddress *string, mode types.VsockMode) (bool, string, error) {
 	var regex *regexp.Regexp
 
-	switch hypervisor {
-	case "qemu":
+	switch mode {
+	case types.VsockVhost:
 		regex = regexp.MustCompile(`^vsock://2:\d+$`)
-	case "firecracker":
+	case types.VsockUnix:
 		regex = regexp.MustCompile(`^unix://(.*)/vaccel\.sock_(\d+)$`)
 	default:
-		return false, "", fmt.Errorf("unsupported hypervisor: %q", hypervisor)
+		return false, "", fmt.Errorf("the monitor does not support vsock")
 	}
 
 	if regex.MatchString(*rpcAddress) {
-		if hypervisor == "firecracker" {
+		if mode == types.VsockUnix {
 			matches := regex.FindStringSubmatch(*rpcAddress)
 			if matches == nil {
-				return false, "", fmt.Errorf("failed to parse rpc address %q for %s", *rpcAddress, hypervisor)
+				return false, "", fmt.Errorf("failed to parse rpc address %q for %s vsock", *rpcAddress, mode)
 			}
 
 			*rpcAddress = "vsock://2:" + matches[2]
@@ -65,22 +74,22 @@ func isValidVSockAddress(rpcAddress *string, hypervisor string) (bool, string, e
 		}
 		return true, "", nil
 	}
-	return false, "", fmt.Errorf("rpc address %q does not match the expected format for %s", *rpcAddress, hypervisor)
+	return false, "", fmt.Errorf("rpc address %q does not match the expected format for %s vsock", *rpcAddress, mode)
 }
 
 // resolveVAccelConfig parses and validates vAccel-related annotations,
-// resolves the RPC address based on the selected hypervisor,
+// resolves the RPC address based on the vsock mode of the monitor,
 // and returns the vAccel type (e.g., "vsock"), the unix socket path to be
-// bind-mounted (Firecracker only) and the normalized RPC address to be
+// bind-mounted (hybrid vsock only) and the normalized RPC address to be
 // exported to the guest.
-func resolveVAccelConfig(hypervisor string, annotations map[string]string) (string, string, string, error) {
+func resolveVAccelConfig(mode types.VsockMode, annotations map[string]string) (string, string, string, error) {
 	var err error
 	var success bool
 	var vsockSocketPath string
 
-	address := annotations["com.urunc.unikernel.RPCAddress"]
+	address := annotations[annotRPCAddress]
 
-	vAccelType, exists := annotations["com.urunc.unikernel.vAccel"]
+	vAccelType, exists := annotations[annotVAccel]
 	if exists {
 		if address == "" {
 			err = fmt.Errorf("vaccel is enabled, but rpc address is not set")
@@ -93,7 +102,7 @@ func resolveVAccelConfig(hypervisor string, annotations map[string]string) (stri
 
 	if vAccelType == "vsock" {
 		// validate address
-		success, vsockSocketPath, err = isValidVSockAddress(&address, hypervisor)
+		success, vsockSocketPath, err = isValidVSockAddress(&address, mode)
 		if !success {
 			return vAccelType, "", "", err
 		}
@@ -104,8 +113,8 @@ func resolveVAccelConfig(hypervisor string, annotations map[string]string) (stri
 
 // prepareVSockEnvironment prepares all required vsock devices and mounts
 // for vAccel execution inside the guest. This includes /dev/vsock,
-// /dev/vhost-vsock, and (for Firecracker) binding the host unix socket.
-func prepareVSockEnvironment(monRootfs string, hypervisor string, vsockSocketPath string) error {
+// /dev/vhost-vsock, and (for hybrid vsock) binding the host unix socket.
+func prepareVSockEnvironment(monRootfs string, mode types.VsockMode, vsockSocketPath string) error {
 	err := setupDev(monRootfs, "/dev/vsock")
 	if err != nil {
 		return err
@@ -117,7 +126,7 @@ func prepareVSockEnvironment(monRootfs string, hypervisor string, vsockSocketPat
 	}
 
 	// bind mount the unix socket directory
-	if hypervisor == "firecracker" {
+	if mode == types.VsockUnix {
 		err = fileFromHost(monRootfs, vsockSocketPath, "", unix.MS_BIND|unix.MS_PRIVATE, false)
 		if err != nil {
 			return err
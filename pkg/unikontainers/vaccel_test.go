@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/urunc-dev/urunc/pkg/unikontainers/types"
 )
 
 func TestIdToGuestCID(t *testing.T) {
@@ -52,92 +53,101 @@ func TestIsValidVSockAddress(t *testing.T) {
 	tests := []struct {
 		name                 string
 		rpcAddress           string
-		monitor              string
+		mode                 types.VsockMode
 		expectedValid        bool
 		expectedErr          bool
 		expectedPath         string
 		expectedModifiedAddr string
 	}{
 		{
-			name:                 "valid qemu vsock address",
+			name:                 "valid vhost vsock address",
 			rpcAddress:           "vsock://2:1234",
-			monitor:              "qemu",
+			mode:                 types.VsockVhost,
 			expectedValid:        true,
 			expectedErr:          false,
 			expectedPath:         "",
 			expectedModifiedAddr: "vsock://2:1234",
 		},
 		{
-			name:          "invalid qemu vsock - wrong CID",
+			name:                 "valid vhost vsock address with another port",
+			rpcAddress:           "vsock://2:2049",
+			mode:                 types.VsockVhost,
+			expectedValid:        true,
+			expectedErr:          false,
+			expectedPath:         "",
+			expectedModifiedAddr: "vsock://2:2049",
+		},
+		{
+			name:          "invalid vhost vsock - wrong CID",
 			rpcAddress:    "vsock://3:1234",
-			monitor:       "qemu",
+			mode:          types.VsockVhost,
 			expectedValid: false,
 			expectedErr:   true,
 		},
 		{
-			name:          "invalid qemu vsock - no port",
+			name:          "invalid vhost vsock - no port",
 			rpcAddress:    "vsock://2:",
-			monitor:       "qemu",
+			mode:          types.VsockVhost,
 			expectedValid: false,
 			expectedErr:   true,
 		},
 		{
-			name:          "invalid qemu vsock - malformed",
+			name:          "invalid vhost vsock - malformed",
 			rpcAddress:    "vsock://invalid",
-			monitor:       "qemu",
+			mode:          types.VsockVhost,
 			expectedValid: false,
 			expectedErr:   true,
 		},
 		{
 			name:          "not a vsock address",
 			rpcAddress:    "http://localhost:1234",
-			monitor:       "qemu",
+			mode:          types.VsockVhost,
 			expectedValid: false,
 			expectedErr:   true,
 		},
 		{
 			name:          "empty address",
 			rpcAddress:    "",
-			monitor:       "qemu",
+			mode:          types.VsockVhost,
 			expectedValid: false,
 			expectedErr:   true,
 		},
 		{
-			name:                 "valid firecracker unix socket",
+			name:                 "valid unix unix socket",
 			rpcAddress:           "unix:///tmp/vaccel.sock_1234",
-			monitor:              "firecracker",
+			mode:                 types.VsockUnix,
 			expectedValid:        true,
 			expectedErr:          false,
 			expectedPath:         "/tmp",
 			expectedModifiedAddr: "vsock://2:1234",
 		},
 		{
-			name:                 "valid firecracker nested path",
+			name:                 "valid unix nested path",
 			rpcAddress:           "unix:///var/run/urunc/vaccel.sock_5678",
-			monitor:              "firecracker",
+			mode:                 types.VsockUnix,
 			expectedValid:        true,
 			expectedErr:          false,
 			expectedPath:         "/var/run/urunc",
 			expectedModifiedAddr: "vsock://2:5678",
 		},
 		{
-			name:          "firecracker invalid - wrong socket name",
+			name:          "unix invalid - wrong socket name",
 			rpcAddress:    "unix:///tmp/test.sock",
-			monitor:       "firecracker",
+			mode:          types.VsockUnix,
 			expectedValid: false,
 			expectedErr:   true,
 		},
 		{
-			name:          "firecracker invalid - no unix prefix",
+			name:          "unix invalid - no unix prefix",
 			rpcAddress:    "/tmp/vaccel.sock_1234",
-			monitor:       "firecracker",
+			mode:          types.VsockUnix,
 			expectedValid: false,
 			expectedErr:   true,
 		},
 		{
-			name:          "unsupported monitor",
+			name:          "monitor without vsock",
 			rpcAddress:    "vsock://2:1234",
-			monitor:       "kvm",
+			mode:          types.VsockNone,
 			expectedValid: false,
 			expectedErr:   true,
 		},
@@ -147,7 +157,7 @@ func TestIsValidVSockAddress(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			addr := tt.rpcAddress
-			gotValid, gotPath, err := isValidVSockAddress(&addr, tt.monitor)
+			gotValid, gotPath, err := isValidVSockAddress(&addr, tt.mode)
 
 			if tt.expectedErr {
 				assert.Error(t, err, "isValidVSockAddress() should return an error")
@@ -161,7 +171,7 @@ func TestIsValidVSockAddress(t *testing.T) {
 				assert.Equal(t, tt.expectedPath, gotPath, "isValidVSockAddress() path mismatch")
 			}
 
-			// Check that rpcAddress is modified correctly (firecracker modifies it)
+			// Check that rpcAddress is modified correctly (hybrid vsock modifies it)
 			if tt.expectedModifiedAddr != "" {
 				assert.Equal(t, tt.expectedModifiedAddr, addr, "isValidVSockAddress() should modify rpcAddress correctly")
 			}
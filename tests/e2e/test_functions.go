@@ -356,7 +356,7 @@ func httpStaticNetTest(tool testTool) (err error) {
 		for _, iface := range ifaces {
 			names = append(names, iface.Name)
 		}
-		err = fmt.Errorf("Expected tap0_urunc, got %v", names)
+		err = fmt.Errorf("Expected a tap<id>_urunc device, got %v", names)
 		return fmt.Errorf("Failed to find urunc's tap device: %v", err)
 	}
 